@@ -0,0 +1,118 @@
+// Command secrets writes and checks encrypted Bitnob credential files for the
+// encrypted: secret provider, and runs a stand-in Vault for the vault:
+// provider in development.
+//
+//	echo '{"client_id":"...","client_secret":"..."}' | secrets seal -out creds.enc -key-file master.key
+//	SECRETS_PASSPHRASE=... secrets seal -out creds.enc < creds.json
+//	secrets check -in creds.enc -key-file master.key
+//	VAULT_TOKEN=dev secrets vault-dev -path secret/bitnob < creds.json
+//	VAULT_TOKEN=dev secrets check -spec vault:secret/data/bitnob
+//
+// The stand-in serves the secret at secret/bitnob as KV v1 and at
+// secret/data/bitnob as KV v2, and replaces it on a POST to either path.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/secrets"
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		log.Fatal("usage: secrets seal|check|vault-dev [flags]")
+	}
+
+	// The passphrase is only read from the environment to keep it out of
+	// shell history
+	passphrase := os.Getenv("SECRETS_PASSPHRASE")
+	flags := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	keyFile := flags.String("key-file", os.Getenv("SECRETS_KEY_FILE"), "file holding the encryption key")
+	in := flags.String("in", "", "encrypted file to check")
+	out := flags.String("out", "", "encrypted file to write")
+	spec := flags.String("spec", "", "secret provider to check instead of a file, e.g. vault:secret/data/bitnob")
+	addr := flags.String("addr", "127.0.0.1:8200", "address the stand-in Vault listens on")
+	path := flags.String("path", "secret/bitnob", "KV v1 path of the stand-in Vault secret")
+	flags.Parse(os.Args[2:])
+	vaultAddr := os.Getenv("VAULT_ADDR")
+	if vaultAddr == "" {
+		vaultAddr = "http://127.0.0.1:8200"
+	}
+
+	switch os.Args[1] {
+	case "seal":
+		if *out == "" {
+			log.Fatal("seal: -out is required")
+		}
+		raw, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatal("seal: ", err)
+		}
+		var creds secrets.Credentials
+		if err := json.Unmarshal(raw, &creds); err != nil {
+			log.Fatal("seal: credentials must be JSON with client_id and client_secret: ", err)
+		}
+		sealed, err := secrets.Seal(creds, *keyFile, passphrase)
+		if err != nil {
+			log.Fatal("seal: ", err)
+		}
+		if err := os.WriteFile(*out, sealed, 0o600); err != nil {
+			log.Fatal("seal: ", err)
+		}
+		fmt.Printf("Sealed credentials for client %s to %s\n", creds.ClientID, *out)
+	case "check":
+		if *spec != "" {
+			provider, err := secrets.Parse(*spec, secrets.Credentials{}, secrets.Options{
+				KeyFile:    *keyFile,
+				Passphrase: passphrase,
+				VaultAddr:  vaultAddr,
+				VaultToken: os.Getenv("VAULT_TOKEN"),
+			})
+			if err != nil {
+				log.Fatal("check: ", err)
+			}
+			creds, err := provider.Load(context.Background())
+			if err != nil {
+				log.Fatal("check: ", err)
+			}
+			fmt.Printf("%s holds credentials for client %s\n", provider, creds.ClientID)
+			return
+		}
+		raw, err := os.ReadFile(*in)
+		if err != nil {
+			log.Fatal("check: ", err)
+		}
+		creds, err := secrets.Open(raw, *keyFile, passphrase)
+		if err != nil {
+			log.Fatal("check: ", err)
+		}
+		fmt.Printf("%s holds credentials for client %s\n", *in, creds.ClientID)
+	case "vault-dev":
+		mount, name, ok := strings.Cut(strings.Trim(*path, "/"), "/")
+		if !ok || name == "" {
+			log.Fatal("vault-dev: -path must be MOUNT/NAME, e.g. secret/bitnob")
+		}
+		raw, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatal("vault-dev: ", err)
+		}
+		vault := &devVault{mount: mount, name: name, token: os.Getenv("VAULT_TOKEN"), version: 1, updated: time.Now().UTC()}
+		if err := json.Unmarshal(raw, &vault.creds); err != nil {
+			log.Fatal("vault-dev: credentials must be JSON with client_id and client_secret: ", err)
+		}
+		log.Printf("Serving %s/%s (KV v1) and %s/data/%s (KV v2) on %s", mount, name, mount, name, *addr)
+		log.Fatal(http.ListenAndServe(*addr, vault))
+	default:
+		log.Fatalf("unknown command %q, expected seal, check or vault-dev", os.Args[1])
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/secrets"
+)
+
+// devVault stands in for Vault during development. It serves one secret as
+// both a KV v1 and a KV v2 read, so the vault: provider can be pointed at
+// either layout, and accepts writes so rotations can be rehearsed.
+type devVault struct {
+	mount string
+	name  string
+	token string
+
+	mu      sync.Mutex
+	creds   secrets.Credentials
+	version int
+	updated time.Time
+}
+
+func (v *devVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if v.token != "" && r.Header.Get("X-Vault-Token") != v.token {
+		writeVault(w, http.StatusForbidden, map[string]interface{}{"errors": []string{"permission denied"}})
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/"), "/")
+	var v2 bool
+	switch path {
+	case v.mount + "/" + v.name:
+	case v.mount + "/data/" + v.name:
+		v2 = true
+	default:
+		writeVault(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		v.read(w, v2)
+	case http.MethodPost, http.MethodPut:
+		v.write(w, r, v2)
+	default:
+		writeVault(w, http.StatusMethodNotAllowed, map[string]interface{}{"errors": []string{"unsupported method"}})
+	}
+}
+
+func (v *devVault) read(w http.ResponseWriter, v2 bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	fields := map[string]string{"client_id": v.creds.ClientID, "client_secret": v.creds.ClientSecret}
+	if !v2 {
+		writeVault(w, http.StatusOK, map[string]interface{}{"data": fields})
+		return
+	}
+	writeVault(w, http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"data": fields,
+			"metadata": map[string]interface{}{
+				"created_time": v.updated.Format(time.RFC3339Nano),
+				"version":      v.version,
+			},
+		},
+	})
+}
+
+// write replaces the secret; KV v2 writes nest the fields in data
+func (v *devVault) write(w http.ResponseWriter, r *http.Request, v2 bool) {
+	raw, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		writeVault(w, http.StatusBadRequest, map[string]interface{}{"errors": []string{err.Error()}})
+		return
+	}
+	var creds secrets.Credentials
+	if v2 {
+		var body struct {
+			Data secrets.Credentials `json:"data"`
+		}
+		err = json.Unmarshal(raw, &body)
+		creds = body.Data
+	} else {
+		err = json.Unmarshal(raw, &creds)
+	}
+	if err != nil || creds.ClientID == "" || creds.ClientSecret == "" {
+		writeVault(w, http.StatusBadRequest, map[string]interface{}{"errors": []string{"client_id and client_secret are required"}})
+		return
+	}
+
+	v.mu.Lock()
+	v.creds = creds
+	v.version++
+	v.updated = time.Now().UTC()
+	version := v.version
+	v.mu.Unlock()
+
+	log.Printf("Stored credentials for client %s as version %d", creds.ClientID, version)
+	if !v2 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeVault(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"version": version}})
+}
+
+func writeVault(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -1,12 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/bitnob-api-demo/config"
-	"github.com/bitnob-api-demo/internal/api"
 	"github.com/bitnob-api-demo/internal/bitnob"
 	"github.com/bitnob-api-demo/internal/middleware"
+	"github.com/bitnob-api-demo/internal/secretbox"
+	"github.com/bitnob-api-demo/internal/secrets"
+	"github.com/bitnob-api-demo/internal/tenant"
 	"github.com/gin-gonic/gin"
 )
 
@@ -17,58 +26,108 @@ func main() {
 	// Set Gin mode
 	gin.SetMode(config.AppConfig.GinMode)
 
-	// Initialize Bitnob client
-	bitnobClient := bitnob.NewClient(
-		config.AppConfig.BitnobAPIURL,
-		config.AppConfig.BitnobClientID,
-		config.AppConfig.BitnobClientSecret,
-	)
+	// Background workers stop when the server shuts down
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	// Initialize handlers
-	transferHandler := api.NewTransferHandler(bitnobClient)
-	payoutHandler := api.NewPayoutHandler(bitnobClient)
-	tradingHandler := api.NewTradingHandler(bitnobClient)
-
-	// Setup router
-	router := gin.Default()
+	// Each tenant gets its own client, stores and workers
+	environment, err := bitnob.ParseEnvironment(config.AppConfig.BitnobEnvironment)
+	if err != nil {
+		log.Fatal("Invalid BITNOB_ENVIRONMENT:", err)
+	}
+	adminKeys, err := middleware.ParseAdminKeys(config.AppConfig.AdminAPIKeys)
+	if err != nil {
+		log.Fatal("Invalid ADMIN_API_KEYS:", err)
+	}
+	baseURL := config.AppConfig.BitnobAPIURL
+	if baseURL == "" {
+		baseURL = bitnob.BaseURLs[environment]
+	}
+	defaults := tenant.Tenant{
+		ID:                   "default",
+		ClientID:             config.AppConfig.BitnobClientID,
+		ClientSecret:         config.AppConfig.BitnobClientSecret,
+		Credentials:          config.AppConfig.BitnobCredentials,
+		SecondaryCredentials: config.AppConfig.BitnobSecondaryCredentials,
+		Environment:          environment,
+		ConfirmProduction:    config.AppConfig.ConfirmProduction,
+		BaseURL:              baseURL,
+		WebhookSecret:        config.AppConfig.WebhookSecret,
+		AdminKeys:            adminKeys,
+		CORSOrigins:          middleware.DefaultOrigins,
+		VelocityLimits:       config.AppConfig.VelocityLimits,
+		VelocityWindow:       tenant.Duration(config.AppConfig.VelocityWindow),
+		BatchConcurrency:     config.AppConfig.BatchConcurrency,
+	}
+	tenants := []tenant.Tenant{defaults}
+	if config.AppConfig.TenantsFile != "" {
+		defaults.WebhookSecret = ""
+		defaults.AdminKeys = nil
+		defaults.ConfirmProduction = false
+		defaults.Credentials = ""
+		defaults.SecondaryCredentials = ""
+		if tenants, err = tenant.Load(config.AppConfig.TenantsFile, defaults); err != nil {
+			log.Fatal("Invalid TENANTS_FILE:", err)
+		}
+	}
+	box := newSecretBox()
+	gateway := tenant.NewGateway(tenants, func(t tenant.Tenant) http.Handler {
+		return newTenantRouter(ctx, t, box)
+	})
 
-	// Apply middleware
-	router.Use(middleware.CORS())
-	router.Use(middleware.Logger())
-	router.Use(middleware.Recovery())
+	// Start server
+	server := &http.Server{
+		Addr:    ":" + config.AppConfig.Port,
+		Handler: gateway,
+	}
 
-	// API routes
-	api := router.Group("/api")
-	{
-		// Wallet routes
-		wallets := api.Group("/wallets")
-		{
-			wallets.POST("/transfers", transferHandler.CreateTransfer)
+	go func() {
+		log.Printf("Starting server on port %s for %d tenants", config.AppConfig.Port, len(tenants))
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Failed to start server:", err)
 		}
+	}()
 
-		// Payout routes
-		payouts := api.Group("/payouts")
-		{
-			payouts.POST("/quotes", payoutHandler.CreateQuote)
-			payouts.POST("/initialize", payoutHandler.InitializePayout)
-			payouts.POST("/finalize", payoutHandler.FinalizePayout)
-			payouts.GET("/countries/:country/requirements", payoutHandler.GetCountryRequirements)
-			payouts.GET("/limits", payoutHandler.GetTransactionLimits)
-		}
+	// Wait for shutdown signal
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+
+	log.Println("Shutting down server")
+	cancel()
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Println("Server forced to shutdown:", err)
+	}
+}
 
-		// Trading routes
-		trading := api.Group("/trading")
-		{
-			trading.POST("/quotes", tradingHandler.CreateQuote)
-			trading.POST("/orders", tradingHandler.CreateOrder)
-			trading.GET("/orders", tradingHandler.GetOrders)
-			trading.GET("/orders/:id", tradingHandler.GetOrderByID)
+// secretOptions configures the credential providers that need keys or tokens
+func secretOptions() secrets.Options {
+	return secrets.Options{
+		KeyFile:    config.AppConfig.SecretsKeyFile,
+		Passphrase: config.AppConfig.SecretsPassphrase,
+		VaultAddr:  config.AppConfig.VaultAddr,
+		VaultToken: config.AppConfig.VaultToken,
+	}
+}
+
+// newSecretBox builds the cipher used for sensitive fields at rest. Without a
+// configured key, an ephemeral one is generated for this process.
+func newSecretBox() *secretbox.Box {
+	key := secretbox.KeyFromString(config.AppConfig.EncryptionKey)
+	if config.AppConfig.EncryptionKey == "" {
+		log.Println("DATA_ENCRYPTION_KEY not set, using an ephemeral encryption key")
+		var err error
+		if key, err = secretbox.RandomKey(); err != nil {
+			log.Fatal("Failed to generate encryption key:", err)
 		}
 	}
 
-	// Start server
-	log.Printf("Starting server on port %s", config.AppConfig.Port)
-	if err := router.Run(":" + config.AppConfig.Port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	box, err := secretbox.New(key)
+	if err != nil {
+		log.Fatal("Failed to initialize encryption:", err)
 	}
+	return box
 }
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bitnob-api-demo/config"
+	"github.com/bitnob-api-demo/internal/activity"
+	"github.com/bitnob-api-demo/internal/address"
+	"github.com/bitnob-api-demo/internal/addressbook"
+	"github.com/bitnob-api-demo/internal/api"
+	"github.com/bitnob-api-demo/internal/audit"
+	"github.com/bitnob-api-demo/internal/batch"
+	"github.com/bitnob-api-demo/internal/beneficiary"
+	"github.com/bitnob-api-demo/internal/bitnob"
+	"github.com/bitnob-api-demo/internal/conditional"
+	"github.com/bitnob-api-demo/internal/dca"
+	"github.com/bitnob-api-demo/internal/events"
+	"github.com/bitnob-api-demo/internal/exports"
+	"github.com/bitnob-api-demo/internal/ledger"
+	"github.com/bitnob-api-demo/internal/middleware"
+	"github.com/bitnob-api-demo/internal/orders"
+	"github.com/bitnob-api-demo/internal/portfolio"
+	"github.com/bitnob-api-demo/internal/quotes"
+	"github.com/bitnob-api-demo/internal/reconcile"
+	"github.com/bitnob-api-demo/internal/requirements"
+	"github.com/bitnob-api-demo/internal/scheduler"
+	"github.com/bitnob-api-demo/internal/secretbox"
+	"github.com/bitnob-api-demo/internal/secrets"
+	"github.com/bitnob-api-demo/internal/tenant"
+	"github.com/bitnob-api-demo/internal/twap"
+	"github.com/bitnob-api-demo/internal/velocity"
+	"github.com/gin-gonic/gin"
+)
+
+// newTenantRouter wires a tenant's own Bitnob client, stores, background
+// workers and routes. Nothing is shared between tenants except the secret
+// box, so one tenant's data is never visible to another.
+func newTenantRouter(ctx context.Context, t tenant.Tenant, box *secretbox.Box) http.Handler {
+	logger := log.New(log.Writer(), "[tenant "+t.ID+"] ", log.Flags())
+
+	// Load credentials from the tenant's secret provider
+	provider, err := secrets.Parse(t.Credentials, secrets.Credentials{ClientID: t.ClientID, ClientSecret: t.ClientSecret}, secretOptions())
+	if err != nil {
+		logger.Fatal("Invalid credentials provider:", err)
+	}
+	creds, err := provider.Load(ctx)
+	if err != nil {
+		logger.Fatalf("Failed to load credentials from %s: %v", provider, err)
+	}
+	logger.Printf("Loaded Bitnob credentials from %s", provider)
+
+	// Refuse to start with credentials from another environment
+	logger.Printf("Using the Bitnob %s environment at %s", t.Environment, t.BaseURL)
+	if config.AppConfig.EnvironmentCheck {
+		err := bitnob.CheckEnvironment(t.Environment, t.BaseURL, creds.ClientID, creds.ClientSecret)
+		if errors.Is(err, bitnob.ErrUnverified) {
+			logger.Println("Skipping environment check:", err)
+		} else if err != nil {
+			logger.Fatal("Environment check failed:", err)
+		}
+	}
+	if t.Environment == bitnob.Production && !t.ConfirmProduction {
+		logger.Println("Production money movement not confirmed, transfers, payouts and orders are refused")
+	}
+
+	// Initialize Bitnob client; payouts are held against customer balances,
+	// then transfers and payouts are recorded for exports
+	auditLog := audit.NewLog()
+	activityLog := activity.NewLog()
+	customerLedger := ledger.NewLedger(auditLog, config.AppConfig.LedgerHoldTTL).WithLogger(logger)
+	upstream := bitnob.NewClient(t.BaseURL, creds.ClientID, creds.ClientSecret).WithLogger(logger)
+	bitnobClient := ledger.NewClient(activity.NewClient(upstream, activityLog), customerLedger).WithLogger(logger)
+
+	// Rotated credentials are swapped in without a restart. A pair retired by
+	// a promotion stays retired if the source still holds it.
+	secrets.Watch(ctx, logger, provider, config.AppConfig.SecretsRefresh, creds, func(next secrets.Credentials) {
+		upstream.SetCredentials(next.ClientID, next.ClientSecret)
+	})
+
+	// A secondary pair overlaps a key rotation; calls rejected with one pair
+	// are retried with the other until the secondary is promoted
+	if t.SecondaryCredentials != "" {
+		secondary, err := secrets.Parse(t.SecondaryCredentials, secrets.Credentials{}, secretOptions())
+		if err != nil {
+			logger.Fatal("Invalid secondary credentials provider:", err)
+		}
+		next, err := secondary.Load(ctx)
+		if err != nil {
+			logger.Printf("Failed to load secondary credentials from %s, retrying: %v", secondary, err)
+		} else {
+			upstream.SetSecondary(next.ClientID, next.ClientSecret)
+			logger.Printf("Loaded secondary Bitnob credentials from %s", secondary)
+		}
+		secrets.Watch(ctx, logger, secondary, config.AppConfig.SecretsRefresh, next, func(next secrets.Credentials) {
+			upstream.SetSecondary(next.ClientID, next.ClientSecret)
+		})
+	}
+
+	// Initialize caches
+	reqCache := requirements.NewCache(bitnobClient, config.AppConfig.RequirementsTTL).WithLogger(logger)
+	reqCache.Start(ctx, config.AppConfig.CacheRefreshEvery)
+
+	// Initialize stores
+	quoteBook := quotes.NewBook()
+	eventBroker := events.NewBroker(1000)
+	orderStore := orders.NewStore(bitnobClient, eventBroker, orders.PollConfig{
+		Tick:        time.Second,
+		MinInterval: config.AppConfig.OrderPollMin,
+		MaxInterval: config.AppConfig.OrderPollMax,
+	}).WithLogger(logger)
+	orderStore.Start(ctx, config.AppConfig.OrderSyncInterval)
+	orderStore.StartPoller(ctx)
+	// Without BITCOIN_NETWORK, sandbox tenants take testnet addresses and
+	// production tenants mainnet ones
+	networkName := config.AppConfig.BitcoinNetwork
+	if networkName == "" {
+		networkName = t.Environment
+	}
+	network := address.ParseNetwork(networkName)
+	addressBook := addressbook.NewBook(addressbook.Config{
+		Network:    network,
+		CoolingOff: config.AppConfig.AddressCoolingOff,
+		Whitelist:  config.AppConfig.TransferWhitelist,
+	}, auditLog)
+	velocityLimits, err := velocity.ParseLimits(t.VelocityLimits)
+	if err != nil {
+		logger.Fatal("Invalid velocity limits:", err)
+	}
+	velocityTracker := velocity.NewTracker(time.Duration(t.VelocityWindow), velocityLimits)
+	beneficiaries := beneficiary.NewStore(box, reqCache, auditLog)
+	payoutBatches := batch.NewPayoutService(ctx, bitnobClient, beneficiaries, reqCache, t.BatchConcurrency).WithLogger(logger)
+	transferBatches := batch.NewTransferService(ctx, bitnobClient, network, addressBook, velocityTracker, t.BatchConcurrency).WithLogger(logger)
+	payoutSchedules := scheduler.NewService(bitnobClient, beneficiaries, reqCache, auditLog).WithLogger(logger)
+	payoutSchedules.Start(ctx, config.AppConfig.SchedulerInterval)
+	tradingPlans := dca.NewService(bitnobClient, auditLog).WithLogger(logger)
+	tradingPlans.Start(ctx, config.AppConfig.SchedulerInterval)
+	conditionalOrders := conditional.NewService(bitnobClient, auditLog).WithLogger(logger)
+	conditionalOrders.Start(ctx, config.AppConfig.TriggerPollEvery)
+	twapOrders := twap.NewService(ctx, bitnobClient).WithLogger(logger)
+	costBasisMethod, err := portfolio.ParseMethod(config.AppConfig.CostBasisMethod)
+	if err != nil {
+		logger.Fatal("Invalid COST_BASIS_METHOD:", err)
+	}
+	reportingRates, err := portfolio.ParseRates(config.AppConfig.ReportingRates)
+	if err != nil {
+		logger.Fatal("Invalid REPORTING_FX_RATES:", err)
+	}
+	portfolioService := portfolio.NewService(bitnobClient, orderStore, costBasisMethod, config.AppConfig.ReportingCurrency, reportingRates).WithLogger(logger)
+	portfolioService.Start(ctx, config.AppConfig.PortfolioSnapEvery)
+	accountChart, err := exports.ParseChart(config.AppConfig.AccountMapping)
+	if err != nil {
+		logger.Fatal("Invalid ACCOUNT_MAPPING:", err)
+	}
+	exportService := exports.NewService(activityLog, portfolioService, accountChart)
+	reconciler := reconcile.NewService(bitnobClient, orderStore, activityLog, auditLog).WithLogger(logger)
+	reconciler.Start(ctx, config.AppConfig.ReconcileInterval)
+	customerLedger.Start(ctx, config.AppConfig.SchedulerInterval)
+	wsGrants, err := events.ParseGrants(config.AppConfig.WSAPIKeys)
+	if err != nil {
+		logger.Fatal("Invalid WS_API_KEYS:", err)
+	}
+	switch {
+	case len(wsGrants) == 0 && len(t.APIKeys) == 0:
+		logger.Println("WS_API_KEYS not set and the tenant has no API keys, the event WebSocket is disabled")
+	case len(wsGrants) == 0:
+		logger.Println("WS_API_KEYS not set, the event WebSocket accepts the tenant API keys")
+	}
+	if t.WebhookSecret == "" {
+		logger.Println("Webhook secret not set, Bitnob webhooks are refused")
+	}
+	if len(t.AdminKeys) == 0 || len(t.APIKeys) == 0 {
+		logger.Println("Admin keys or tenant API keys not set, address book changes, beneficiary verification, ledger deposits and admin routes are disabled")
+	}
+
+	// Initialize handlers
+	transferHandler := api.NewTransferHandler(bitnobClient, network, addressBook, velocityTracker, eventBroker)
+	transferBatchHandler := api.NewTransferBatchHandler(transferBatches)
+	addressBookHandler := api.NewAddressBookHandler(addressBook)
+	payoutHandler := api.NewPayoutHandler(bitnobClient, beneficiaries, reqCache, quoteBook, eventBroker)
+	beneficiaryHandler := api.NewBeneficiaryHandler(beneficiaries)
+	payoutBatchHandler := api.NewPayoutBatchHandler(payoutBatches)
+	payoutScheduleHandler := api.NewPayoutScheduleHandler(payoutSchedules)
+	tradingHandler := api.NewTradingHandler(bitnobClient, quoteBook, orderStore, eventBroker)
+	quoteHandler := api.NewQuoteHandler(quoteBook)
+	tradingPlanHandler := api.NewTradingPlanHandler(tradingPlans)
+	conditionalOrderHandler := api.NewConditionalOrderHandler(conditionalOrders)
+	twapHandler := api.NewTWAPHandler(twapOrders)
+	portfolioHandler := api.NewPortfolioHandler(portfolioService)
+	exportHandler := api.NewExportHandler(exportService)
+	reconciliationHandler := api.NewReconciliationHandler(reconciler)
+	ledgerHandler := api.NewLedgerHandler(customerLedger)
+	webhookHandler := api.NewWebhookHandler(t.WebhookSecret, eventBroker, reconciler, customerLedger)
+	webSocketHandler := api.NewWebSocketHandler(eventBroker, wsGrants, t.CORSOrigins, len(t.APIKeys) > 0)
+	credentialHandler := api.NewCredentialHandler(upstream, auditLog)
+
+	// Setup router
+	router := gin.New()
+
+	// Apply middleware
+	router.Use(middleware.CORS(t.CORSOrigins))
+	router.Use(middleware.Logger(t.ID))
+	router.Use(middleware.Recovery())
+	router.Use(middleware.Environment(t.Environment))
+	moves := middleware.MoneyMovement(t.Environment, t.ConfirmProduction)
+	admin := middleware.Admin(t.AdminKeys, len(t.APIKeys) == 0)
+
+	// API routes
+	api := router.Group("/api")
+	{
+		// Wallet routes
+		wallets := api.Group("/wallets")
+		{
+			wallets.POST("/transfers", moves, transferHandler.CreateTransfer)
+			wallets.GET("/transfers/batches", transferBatchHandler.ListBatches)
+			wallets.POST("/transfers/batches", moves, transferBatchHandler.CreateBatch)
+			wallets.GET("/transfers/batches/:id", transferBatchHandler.GetBatch)
+			wallets.GET("/transfers/batches/:id/report", transferBatchHandler.DownloadReport)
+
+			// Address book routes; changes name an authenticated operator
+			wallets.GET("/addresses", addressBookHandler.ListAddresses)
+			wallets.POST("/addresses", admin, addressBookHandler.CreateAddress)
+			wallets.GET("/addresses/audit", addressBookHandler.GetAuditTrail)
+			wallets.GET("/addresses/:id", addressBookHandler.GetAddress)
+			wallets.PUT("/addresses/:id", admin, addressBookHandler.UpdateAddress)
+			wallets.DELETE("/addresses/:id", admin, addressBookHandler.DeleteAddress)
+		}
+
+		// Payout routes
+		payouts := api.Group("/payouts")
+		{
+			payouts.POST("/quotes", payoutHandler.CreateQuote)
+			payouts.POST("/initialize", moves, payoutHandler.InitializePayout)
+			payouts.POST("/finalize", moves, payoutHandler.FinalizePayout)
+			payouts.GET("/countries/:country/requirements", payoutHandler.GetCountryRequirements)
+			payouts.GET("/limits", payoutHandler.GetTransactionLimits)
+
+			// Beneficiary routes
+			payouts.GET("/beneficiaries", beneficiaryHandler.ListBeneficiaries)
+			payouts.POST("/beneficiaries", beneficiaryHandler.CreateBeneficiary)
+			payouts.GET("/beneficiaries/:id", beneficiaryHandler.GetBeneficiary)
+			payouts.PUT("/beneficiaries/:id", beneficiaryHandler.UpdateBeneficiary)
+			payouts.DELETE("/beneficiaries/:id", beneficiaryHandler.DeleteBeneficiary)
+			payouts.POST("/beneficiaries/:id/verification", admin, beneficiaryHandler.SetVerification)
+
+			// Batch payout routes
+			payouts.GET("/batches", payoutBatchHandler.ListBatches)
+			payouts.POST("/batches", moves, payoutBatchHandler.CreateBatch)
+			payouts.GET("/batches/:id", payoutBatchHandler.GetBatch)
+			payouts.POST("/batches/:id/confirm", moves, payoutBatchHandler.ConfirmBatch)
+			payouts.POST("/batches/:id/retry", moves, payoutBatchHandler.RetryBatch)
+			payouts.GET("/batches/:id/results", payoutBatchHandler.DownloadResults)
+
+			// Scheduled payout routes
+			payouts.GET("/schedules", payoutScheduleHandler.ListSchedules)
+			payouts.POST("/schedules", moves, payoutScheduleHandler.CreateSchedule)
+			payouts.GET("/schedules/:id", payoutScheduleHandler.GetSchedule)
+			payouts.PUT("/schedules/:id", moves, payoutScheduleHandler.UpdateSchedule)
+			payouts.DELETE("/schedules/:id", payoutScheduleHandler.DeleteSchedule)
+			payouts.POST("/schedules/:id/pause", payoutScheduleHandler.PauseSchedule)
+			payouts.POST("/schedules/:id/resume", moves, payoutScheduleHandler.ResumeSchedule)
+			payouts.GET("/schedules/:id/runs", payoutScheduleHandler.ListRuns)
+		}
+
+		// Quote routes
+		api.GET("/quotes/:id", quoteHandler.GetQuote)
+
+		// Trading routes
+		trading := api.Group("/trading")
+		{
+			trading.POST("/quotes", tradingHandler.CreateQuote)
+			trading.POST("/orders", moves, tradingHandler.CreateOrder)
+			trading.GET("/orders", tradingHandler.GetOrders)
+			trading.GET("/orders/stream", tradingHandler.StreamOrders)
+			trading.GET("/orders/:id", tradingHandler.GetOrderByID)
+
+			// Recurring buy plan routes
+			trading.GET("/plans", tradingPlanHandler.ListPlans)
+			trading.POST("/plans", moves, tradingPlanHandler.CreatePlan)
+			trading.GET("/plans/:id", tradingPlanHandler.GetPlan)
+			trading.PUT("/plans/:id", moves, tradingPlanHandler.UpdatePlan)
+			trading.DELETE("/plans/:id", tradingPlanHandler.DeletePlan)
+			trading.POST("/plans/:id/pause", tradingPlanHandler.PausePlan)
+			trading.POST("/plans/:id/resume", moves, tradingPlanHandler.ResumePlan)
+			trading.GET("/plans/:id/runs", tradingPlanHandler.ListRuns)
+
+			// Conditional order routes
+			trading.GET("/conditional-orders", conditionalOrderHandler.ListOrders)
+			trading.POST("/conditional-orders", moves, conditionalOrderHandler.CreateOrder)
+			trading.GET("/conditional-orders/:id", conditionalOrderHandler.GetOrder)
+			trading.DELETE("/conditional-orders/:id", conditionalOrderHandler.CancelOrder)
+
+			// TWAP execution routes
+			trading.GET("/twap", twapHandler.ListOrders)
+			trading.POST("/twap", moves, twapHandler.CreateOrder)
+			trading.GET("/twap/:id", twapHandler.GetOrder)
+			trading.POST("/twap/:id/cancel", twapHandler.CancelOrder)
+		}
+
+		// Portfolio routes
+		api.GET("/portfolio", portfolioHandler.GetPortfolio)
+		api.GET("/portfolio/snapshots", portfolioHandler.ListSnapshots)
+		api.POST("/portfolio/snapshots", portfolioHandler.CreateSnapshot)
+		api.GET("/portfolio/tax-report", portfolioHandler.GetTaxReport)
+
+		// Accounting export routes
+		api.GET("/exports", exportHandler.GetExport)
+		api.GET("/exports/accounts", exportHandler.GetAccounts)
+
+		// Reconciliation routes
+		api.GET("/reconciliation", reconciliationHandler.GetReport)
+		api.GET("/reconciliation/runs", reconciliationHandler.ListRuns)
+		api.POST("/reconciliation/runs", reconciliationHandler.CreateRun)
+		api.GET("/reconciliation/discrepancies/:id", reconciliationHandler.GetDiscrepancy)
+		api.POST("/reconciliation/discrepancies/:id/acknowledge", reconciliationHandler.AcknowledgeDiscrepancy)
+		api.POST("/reconciliation/discrepancies/:id/resolve", reconciliationHandler.ResolveDiscrepancy)
+
+		// Customer ledger routes
+		api.GET("/ledger/accounts", ledgerHandler.ListAccounts)
+		api.GET("/ledger/entries", ledgerHandler.ListEntries)
+		api.GET("/ledger/customers/:id", ledgerHandler.GetCustomer)
+		api.POST("/ledger/customers/:id/deposits", admin, ledgerHandler.CreateDeposit)
+		api.GET("/ledger/customers/:id/holds", ledgerHandler.ListHolds)
+
+		// Credential rotation routes
+		admins := api.Group("/admin", admin)
+		{
+			admins.GET("/credentials", credentialHandler.GetCredentials)
+			admins.PUT("/credentials/secondary", credentialHandler.StageSecondary)
+			admins.DELETE("/credentials/secondary", credentialHandler.DeleteSecondary)
+			admins.POST("/credentials/promote", credentialHandler.Promote)
+		}
+
+		// Event routes
+		api.GET("/ws", webSocketHandler.Connect)
+		api.POST("/webhooks/bitnob", webhookHandler.ReceiveBitnob)
+	}
+
+	return router
+}
@@ -3,34 +3,121 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	BitnobClientID     string
-	BitnobClientSecret string
-	BitnobAPIURL       string
-	Port               string
-	GinMode            string
+	BitnobClientID             string
+	BitnobClientSecret         string
+	BitnobCredentials          string
+	BitnobSecondaryCredentials string
+	SecretsKeyFile             string
+	SecretsPassphrase          string
+	VaultAddr                  string
+	VaultToken                 string
+	SecretsRefresh             time.Duration
+	BitnobAPIURL               string
+	BitnobEnvironment          string
+	ConfirmProduction          bool
+	EnvironmentCheck           bool
+	Port                       string
+	GinMode                    string
+	BitcoinNetwork             string
+	TransferWhitelist          bool
+	AddressCoolingOff          time.Duration
+	EncryptionKey              string
+	RequirementsTTL            time.Duration
+	CacheRefreshEvery          time.Duration
+	BatchConcurrency           int
+	VelocityLimits             string
+	VelocityWindow             time.Duration
+	SchedulerInterval          time.Duration
+	TriggerPollEvery           time.Duration
+	OrderSyncInterval          time.Duration
+	OrderPollMin               time.Duration
+	OrderPollMax               time.Duration
+	WebhookSecret              string
+	WSAPIKeys                  string
+	AdminAPIKeys               string
+	CostBasisMethod            string
+	PortfolioSnapEvery         time.Duration
+	ReportingCurrency          string
+	ReportingRates             string
+	AccountMapping             string
+	ReconcileInterval          time.Duration
+	LedgerHoldTTL              time.Duration
+	TenantsFile                string
 }
 
 var AppConfig *Config
 
+// defaultAccountMapping is the chart of accounts used by accounting exports,
+// as type:currency:role=account entries
+const defaultAccountMapping = "*:*:wallet=Assets:Bitnob:{currency}," +
+	"transfer:*:clearing=Liabilities:Transfers in transit:{currency}," +
+	"payout:*:clearing=Expenses:Payouts:{currency}," +
+	"trade:*:clearing=Equity:Trading:{currency}," +
+	"*:*:fees=Expenses:Bitnob fees:{currency}"
+
 func Load() {
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, using environment variables")
 	}
 
 	AppConfig = &Config{
-		BitnobClientID:     getEnv("BITNOB_CLIENT_ID", ""),
-		BitnobClientSecret: getEnv("BITNOB_CLIENT_SECRET", ""),
-		BitnobAPIURL:       getEnv("BITNOB_API_URL", "https://api.bitnob.co"),
-		Port:               getEnv("PORT", "8080"),
-		GinMode:            getEnv("GIN_MODE", "debug"),
+		BitnobClientID:             getEnv("BITNOB_CLIENT_ID", ""),
+		BitnobClientSecret:         getEnv("BITNOB_CLIENT_SECRET", ""),
+		BitnobCredentials:          getEnv("BITNOB_CREDENTIALS", "env"),
+		BitnobSecondaryCredentials: getEnv("BITNOB_SECONDARY_CREDENTIALS", ""),
+		SecretsKeyFile:             getEnv("SECRETS_KEY_FILE", ""),
+		SecretsPassphrase:          getEnv("SECRETS_PASSPHRASE", ""),
+		VaultAddr:                  getEnv("VAULT_ADDR", "http://127.0.0.1:8200"),
+		VaultToken:                 getEnv("VAULT_TOKEN", ""),
+		SecretsRefresh:             getEnvDuration("SECRETS_REFRESH_INTERVAL", time.Minute),
+		BitnobAPIURL:               getEnv("BITNOB_API_URL", ""),
+		BitnobEnvironment:          getEnv("BITNOB_ENVIRONMENT", "sandbox"),
+		ConfirmProduction:          getEnvBool("BITNOB_CONFIRM_PRODUCTION", false),
+		EnvironmentCheck:           getEnvBool("BITNOB_ENVIRONMENT_CHECK", true),
+		Port:                       getEnv("PORT", "8080"),
+		GinMode:                    getEnv("GIN_MODE", "debug"),
+		BitcoinNetwork:             getEnv("BITCOIN_NETWORK", ""),
+		TransferWhitelist:          getEnvBool("TRANSFER_WHITELIST_ENABLED", false),
+		AddressCoolingOff:          getEnvDuration("ADDRESS_COOLING_OFF", 24*time.Hour),
+		EncryptionKey:              getEnv("DATA_ENCRYPTION_KEY", ""),
+		RequirementsTTL:            getEnvDuration("REQUIREMENTS_CACHE_TTL", time.Hour),
+		CacheRefreshEvery:          getEnvDuration("CACHE_REFRESH_INTERVAL", time.Minute),
+		BatchConcurrency:           getEnvInt("BATCH_CONCURRENCY", 4),
+		VelocityLimits:             getEnv("TRANSFER_VELOCITY_LIMITS", ""),
+		VelocityWindow:             getEnvDuration("TRANSFER_VELOCITY_WINDOW", 24*time.Hour),
+		SchedulerInterval:          getEnvDuration("SCHEDULER_INTERVAL", 30*time.Second),
+		TriggerPollEvery:           getEnvDuration("TRIGGER_POLL_INTERVAL", 10*time.Second),
+		OrderSyncInterval:          getEnvDuration("ORDER_SYNC_INTERVAL", time.Minute),
+		OrderPollMin:               getEnvDuration("ORDER_POLL_MIN_INTERVAL", 2*time.Second),
+		OrderPollMax:               getEnvDuration("ORDER_POLL_MAX_INTERVAL", time.Minute),
+		WebhookSecret:              getEnv("BITNOB_WEBHOOK_SECRET", ""),
+		WSAPIKeys:                  getEnv("WS_API_KEYS", ""),
+		AdminAPIKeys:               getEnv("ADMIN_API_KEYS", ""),
+		CostBasisMethod:            getEnv("COST_BASIS_METHOD", "fifo"),
+		PortfolioSnapEvery:         getEnvDuration("PORTFOLIO_SNAPSHOT_INTERVAL", time.Hour),
+		ReportingCurrency:          getEnv("REPORTING_CURRENCY", "USD"),
+		ReportingRates:             getEnv("REPORTING_FX_RATES", "USDT/USD:1,USDC/USD:1"),
+		AccountMapping:             getEnv("ACCOUNT_MAPPING", defaultAccountMapping),
+		ReconcileInterval:          getEnvDuration("RECONCILE_INTERVAL", 15*time.Minute),
+		LedgerHoldTTL:              getEnvDuration("LEDGER_HOLD_TTL", 30*time.Minute),
+		TenantsFile:                getEnv("TENANTS_FILE", ""),
+	}
+
+	// A whitelist without a cooling-off period lets a new address be used at once
+	if AppConfig.TransferWhitelist && AppConfig.AddressCoolingOff <= 0 {
+		log.Fatal("ADDRESS_COOLING_OFF must be positive when TRANSFER_WHITELIST_ENABLED is set")
 	}
 
-	if AppConfig.BitnobClientID == "" || AppConfig.BitnobClientSecret == "" {
+	// Tenants and secret providers supply credentials of their own
+	if AppConfig.TenantsFile == "" && AppConfig.BitnobCredentials == "env" &&
+		(AppConfig.BitnobClientID == "" || AppConfig.BitnobClientSecret == "") {
 		log.Fatal("BITNOB_CLIENT_ID and BITNOB_CLIENT_SECRET must be set")
 	}
 }
@@ -41,3 +128,39 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Printf("Invalid boolean for %s, using default %t", key, defaultValue)
+			return defaultValue
+		}
+		return parsed
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			log.Printf("Invalid integer for %s, using default %d", key, defaultValue)
+			return defaultValue
+		}
+		return parsed
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			log.Printf("Invalid duration for %s, using default %s", key, defaultValue)
+			return defaultValue
+		}
+		return parsed
+	}
+	return defaultValue
+}
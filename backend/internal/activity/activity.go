@@ -0,0 +1,302 @@
+package activity
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/models"
+	"github.com/bitnob-api-demo/internal/store"
+)
+
+// Transaction kinds
+const (
+	KindTransfer = "transfer"
+	KindPayout   = "payout"
+	KindTrade    = "trade"
+)
+
+// StatusInitialized marks a payout that has been initialized but not
+// finalized, so no funds have moved yet
+const StatusInitialized = "initialized"
+
+// quoteRetention is how long payout quotes are kept to enrich payouts
+const quoteRetention = time.Hour
+
+// Transaction is a money movement sent through the gateway. Amount and Fee
+// are in Currency; payouts also carry the rate and settlement amount.
+type Transaction struct {
+	ID                 string     `json:"id"`
+	Kind               string     `json:"kind"`
+	Reference          string     `json:"reference"`
+	QuoteID            string     `json:"quote_id,omitempty"`
+	Status             string     `json:"status"`
+	Currency           string     `json:"currency"`
+	Amount             float64    `json:"amount"`
+	Fee                float64    `json:"fee"`
+	Rate               float64    `json:"rate,omitempty"`
+	SettlementCurrency string     `json:"settlement_currency,omitempty"`
+	SettlementAmount   float64    `json:"settlement_amount,omitempty"`
+	Side               string     `json:"side,omitempty"`
+	Counterparty       string     `json:"counterparty,omitempty"`
+	Description        string     `json:"description,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+	FinalizedAt        *time.Time `json:"finalized_at,omitempty"`
+}
+
+// Posted reports whether the transaction moved funds
+func (t Transaction) Posted() bool {
+	switch strings.ToLower(t.Status) {
+	case StatusInitialized, "failed", "cancelled", "canceled", "rejected", "expired", "reversed":
+		return false
+	}
+	return true
+}
+
+// Log records transfers and payouts as they are sent
+type Log struct {
+	transactions *store.Table[Transaction]
+}
+
+// NewLog creates an empty activity log
+func NewLog() *Log {
+	return &Log{transactions: store.NewTable[Transaction]()}
+}
+
+// Record stores a new transaction
+func (l *Log) Record(tx Transaction) Transaction {
+	now := time.Now().UTC()
+	if tx.ID == "" {
+		tx.ID = store.NewID("txn")
+	}
+	if tx.CreatedAt.IsZero() {
+		tx.CreatedAt = now
+	}
+	tx.UpdatedAt = now
+	l.transactions.Put(tx.ID, tx)
+	return tx
+}
+
+// Find returns the transaction of a kind with the given upstream reference
+// or quote ID
+func (l *Log) Find(kind, ref string) (Transaction, bool) {
+	return l.transactions.Find(func(t Transaction) bool {
+		return t.Kind == kind && ref != "" && (t.Reference == ref || t.QuoteID == ref)
+	})
+}
+
+// Update applies fn to a stored transaction
+func (l *Log) Update(id string, fn func(*Transaction)) (Transaction, error) {
+	return l.transactions.Update(id, func(t *Transaction) error {
+		fn(t)
+		t.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+}
+
+// List returns transactions created in [from, to), oldest first; zero bounds
+// are open
+func (l *Log) List(from, to time.Time) []Transaction {
+	rows := l.transactions.List(func(t Transaction) bool {
+		return (from.IsZero() || !t.CreatedAt.Before(from)) && (to.IsZero() || t.CreatedAt.Before(to))
+	})
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].CreatedAt.Before(rows[j].CreatedAt) })
+	return rows
+}
+
+// Upstream is the Bitnob client being recorded
+type Upstream interface {
+	CreateTransfer(req interface{}) (interface{}, error)
+	GetWalletBalances() (interface{}, error)
+	CreatePayoutQuote(req interface{}) (interface{}, error)
+	InitializePayout(req interface{}) (interface{}, error)
+	FinalizePayout(req interface{}) (interface{}, error)
+	GetCountryRequirements(country string) (interface{}, error)
+	GetTransactionLimits() (interface{}, error)
+	CreateTradingQuote(req interface{}) (interface{}, error)
+	CreateOrder(req interface{}) (interface{}, error)
+	GetOrders() (interface{}, error)
+	GetOrderByID(id string) (interface{}, error)
+}
+
+// Client wraps the Bitnob client and records every successful transfer and
+// payout, whether it came from a handler, a batch or a schedule
+type Client struct {
+	Upstream
+	log *Log
+
+	mu     sync.Mutex
+	quotes map[string]payoutQuote
+}
+
+type payoutQuote struct {
+	models.PayoutQuoteResponse
+	fromAsset  string
+	toCurrency string
+	at         time.Time
+}
+
+// NewClient wraps upstream so its money movements are recorded in log
+func NewClient(upstream Upstream, log *Log) *Client {
+	return &Client{
+		Upstream: upstream,
+		log:      log,
+		quotes:   make(map[string]payoutQuote),
+	}
+}
+
+func (c *Client) CreateTransfer(req interface{}) (interface{}, error) {
+	response, err := c.Upstream.CreateTransfer(req)
+	if err != nil {
+		return response, err
+	}
+
+	var in models.TransferRequest
+	var out models.TransferResponse
+	models.Decode(req, &in)
+	models.Decode(response, &out)
+	amount, _ := strconv.ParseFloat(in.Amount, 64)
+	c.log.Record(Transaction{
+		Kind:         KindTransfer,
+		Reference:    out.TransactionID,
+		Status:       out.Status,
+		Currency:     strings.ToUpper(in.Currency),
+		Amount:       amount,
+		Counterparty: in.ToAddress,
+		Description:  firstNonEmpty(in.Description, in.Reference),
+	})
+	return response, nil
+}
+
+func (c *Client) CreatePayoutQuote(req interface{}) (interface{}, error) {
+	response, err := c.Upstream.CreatePayoutQuote(req)
+	if err != nil {
+		return response, err
+	}
+
+	var in models.PayoutQuoteRequest
+	var q payoutQuote
+	models.Decode(req, &in)
+	if models.Decode(response, &q.PayoutQuoteResponse) == nil {
+		q.fromAsset = strings.ToUpper(in.FromAsset)
+		q.toCurrency = strings.ToUpper(in.ToCurrency)
+		q.at = time.Now()
+		c.mu.Lock()
+		for id, old := range c.quotes {
+			if time.Since(old.at) > quoteRetention {
+				delete(c.quotes, id)
+			}
+		}
+		for _, id := range []string{q.ID, q.QuoteID} {
+			if id != "" {
+				c.quotes[id] = q
+			}
+		}
+		c.mu.Unlock()
+	}
+	return response, nil
+}
+
+func (c *Client) InitializePayout(req interface{}) (interface{}, error) {
+	response, err := c.Upstream.InitializePayout(req)
+	if err != nil {
+		return response, err
+	}
+
+	var in models.InitializePayoutRequest
+	var out models.InitializePayoutResponse
+	models.Decode(req, &in)
+	models.Decode(response, &out)
+
+	c.mu.Lock()
+	q, ok := c.quotes[in.QuoteID]
+	c.mu.Unlock()
+
+	tx := Transaction{
+		Kind:               KindPayout,
+		Reference:          out.ID,
+		QuoteID:            in.QuoteID,
+		Status:             StatusInitialized,
+		Currency:           strings.ToUpper(out.FromAsset),
+		Amount:             out.Amount,
+		Fee:                out.Fees,
+		Rate:               out.ExchangeRate,
+		SettlementCurrency: out.SettlementCurrency,
+		SettlementAmount:   out.SettlementAmount,
+		Counterparty:       in.Country,
+		Description:        firstNonEmpty(in.PaymentReason, in.Reference),
+	}
+	if ok {
+		// Bitnob may omit amounts on initialize; fall back to the quote
+		tx.Currency = firstNonEmpty(tx.Currency, q.fromAsset)
+		tx.Amount = firstNonZero(tx.Amount, q.Amount)
+		tx.Rate = firstNonZero(tx.Rate, q.ExchangeRate)
+		tx.SettlementCurrency = firstNonEmpty(tx.SettlementCurrency, q.SettlementCurrency, q.toCurrency)
+		tx.SettlementAmount = firstNonZero(tx.SettlementAmount, q.SettlementAmount)
+	}
+	if existing, found := c.log.Find(KindPayout, in.QuoteID); found {
+		tx.ID, tx.CreatedAt = existing.ID, existing.CreatedAt
+	}
+	c.log.Record(tx)
+	return response, nil
+}
+
+func (c *Client) FinalizePayout(req interface{}) (interface{}, error) {
+	response, err := c.Upstream.FinalizePayout(req)
+	if err != nil {
+		return response, err
+	}
+
+	var in models.FinalizePayoutRequest
+	var out models.InitializePayoutResponse
+	models.Decode(req, &in)
+	models.Decode(response, &out)
+
+	status := firstNonEmpty(out.Status, "finalized")
+	now := time.Now().UTC()
+	if tx, ok := c.log.Find(KindPayout, in.QuoteID); ok {
+		c.log.Update(tx.ID, func(t *Transaction) {
+			t.Status = status
+			t.Reference = firstNonEmpty(t.Reference, out.ID)
+			t.FinalizedAt = &now
+		})
+	} else {
+		c.log.Record(Transaction{
+			Kind:        KindPayout,
+			Reference:   out.ID,
+			QuoteID:     in.QuoteID,
+			Status:      status,
+			Currency:    strings.ToUpper(out.FromAsset),
+			Amount:      out.Amount,
+			Fee:         out.Fees,
+			Rate:        out.ExchangeRate,
+			FinalizedAt: &now,
+		})
+	}
+
+	c.mu.Lock()
+	delete(c.quotes, in.QuoteID)
+	c.mu.Unlock()
+	return response, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func firstNonZero(values ...float64) float64 {
+	for _, v := range values {
+		if v != 0 {
+			return v
+		}
+	}
+	return 0
+}
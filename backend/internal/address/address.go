@@ -0,0 +1,108 @@
+package address
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Family groups chains that share an address format
+type Family string
+
+const (
+	FamilyBitcoin   Family = "bitcoin"
+	FamilyLightning Family = "lightning"
+	FamilyEVM       Family = "evm"
+	FamilyTron      Family = "tron"
+	FamilySolana    Family = "solana"
+)
+
+// chainFamilies maps the chain names accepted by the API onto address families
+var chainFamilies = map[string]Family{
+	"bitcoin":   FamilyBitcoin,
+	"btc":       FamilyBitcoin,
+	"lightning": FamilyLightning,
+	"ln":        FamilyLightning,
+	"ethereum":  FamilyEVM,
+	"eth":       FamilyEVM,
+	"erc20":     FamilyEVM,
+	"polygon":   FamilyEVM,
+	"matic":     FamilyEVM,
+	"bsc":       FamilyEVM,
+	"bep20":     FamilyEVM,
+	"base":      FamilyEVM,
+	"arbitrum":  FamilyEVM,
+	"optimism":  FamilyEVM,
+	"avalanche": FamilyEVM,
+	"celo":      FamilyEVM,
+	"tron":      FamilyTron,
+	"trx":       FamilyTron,
+	"trc20":     FamilyTron,
+	"solana":    FamilySolana,
+	"sol":       FamilySolana,
+	"spl":       FamilySolana,
+}
+
+// Result describes a successfully validated address
+type Result struct {
+	Family  Family   `json:"family"`
+	Type    string   `json:"type"`
+	Network Network  `json:"network,omitempty"`
+	Invoice *Invoice `json:"invoice,omitempty"`
+}
+
+// ChainFamily returns the address family for a chain name
+func ChainFamily(chain string) (Family, bool) {
+	family, ok := chainFamilies[strings.ToLower(strings.TrimSpace(chain))]
+	return family, ok
+}
+
+// Validate checks addr against the format required by chain. Bitcoin and
+// Lightning addresses must also belong to the given network.
+func Validate(chain, addr string, network Network) (*Result, error) {
+	addr = strings.TrimSpace(addr)
+	if addr == "" {
+		return nil, fmt.Errorf("address is required")
+	}
+
+	family, ok := ChainFamily(chain)
+	if !ok {
+		return nil, fmt.Errorf("unsupported chain %q", chain)
+	}
+
+	result, err := validateFamily(family, addr, network)
+	if err != nil {
+		if detected := Detect(addr, network); detected != "" && detected != family {
+			return nil, fmt.Errorf("%s address cannot be used on chain %s", detected, chain)
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Detect guesses the address family of addr, returning "" when nothing matches
+func Detect(addr string, network Network) Family {
+	for _, family := range []Family{FamilyEVM, FamilyLightning, FamilyBitcoin, FamilyTron, FamilySolana} {
+		if _, err := validateFamily(family, addr, network); err == nil {
+			return family
+		}
+	}
+	return ""
+}
+
+func validateFamily(family Family, addr string, network Network) (*Result, error) {
+	switch family {
+	case FamilyBitcoin:
+		return validateBitcoin(addr, network)
+	case FamilyLightning:
+		return validateLightning(addr, network)
+	case FamilyEVM:
+		return validateEVM(addr)
+	case FamilyTron:
+		return validateTron(addr)
+	case FamilySolana:
+		return validateSolana(addr)
+	default:
+		return nil, fmt.Errorf("unsupported address family %q", family)
+	}
+}
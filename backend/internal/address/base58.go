@@ -0,0 +1,71 @@
+package address
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var base58Index = func() [256]int {
+	var idx [256]int
+	for i := range idx {
+		idx[i] = -1
+	}
+	for i := 0; i < len(base58Alphabet); i++ {
+		idx[base58Alphabet[i]] = i
+	}
+	return idx
+}()
+
+var (
+	errInvalidBase58 = errors.New("invalid base58 character")
+	errChecksum      = errors.New("checksum mismatch")
+)
+
+// base58Decode decodes a Bitcoin-alphabet base58 string, preserving leading zero bytes
+func base58Decode(s string) ([]byte, error) {
+	if s == "" {
+		return nil, errInvalidBase58
+	}
+
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for i := 0; i < len(s); i++ {
+		v := base58Index[s[i]]
+		if v < 0 {
+			return nil, errInvalidBase58
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(v)))
+	}
+
+	zeros := 0
+	for zeros < len(s) && s[zeros] == '1' {
+		zeros++
+	}
+
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
+
+// base58CheckDecode decodes a base58check string and returns the version byte and payload
+func base58CheckDecode(s string) (byte, []byte, error) {
+	decoded, err := base58Decode(s)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(decoded) < 5 {
+		return 0, nil, errors.New("decoded value too short")
+	}
+
+	body, checksum := decoded[:len(decoded)-4], decoded[len(decoded)-4:]
+	first := sha256.Sum256(body)
+	second := sha256.Sum256(first[:])
+	if !bytes.Equal(second[:4], checksum) {
+		return 0, nil, errChecksum
+	}
+
+	return body[0], body[1:], nil
+}
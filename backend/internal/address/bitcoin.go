@@ -0,0 +1,132 @@
+package address
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Network identifies a Bitcoin network
+type Network string
+
+const (
+	Mainnet Network = "mainnet"
+	Testnet Network = "testnet"
+)
+
+// ParseNetwork maps a configured network name onto a Network, defaulting to mainnet
+func ParseNetwork(name string) Network {
+	switch strings.ToLower(name) {
+	case "testnet", "testnet3", "testnet4", "signet", "regtest", "sandbox":
+		return Testnet
+	default:
+		return Mainnet
+	}
+}
+
+var base58Versions = map[byte]struct {
+	network Network
+	kind    string
+}{
+	0x00: {Mainnet, "p2pkh"},
+	0x05: {Mainnet, "p2sh"},
+	0x6f: {Testnet, "p2pkh"},
+	0xc4: {Testnet, "p2sh"},
+}
+
+var segwitHRPs = map[string]Network{
+	"bc":   Mainnet,
+	"tb":   Testnet,
+	"bcrt": Testnet,
+}
+
+// validateBitcoin checks legacy base58check and segwit (bech32/bech32m) addresses
+func validateBitcoin(addr string, network Network) (*Result, error) {
+	if looksLikeSegwit(addr) {
+		return validateSegwit(addr, network)
+	}
+
+	version, payload, err := base58CheckDecode(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bitcoin address: %w", err)
+	}
+
+	info, ok := base58Versions[version]
+	if !ok {
+		return nil, fmt.Errorf("unknown bitcoin address version 0x%02x", version)
+	}
+	if len(payload) != 20 {
+		return nil, fmt.Errorf("invalid %s payload length %d", info.kind, len(payload))
+	}
+	if info.network != network {
+		return nil, fmt.Errorf("%s address cannot be used on %s", info.network, network)
+	}
+
+	return &Result{Family: FamilyBitcoin, Type: info.kind, Network: info.network}, nil
+}
+
+func looksLikeSegwit(addr string) bool {
+	lower := strings.ToLower(addr)
+	for hrp := range segwitHRPs {
+		if strings.HasPrefix(lower, hrp+"1") {
+			return true
+		}
+	}
+	return false
+}
+
+func validateSegwit(addr string, network Network) (*Result, error) {
+	hrp, data, encoding, err := bech32Decode(addr, 90)
+	if err != nil {
+		return nil, fmt.Errorf("invalid segwit address: %w", err)
+	}
+	addrNetwork, ok := segwitHRPs[hrp]
+	if !ok {
+		return nil, fmt.Errorf("unknown segwit prefix %q", hrp)
+	}
+	if len(data) < 1 {
+		return nil, fmt.Errorf("missing witness version")
+	}
+
+	version := data[0]
+	if version > 16 {
+		return nil, fmt.Errorf("invalid witness version %d", version)
+	}
+
+	program, err := convertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid witness program: %w", err)
+	}
+	if len(program) < 2 || len(program) > 40 {
+		return nil, fmt.Errorf("invalid witness program length %d", len(program))
+	}
+
+	var kind string
+	switch {
+	case version == 0:
+		if encoding != encodingBech32 {
+			return nil, fmt.Errorf("witness v0 address must use bech32")
+		}
+		switch len(program) {
+		case 20:
+			kind = "p2wpkh"
+		case 32:
+			kind = "p2wsh"
+		default:
+			return nil, fmt.Errorf("invalid witness v0 program length %d", len(program))
+		}
+	default:
+		if encoding != encodingBech32m {
+			return nil, fmt.Errorf("witness v%d address must use bech32m", version)
+		}
+		kind = fmt.Sprintf("witness_v%d", version)
+		if version == 1 && len(program) == 32 {
+			kind = "p2tr"
+		}
+	}
+
+	if addrNetwork != network {
+		return nil, fmt.Errorf("%s address cannot be used on %s", addrNetwork, network)
+	}
+
+	return &Result{Family: FamilyBitcoin, Type: kind, Network: addrNetwork}, nil
+}
@@ -0,0 +1,51 @@
+package address
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// validateEVM checks a 20-byte hex address and, when mixed case, its EIP-55 checksum
+func validateEVM(addr string) (*Result, error) {
+	if !strings.HasPrefix(addr, "0x") && !strings.HasPrefix(addr, "0X") {
+		return nil, errors.New("EVM address must start with 0x")
+	}
+	body := addr[2:]
+	if len(body) != 40 {
+		return nil, fmt.Errorf("EVM address must be 40 hex characters, got %d", len(body))
+	}
+	if _, err := hex.DecodeString(body); err != nil {
+		return nil, errors.New("EVM address contains non-hex characters")
+	}
+
+	kind := "eoa_or_contract"
+	if body != strings.ToLower(body) && body != strings.ToUpper(body) {
+		if ToChecksumAddress(body) != "0x"+body {
+			return nil, errors.New("EVM address fails EIP-55 checksum")
+		}
+		kind = "checksummed"
+	}
+
+	return &Result{Family: FamilyEVM, Type: kind}, nil
+}
+
+// ToChecksumAddress returns the EIP-55 mixed-case form of a hex address
+func ToChecksumAddress(addr string) string {
+	lower := strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(addr, "0x"), "0X"))
+
+	h := sha3.NewLegacyKeccak256()
+	h.Write([]byte(lower))
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	out := []byte(lower)
+	for i, ch := range out {
+		if ch >= 'a' && ch <= 'f' && hash[i] >= '8' {
+			out[i] = ch - 32
+		}
+	}
+	return "0x" + string(out)
+}
@@ -0,0 +1,213 @@
+package address
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultInvoiceExpiry is the BOLT11 expiry used when the invoice has no 'x' field
+const defaultInvoiceExpiry = 3600 * time.Second
+
+// signatureWords is the length of the recoverable signature in 5-bit words
+const signatureWords = 104
+
+var invoiceNetworks = []struct {
+	prefix  string
+	network Network
+}{
+	{"bcrt", Testnet},
+	{"tbs", Testnet},
+	{"bc", Mainnet},
+	{"tb", Testnet},
+}
+
+// Invoice holds the fields decoded from a BOLT11 Lightning invoice
+type Invoice struct {
+	Network     Network   `json:"network"`
+	AmountMsat  int64     `json:"amount_msat,omitempty"`
+	HasAmount   bool      `json:"has_amount"`
+	Timestamp   time.Time `json:"timestamp"`
+	Expiry      int64     `json:"expiry_seconds"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	PaymentHash string    `json:"payment_hash,omitempty"`
+	Description string    `json:"description,omitempty"`
+}
+
+// Expired reports whether the invoice is past its expiry at the given time
+func (inv *Invoice) Expired(now time.Time) bool {
+	return !now.Before(inv.ExpiresAt)
+}
+
+// DecodeInvoice decodes a BOLT11 invoice without verifying the node signature
+func DecodeInvoice(s string) (*Invoice, error) {
+	s = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "lightning:")
+
+	hrp, data, encoding, err := bech32Decode(s, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid invoice encoding: %w", err)
+	}
+	if encoding != encodingBech32 {
+		return nil, errors.New("invoice must use bech32 checksum")
+	}
+	if !strings.HasPrefix(hrp, "ln") {
+		return nil, errors.New("invoice prefix must start with ln")
+	}
+
+	inv := &Invoice{}
+	rest := hrp[2:]
+	matched := false
+	for _, n := range invoiceNetworks {
+		if strings.HasPrefix(rest, n.prefix) {
+			inv.Network = n.network
+			rest = rest[len(n.prefix):]
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return nil, fmt.Errorf("unknown invoice network in %q", hrp)
+	}
+
+	if rest != "" {
+		msat, err := parseInvoiceAmount(rest)
+		if err != nil {
+			return nil, err
+		}
+		inv.AmountMsat = msat
+		inv.HasAmount = true
+	}
+
+	if len(data) < 7+signatureWords {
+		return nil, errors.New("invoice too short")
+	}
+	fields := data[:len(data)-signatureWords]
+
+	inv.Timestamp = time.Unix(int64(wordsToUint(fields[:7])), 0).UTC()
+	inv.Expiry = int64(defaultInvoiceExpiry / time.Second)
+
+	for i := 7; i < len(fields); {
+		if i+3 > len(fields) {
+			return nil, errors.New("truncated tagged field")
+		}
+		tag := fields[i]
+		length := int(fields[i+1])<<5 | int(fields[i+2])
+		i += 3
+		if i+length > len(fields) {
+			return nil, errors.New("tagged field overruns invoice")
+		}
+		value := fields[i : i+length]
+		i += length
+
+		switch tag {
+		case 1: // p: payment hash
+			if length == 52 {
+				if b, err := convertBits(value, 5, 8, false); err == nil {
+					inv.PaymentHash = hex.EncodeToString(b)
+				}
+			}
+		case 6: // x: expiry
+			inv.Expiry = int64(wordsToUint(value))
+		case 13: // d: description
+			if b, err := convertBits(value, 5, 8, false); err == nil {
+				inv.Description = string(b)
+			}
+		}
+	}
+
+	if inv.PaymentHash == "" {
+		return nil, errors.New("invoice is missing a payment hash")
+	}
+
+	inv.ExpiresAt = inv.Timestamp.Add(time.Duration(inv.Expiry) * time.Second)
+	return inv, nil
+}
+
+// parseInvoiceAmount converts the BOLT11 amount (digits plus optional multiplier) to millisatoshis
+func parseInvoiceAmount(s string) (int64, error) {
+	multiplier := s[len(s)-1]
+	digits := s
+	if multiplier < '0' || multiplier > '9' {
+		digits = s[:len(s)-1]
+	} else {
+		multiplier = 0
+	}
+
+	amount, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || amount <= 0 || strings.HasPrefix(digits, "0") {
+		return 0, fmt.Errorf("invalid invoice amount %q", s)
+	}
+
+	// 1 BTC = 10^11 msat
+	msat := new(big.Int).Mul(big.NewInt(amount), big.NewInt(100_000_000_000))
+	var divisor int64
+	switch multiplier {
+	case 0:
+		divisor = 1
+	case 'm':
+		divisor = 1_000
+	case 'u':
+		divisor = 1_000_000
+	case 'n':
+		divisor = 1_000_000_000
+	case 'p':
+		divisor = 1_000_000_000_000
+	default:
+		return 0, fmt.Errorf("invalid invoice amount multiplier %q", multiplier)
+	}
+
+	q, r := new(big.Int).QuoRem(msat, big.NewInt(divisor), new(big.Int))
+	if r.Sign() != 0 {
+		return 0, fmt.Errorf("invoice amount %q is not a whole number of millisatoshis", s)
+	}
+	if !q.IsInt64() {
+		return 0, fmt.Errorf("invoice amount %q out of range", s)
+	}
+	return q.Int64(), nil
+}
+
+func wordsToUint(words []byte) uint64 {
+	var v uint64
+	for _, w := range words {
+		v = v<<5 | uint64(w)
+	}
+	return v
+}
+
+// validateLightning decodes a BOLT11 invoice and rejects expired or wrong-network invoices
+func validateLightning(invoice string, network Network) (*Result, error) {
+	inv, err := DecodeInvoice(invoice)
+	if err != nil {
+		return nil, err
+	}
+	if inv.Network != network {
+		return nil, fmt.Errorf("%s invoice cannot be paid on %s", inv.Network, network)
+	}
+	if inv.Expired(time.Now()) {
+		return nil, fmt.Errorf("invoice expired at %s", inv.ExpiresAt.Format(time.RFC3339))
+	}
+
+	return &Result{Family: FamilyLightning, Type: "bolt11", Network: inv.Network, Invoice: inv}, nil
+}
+
+// CheckInvoiceAmount verifies that a BTC amount matches a fixed-amount invoice.
+// Invoices without an amount, and non-BTC currencies, always pass.
+func CheckInvoiceAmount(inv *Invoice, amount, currency string) error {
+	if inv == nil || !inv.HasAmount || !strings.EqualFold(currency, "BTC") {
+		return nil
+	}
+
+	btc, err := strconv.ParseFloat(amount, 64)
+	if err != nil {
+		return fmt.Errorf("invalid amount %q", amount)
+	}
+	if sats := int64(math.Round(btc * 1e8)); sats != inv.AmountMsat/1000 {
+		return fmt.Errorf("amount %s BTC does not match invoice amount of %d sats", amount, inv.AmountMsat/1000)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package address
+
+import "fmt"
+
+// validateSolana checks that the address decodes to a 32-byte public key
+func validateSolana(addr string) (*Result, error) {
+	if len(addr) < 32 || len(addr) > 44 {
+		return nil, fmt.Errorf("invalid solana address length %d", len(addr))
+	}
+	key, err := base58Decode(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid solana address: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("solana address must decode to 32 bytes, got %d", len(key))
+	}
+
+	return &Result{Family: FamilySolana, Type: "pubkey"}, nil
+}
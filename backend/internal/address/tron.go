@@ -0,0 +1,22 @@
+package address
+
+import "fmt"
+
+// tronVersion is the address prefix byte for Tron mainnet accounts
+const tronVersion = 0x41
+
+// validateTron checks a base58check Tron address
+func validateTron(addr string) (*Result, error) {
+	version, payload, err := base58CheckDecode(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tron address: %w", err)
+	}
+	if version != tronVersion {
+		return nil, fmt.Errorf("invalid tron address prefix 0x%02x", version)
+	}
+	if len(payload) != 20 {
+		return nil, fmt.Errorf("invalid tron address length %d", len(payload))
+	}
+
+	return &Result{Family: FamilyTron, Type: "account"}, nil
+}
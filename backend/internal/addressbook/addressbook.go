@@ -0,0 +1,225 @@
+package addressbook
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/address"
+	"github.com/bitnob-api-demo/internal/audit"
+	"github.com/bitnob-api-demo/internal/store"
+)
+
+// auditResource is the resource name used for address book audit entries
+const auditResource = "address"
+
+var (
+	ErrDuplicate      = errors.New("address already exists in the address book")
+	ErrNotWhitelisted = errors.New("destination address is not in the address book")
+	ErrCoolingOff     = errors.New("destination address is still in its cooling-off period")
+	ErrWrongCurrency  = errors.New("destination address is not approved for this currency")
+)
+
+// Entry is a saved, pre-approved destination address
+type Entry struct {
+	ID         string    `json:"id"`
+	Label      string    `json:"label"`
+	Chain      string    `json:"chain"`
+	Currency   string    `json:"currency"`
+	Address    string    `json:"address"`
+	Owner      string    `json:"owner"`
+	CreatedBy  string    `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	UsableFrom time.Time `json:"usable_from"`
+}
+
+// Usable reports whether the entry has cleared its cooling-off period
+func (e Entry) Usable(now time.Time) bool {
+	return !now.Before(e.UsableFrom)
+}
+
+// Config controls address validation and whitelist enforcement
+type Config struct {
+	Network    address.Network
+	CoolingOff time.Duration
+	Whitelist  bool
+}
+
+// Book stores saved addresses and enforces the withdrawal whitelist
+type Book struct {
+	cfg     Config
+	entries *store.Table[Entry]
+	audit   *audit.Log
+
+	// writeMu makes the duplicate check and the write one step
+	writeMu sync.Mutex
+}
+
+// NewBook creates an empty address book
+func NewBook(cfg Config, auditLog *audit.Log) *Book {
+	return &Book{
+		cfg:     cfg,
+		entries: store.NewTable[Entry](),
+		audit:   auditLog,
+	}
+}
+
+// WhitelistEnabled reports whether transfers are restricted to saved addresses
+func (b *Book) WhitelistEnabled() bool {
+	return b.cfg.Whitelist
+}
+
+// Create validates and saves a new address
+func (b *Book) Create(entry Entry, actor string) (Entry, error) {
+	entry.Address = strings.TrimSpace(entry.Address)
+	if err := b.validate(entry); err != nil {
+		return Entry{}, err
+	}
+
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	if _, exists := b.Lookup(entry.Chain, entry.Address); exists {
+		return Entry{}, ErrDuplicate
+	}
+
+	now := time.Now().UTC()
+	entry.ID = store.NewID("addr")
+	entry.Chain = strings.ToLower(entry.Chain)
+	entry.Currency = strings.ToUpper(strings.TrimSpace(entry.Currency))
+	entry.CreatedBy = actor
+	entry.CreatedAt = now
+	entry.UpdatedAt = now
+	entry.UsableFrom = now.Add(b.cfg.CoolingOff)
+
+	b.entries.Put(entry.ID, entry)
+	b.audit.Record(actor, "create", auditResource, entry.ID, nil, entry)
+
+	return entry, nil
+}
+
+// Get returns a saved address by ID
+func (b *Book) Get(id string) (Entry, bool) {
+	return b.entries.Get(id)
+}
+
+// List returns saved addresses matching the non-empty filters
+func (b *Book) List(chain, currency, owner string) []Entry {
+	return b.entries.List(func(e Entry) bool {
+		return (chain == "" || strings.EqualFold(e.Chain, chain)) &&
+			(currency == "" || strings.EqualFold(e.Currency, currency)) &&
+			(owner == "" || e.Owner == owner)
+	})
+}
+
+// Update replaces the editable fields of an entry. Changing the chain,
+// address or currency restarts the cooling-off period.
+func (b *Book) Update(id string, changes Entry, actor string) (Entry, error) {
+	changes.Address = strings.TrimSpace(changes.Address)
+	if err := b.validate(changes); err != nil {
+		return Entry{}, err
+	}
+
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	if existing, exists := b.Lookup(changes.Chain, changes.Address); exists && existing.ID != id {
+		return Entry{}, ErrDuplicate
+	}
+
+	currency := strings.ToUpper(strings.TrimSpace(changes.Currency))
+	var before Entry
+	updated, err := b.entries.Update(id, func(e *Entry) error {
+		before = *e
+		now := time.Now().UTC()
+		// Clearing the currency allows every currency, so it counts as a change
+		if !strings.EqualFold(e.Chain, changes.Chain) || normalize(e.Chain, e.Address) != normalize(changes.Chain, changes.Address) ||
+			e.Currency != currency {
+			e.UsableFrom = now.Add(b.cfg.CoolingOff)
+		}
+		e.Label = changes.Label
+		e.Chain = strings.ToLower(changes.Chain)
+		e.Currency = currency
+		e.Address = changes.Address
+		e.Owner = changes.Owner
+		e.UpdatedAt = now
+		return nil
+	})
+	if err != nil {
+		return Entry{}, err
+	}
+
+	b.audit.Record(actor, "update", auditResource, id, before, updated)
+	return updated, nil
+}
+
+// Delete removes a saved address
+func (b *Book) Delete(id, actor string) error {
+	removed, ok := b.entries.Delete(id)
+	if !ok {
+		return store.ErrNotFound
+	}
+	b.audit.Record(actor, "delete", auditResource, id, removed, nil)
+	return nil
+}
+
+// Lookup finds a saved address on the given chain
+func (b *Book) Lookup(chain, addr string) (Entry, bool) {
+	key := normalize(chain, addr)
+	return b.entries.Find(func(e Entry) bool {
+		return strings.EqualFold(e.Chain, chain) && normalize(e.Chain, e.Address) == key
+	})
+}
+
+// Authorize checks that a transfer destination is whitelisted and usable.
+// It always succeeds when whitelist mode is disabled.
+func (b *Book) Authorize(chain, currency, addr string, now time.Time) (Entry, error) {
+	if !b.cfg.Whitelist {
+		return Entry{}, nil
+	}
+
+	entry, ok := b.Lookup(chain, addr)
+	if !ok {
+		return Entry{}, ErrNotWhitelisted
+	}
+	if entry.Currency != "" && !strings.EqualFold(entry.Currency, currency) {
+		return entry, ErrWrongCurrency
+	}
+	if !entry.Usable(now) {
+		return entry, fmt.Errorf("%w until %s", ErrCoolingOff, entry.UsableFrom.Format(time.RFC3339))
+	}
+
+	return entry, nil
+}
+
+// AuditTrail returns the change history for the address book or a single entry
+func (b *Book) AuditTrail(id string) []audit.Entry {
+	return b.audit.List(auditResource, id)
+}
+
+func (b *Book) validate(entry Entry) error {
+	if strings.TrimSpace(entry.Label) == "" {
+		return errors.New("label is required")
+	}
+	if _, err := address.Validate(entry.Chain, entry.Address, b.cfg.Network); err != nil {
+		return err
+	}
+	return nil
+}
+
+// normalize lowercases addresses whose encoding is case-insensitive
+func normalize(chain, addr string) string {
+	addr = strings.TrimSpace(addr)
+	family, _ := address.ChainFamily(chain)
+	switch family {
+	case address.FamilyEVM, address.FamilyLightning:
+		return strings.ToLower(addr)
+	case address.FamilyBitcoin:
+		lower := strings.ToLower(addr)
+		if strings.HasPrefix(lower, "bc1") || strings.HasPrefix(lower, "tb1") || strings.HasPrefix(lower, "bcrt1") {
+			return lower
+		}
+	}
+	return addr
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bitnob-api-demo/internal/addressbook"
+	"github.com/bitnob-api-demo/internal/models"
+	"github.com/bitnob-api-demo/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+type AddressBookHandler struct {
+	book *addressbook.Book
+}
+
+func NewAddressBookHandler(book *addressbook.Book) *AddressBookHandler {
+	return &AddressBookHandler{
+		book: book,
+	}
+}
+
+func (h *AddressBookHandler) CreateAddress(c *gin.Context) {
+	var req models.SavedAddressRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	entry, err := h.book.Create(entryFromRequest(req), actorFrom(c))
+	if err != nil {
+		h.writeError(c, "Failed to save address", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    entry,
+	})
+}
+
+func (h *AddressBookHandler) ListAddresses(c *gin.Context) {
+	entries := h.book.List(c.Query("chain"), c.Query("currency"), c.Query("owner"))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"data":      entries,
+		"whitelist": h.book.WhitelistEnabled(),
+	})
+}
+
+func (h *AddressBookHandler) GetAddress(c *gin.Context) {
+	entry, ok := h.book.Get(c.Param("id"))
+	if !ok {
+		h.writeError(c, "Failed to get address", store.ErrNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    entry,
+	})
+}
+
+func (h *AddressBookHandler) UpdateAddress(c *gin.Context) {
+	var req models.SavedAddressRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	entry, err := h.book.Update(c.Param("id"), entryFromRequest(req), actorFrom(c))
+	if err != nil {
+		h.writeError(c, "Failed to update address", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    entry,
+	})
+}
+
+func (h *AddressBookHandler) DeleteAddress(c *gin.Context) {
+	if err := h.book.Delete(c.Param("id"), actorFrom(c)); err != nil {
+		h.writeError(c, "Failed to delete address", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+	})
+}
+
+func (h *AddressBookHandler) GetAuditTrail(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.book.AuditTrail(c.Query("address_id")),
+	})
+}
+
+func (h *AddressBookHandler) writeError(c *gin.Context, message string, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, addressbook.ErrDuplicate):
+		status = http.StatusConflict
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"error":   message,
+		"details": err.Error(),
+	})
+}
+
+func entryFromRequest(req models.SavedAddressRequest) addressbook.Entry {
+	return addressbook.Entry{
+		Label:    req.Label,
+		Chain:    req.Chain,
+		Currency: req.Currency,
+		Address:  req.Address,
+		Owner:    req.Owner,
+	}
+}
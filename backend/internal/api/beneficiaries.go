@@ -0,0 +1,151 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bitnob-api-demo/internal/beneficiary"
+	"github.com/bitnob-api-demo/internal/models"
+	"github.com/bitnob-api-demo/internal/requirements"
+	"github.com/bitnob-api-demo/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+type BeneficiaryHandler struct {
+	beneficiaries *beneficiary.Store
+}
+
+func NewBeneficiaryHandler(beneficiaries *beneficiary.Store) *BeneficiaryHandler {
+	return &BeneficiaryHandler{
+		beneficiaries: beneficiaries,
+	}
+}
+
+func (h *BeneficiaryHandler) CreateBeneficiary(c *gin.Context) {
+	var req models.SavedBeneficiaryRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	b, err := h.beneficiaries.Create(req.Label, req.Country, req.CustomerID, req.Beneficiary, actorFrom(c))
+	if err != nil {
+		writeBeneficiaryError(c, "Failed to save beneficiary", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    b,
+	})
+}
+
+func (h *BeneficiaryHandler) ListBeneficiaries(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.beneficiaries.List(c.Query("country"), c.Query("customerId")),
+	})
+}
+
+func (h *BeneficiaryHandler) GetBeneficiary(c *gin.Context) {
+	b, ok := h.beneficiaries.Get(c.Param("id"))
+	if !ok {
+		writeBeneficiaryError(c, "Failed to get beneficiary", store.ErrNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    b,
+	})
+}
+
+func (h *BeneficiaryHandler) UpdateBeneficiary(c *gin.Context) {
+	var req models.SavedBeneficiaryRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	b, err := h.beneficiaries.Update(c.Param("id"), req.Label, req.Country, req.CustomerID, req.Beneficiary, actorFrom(c))
+	if err != nil {
+		writeBeneficiaryError(c, "Failed to update beneficiary", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    b,
+	})
+}
+
+func (h *BeneficiaryHandler) DeleteBeneficiary(c *gin.Context) {
+	if err := h.beneficiaries.Delete(c.Param("id"), actorFrom(c)); err != nil {
+		writeBeneficiaryError(c, "Failed to delete beneficiary", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+	})
+}
+
+func (h *BeneficiaryHandler) SetVerification(c *gin.Context) {
+	var req models.BeneficiaryVerificationRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	b, err := h.beneficiaries.SetVerification(c.Param("id"), req.Status, req.VerifiedName, actorFrom(c))
+	if err != nil {
+		writeBeneficiaryError(c, "Failed to update verification status", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    b,
+	})
+}
+
+func writeBeneficiaryError(c *gin.Context, message string, err error) {
+	var verr *requirements.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"success": false,
+			"error":   message,
+			"details": verr,
+		})
+		return
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   message,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"success": false,
+		"error":   message,
+		"details": err.Error(),
+	})
+}
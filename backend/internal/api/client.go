@@ -4,6 +4,7 @@ package api
 type BitnobClient interface {
 	// Transfer methods
 	CreateTransfer(req interface{}) (interface{}, error)
+	GetWalletBalances() (interface{}, error)
 
 	// Payout methods
 	CreatePayoutQuote(req interface{}) (interface{}, error)
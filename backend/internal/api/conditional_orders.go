@@ -0,0 +1,94 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bitnob-api-demo/internal/conditional"
+	"github.com/bitnob-api-demo/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+type ConditionalOrderHandler struct {
+	orders *conditional.Service
+}
+
+func NewConditionalOrderHandler(orders *conditional.Service) *ConditionalOrderHandler {
+	return &ConditionalOrderHandler{
+		orders: orders,
+	}
+}
+
+func (h *ConditionalOrderHandler) CreateOrder(c *gin.Context) {
+	var req conditional.Request
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// OCO requests return both linked legs
+	orders, err := h.orders.Create(req, actorFrom(c))
+	if err != nil {
+		writeConditionalError(c, "Failed to create conditional order", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    orders,
+	})
+}
+
+func (h *ConditionalOrderHandler) ListOrders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.orders.List(c.Query("status")),
+	})
+}
+
+func (h *ConditionalOrderHandler) GetOrder(c *gin.Context) {
+	order, ok := h.orders.Get(c.Param("id"))
+	if !ok {
+		writeConditionalError(c, "Failed to get conditional order", store.ErrNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    order,
+	})
+}
+
+func (h *ConditionalOrderHandler) CancelOrder(c *gin.Context) {
+	order, err := h.orders.Cancel(c.Param("id"), actorFrom(c))
+	if err != nil {
+		writeConditionalError(c, "Failed to cancel conditional order", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    order,
+	})
+}
+
+func writeConditionalError(c *gin.Context, message string, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, conditional.ErrNotOpen):
+		status = http.StatusConflict
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"error":   message,
+		"details": err.Error(),
+	})
+}
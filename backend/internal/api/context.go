@@ -0,0 +1,21 @@
+package api
+
+import (
+	"github.com/bitnob-api-demo/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// actorHeader identifies the operator making a change, for audit purposes
+const actorHeader = "X-Actor"
+
+// actorFrom returns the operator identity for the request: the operator an
+// admin key authenticated, or else the self-declared actor header
+func actorFrom(c *gin.Context) string {
+	if actor := c.GetString(middleware.ActorKey); actor != "" {
+		return actor
+	}
+	if actor := c.GetHeader(actorHeader); actor != "" {
+		return actor
+	}
+	return "anonymous"
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/bitnob-api-demo/internal/audit"
+	"github.com/bitnob-api-demo/internal/bitnob"
+	"github.com/bitnob-api-demo/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// credentialsResource names key rotations in the audit log
+const credentialsResource = "bitnob_credentials"
+
+type CredentialHandler struct {
+	bitnob *bitnob.Client
+	audit  *audit.Log
+}
+
+func NewCredentialHandler(client *bitnob.Client, auditLog *audit.Log) *CredentialHandler {
+	return &CredentialHandler{
+		bitnob: client,
+		audit:  auditLog,
+	}
+}
+
+// GetCredentials returns the primary and secondary key pairs, without their
+// secrets, and how many calls each pair has signed
+func (h *CredentialHandler) GetCredentials(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.bitnob.Keys(),
+	})
+}
+
+// StageSecondary adds the new key pair of a rotation. Calls keep signing
+// with the primary pair and fall back to this one when Bitnob rejects it.
+func (h *CredentialHandler) StageSecondary(c *gin.Context) {
+	var req models.StageCredentialsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	before := h.bitnob.Keys().Secondary
+	h.bitnob.SetSecondary(strings.TrimSpace(req.ClientID), strings.TrimSpace(req.ClientSecret))
+	keys := h.bitnob.Keys()
+	h.audit.Record(actorFrom(c), "stage", credentialsResource, req.ClientID, before, keys.Secondary)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    keys,
+	})
+}
+
+func (h *CredentialHandler) DeleteSecondary(c *gin.Context) {
+	removed, err := h.bitnob.ClearSecondary()
+	if err != nil {
+		writeCredentialError(c, "Failed to remove secondary credentials", err)
+		return
+	}
+	h.audit.Record(actorFrom(c), "delete", credentialsResource, removed.ClientID, removed, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.bitnob.Keys(),
+	})
+}
+
+// Promote makes the secondary pair primary and retires the old primary
+// pair, completing a rotation
+func (h *CredentialHandler) Promote(c *gin.Context) {
+	retired, err := h.bitnob.Promote()
+	if err != nil {
+		writeCredentialError(c, "Failed to promote secondary credentials", err)
+		return
+	}
+	keys := h.bitnob.Keys()
+	h.audit.Record(actorFrom(c), "promote", credentialsResource, keys.Primary.ClientID, retired, keys.Primary)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"retired": retired,
+			"keys":    keys,
+		},
+	})
+}
+
+func writeCredentialError(c *gin.Context, message string, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, bitnob.ErrNoSecondary) {
+		status = http.StatusConflict
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"error":   message,
+		"details": err.Error(),
+	})
+}
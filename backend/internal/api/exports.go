@@ -0,0 +1,91 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/exports"
+	"github.com/gin-gonic/gin"
+)
+
+type ExportHandler struct {
+	exports *exports.Service
+}
+
+func NewExportHandler(service *exports.Service) *ExportHandler {
+	return &ExportHandler{
+		exports: service,
+	}
+}
+
+// GetExport exports transactions created in [?from, ?to), optionally limited
+// to ?type=transfer,payout,trade. ?format=csv|journal|ofx returns a
+// download; without a format the entries are returned as JSON.
+func (h *ExportHandler) GetExport(c *gin.Context) {
+	from, err := parseTimeQuery(c, "from")
+	var to time.Time
+	if err == nil {
+		to, err = parseTimeQuery(c, "to")
+	}
+	var kinds map[string]bool
+	if err == nil {
+		kinds, err = exports.ParseKinds(c.Query("type"))
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid query",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	entries := h.exports.Entries(from, to, kinds)
+
+	var buf bytes.Buffer
+	contentType, ext := "text/csv", "csv"
+	switch format := c.Query("format"); format {
+	case "":
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    entries,
+		})
+		return
+	case exports.FormatCSV:
+		err = h.exports.WriteCSV(&buf, entries)
+	case exports.FormatJournal:
+		err = h.exports.WriteJournal(&buf, entries)
+	case exports.FormatOFX:
+		contentType, ext = "application/x-ofx", "ofx"
+		err = h.exports.WriteOFX(&buf, entries, from, to)
+	default:
+		err = exports.ErrInvalidFormat
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, exports.ErrInvalidFormat) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   "Failed to export transactions",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("bitnob-%s-%s.%s", c.Query("format"), time.Now().UTC().Format("20060102"), ext)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// GetAccounts returns the chart-of-accounts mapping used by journal and OFX exports
+func (h *ExportHandler) GetAccounts(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.exports.Chart().Rules(),
+	})
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bitnob-api-demo/internal/ledger"
+	"github.com/gin-gonic/gin"
+)
+
+type LedgerHandler struct {
+	ledger *ledger.Ledger
+}
+
+func NewLedgerHandler(l *ledger.Ledger) *LedgerHandler {
+	return &LedgerHandler{
+		ledger: l,
+	}
+}
+
+// GetCustomer returns a customer's balances and holds in every asset
+func (h *LedgerHandler) GetCustomer(c *gin.Context) {
+	id := c.Param("id")
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"customer_id": id,
+			"balances":    h.ledger.Balances(id),
+			"holds":       h.ledger.Holds(id, ledger.HoldActive),
+		},
+	})
+}
+
+// CreateDeposit credits a customer with funds received into the pooled account
+func (h *LedgerHandler) CreateDeposit(c *gin.Context) {
+	var req ledger.Deposit
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	entry, err := h.ledger.Deposit(c.Param("id"), req, actorFrom(c))
+	if err != nil {
+		writeLedgerError(c, "Failed to record deposit", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    entry,
+	})
+}
+
+// ListHolds returns a customer's holds, optionally filtered by ?status
+func (h *LedgerHandler) ListHolds(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.ledger.Holds(c.Param("id"), c.Query("status")),
+	})
+}
+
+// ListEntries returns journal entries filtered by ?customer and ?asset
+func (h *LedgerHandler) ListEntries(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.ledger.Entries(c.Query("customer"), c.Query("asset")),
+	})
+}
+
+// ListAccounts returns the trial balance of every ledger account
+func (h *LedgerHandler) ListAccounts(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.ledger.Accounts(),
+	})
+}
+
+func writeLedgerError(c *gin.Context, message string, err error) {
+	status := http.StatusBadRequest
+	var details interface{} = err.Error()
+	var fundsErr *ledger.InsufficientFundsError
+	switch {
+	case errors.As(err, &fundsErr):
+		status = http.StatusUnprocessableEntity
+		details = fundsErr
+	case errors.Is(err, ledger.ErrNoHold):
+		status = http.StatusConflict
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"error":   message,
+		"details": details,
+	})
+}
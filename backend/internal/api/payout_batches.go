@@ -0,0 +1,138 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"github.com/bitnob-api-demo/internal/batch"
+	"github.com/bitnob-api-demo/internal/models"
+	"github.com/bitnob-api-demo/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+type PayoutBatchHandler struct {
+	batches *batch.PayoutService
+}
+
+func NewPayoutBatchHandler(batches *batch.PayoutService) *PayoutBatchHandler {
+	return &PayoutBatchHandler{
+		batches: batches,
+	}
+}
+
+func (h *PayoutBatchHandler) CreateBatch(c *gin.Context) {
+	data, filename, contentType, err := readUpload(c)
+	if err != nil {
+		writeUploadError(c, "Invalid upload", err)
+		return
+	}
+
+	rows, err := batch.ParsePayoutRows(data, batch.DetectFormat(filename, contentType, data))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid upload",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	b, err := h.batches.Create(rows, actorFrom(c))
+	if err != nil {
+		writeBatchError(c, "Failed to create payout batch", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    b,
+	})
+}
+
+func (h *PayoutBatchHandler) ListBatches(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.batches.List(),
+	})
+}
+
+func (h *PayoutBatchHandler) GetBatch(c *gin.Context) {
+	b, ok := h.batches.Get(c.Param("id"))
+	if !ok {
+		writeBatchError(c, "Failed to get payout batch", store.ErrNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    b,
+	})
+}
+
+func (h *PayoutBatchHandler) ConfirmBatch(c *gin.Context) {
+	var req models.BatchConfirmRequest
+
+	// The body is optional; an empty body confirms without skipping invalid rows
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid request",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	b, err := h.batches.Confirm(c.Param("id"), req.SkipInvalid)
+	if err != nil {
+		writeBatchError(c, "Failed to confirm payout batch", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"data":    b,
+	})
+}
+
+func (h *PayoutBatchHandler) RetryBatch(c *gin.Context) {
+	b, err := h.batches.Retry(c.Param("id"))
+	if err != nil {
+		writeBatchError(c, "Failed to retry payout batch", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"data":    b,
+	})
+}
+
+func (h *PayoutBatchHandler) DownloadResults(c *gin.Context) {
+	var buf bytes.Buffer
+	if err := h.batches.WriteResultsCSV(c.Param("id"), &buf); err != nil {
+		writeBatchError(c, "Failed to export payout batch", err)
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+c.Param("id")+`-results.csv"`)
+	c.Data(http.StatusOK, "text/csv", buf.Bytes())
+}
+
+func writeBatchError(c *gin.Context, message string, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, batch.ErrNotDryRun), errors.Is(err, batch.ErrNotRetryable), errors.Is(err, batch.ErrInvalidRows):
+		status = http.StatusConflict
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"error":   message,
+		"details": err.Error(),
+	})
+}
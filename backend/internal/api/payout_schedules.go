@@ -0,0 +1,153 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bitnob-api-demo/internal/scheduler"
+	"github.com/bitnob-api-demo/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+type PayoutScheduleHandler struct {
+	schedules *scheduler.Service
+}
+
+func NewPayoutScheduleHandler(schedules *scheduler.Service) *PayoutScheduleHandler {
+	return &PayoutScheduleHandler{
+		schedules: schedules,
+	}
+}
+
+func (h *PayoutScheduleHandler) CreateSchedule(c *gin.Context) {
+	var req scheduler.Definition
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	ps, err := h.schedules.Create(req, actorFrom(c))
+	if err != nil {
+		writeScheduleError(c, "Failed to create payout schedule", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    ps,
+	})
+}
+
+func (h *PayoutScheduleHandler) ListSchedules(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.schedules.List(),
+	})
+}
+
+func (h *PayoutScheduleHandler) GetSchedule(c *gin.Context) {
+	ps, ok := h.schedules.Get(c.Param("id"))
+	if !ok {
+		writeScheduleError(c, "Failed to get payout schedule", store.ErrNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    ps,
+	})
+}
+
+func (h *PayoutScheduleHandler) UpdateSchedule(c *gin.Context) {
+	var req scheduler.Definition
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	ps, err := h.schedules.Update(c.Param("id"), req, actorFrom(c))
+	if err != nil {
+		writeScheduleError(c, "Failed to update payout schedule", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    ps,
+	})
+}
+
+func (h *PayoutScheduleHandler) DeleteSchedule(c *gin.Context) {
+	if err := h.schedules.Delete(c.Param("id"), actorFrom(c)); err != nil {
+		writeScheduleError(c, "Failed to delete payout schedule", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+	})
+}
+
+func (h *PayoutScheduleHandler) PauseSchedule(c *gin.Context) {
+	ps, err := h.schedules.Pause(c.Param("id"), actorFrom(c))
+	if err != nil {
+		writeScheduleError(c, "Failed to pause payout schedule", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    ps,
+	})
+}
+
+func (h *PayoutScheduleHandler) ResumeSchedule(c *gin.Context) {
+	ps, err := h.schedules.Resume(c.Param("id"), actorFrom(c))
+	if err != nil {
+		writeScheduleError(c, "Failed to resume payout schedule", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    ps,
+	})
+}
+
+func (h *PayoutScheduleHandler) ListRuns(c *gin.Context) {
+	if _, ok := h.schedules.Get(c.Param("id")); !ok {
+		writeScheduleError(c, "Failed to list payout schedule runs", store.ErrNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.schedules.Runs(c.Param("id")),
+	})
+}
+
+func writeScheduleError(c *gin.Context, message string, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, scheduler.ErrInvalidState):
+		status = http.StatusConflict
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"error":   message,
+		"details": err.Error(),
+	})
+}
@@ -1,26 +1,42 @@
 package api
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"time"
 
+	"github.com/bitnob-api-demo/internal/beneficiary"
+	"github.com/bitnob-api-demo/internal/events"
+	"github.com/bitnob-api-demo/internal/ledger"
 	"github.com/bitnob-api-demo/internal/models"
+	"github.com/bitnob-api-demo/internal/quotes"
+	"github.com/bitnob-api-demo/internal/requirements"
 	"github.com/gin-gonic/gin"
 )
 
 type PayoutHandler struct {
-	bitnobClient BitnobClient
+	bitnobClient  BitnobClient
+	beneficiaries *beneficiary.Store
+	requirements  *requirements.Cache
+	quotes        *quotes.Book
+	events        *events.Broker
 }
 
-func NewPayoutHandler(client BitnobClient) *PayoutHandler {
+func NewPayoutHandler(client BitnobClient, beneficiaries *beneficiary.Store, reqCache *requirements.Cache, quoteBook *quotes.Book, broker *events.Broker) *PayoutHandler {
 	return &PayoutHandler{
-		bitnobClient: client,
+		bitnobClient:  client,
+		beneficiaries: beneficiaries,
+		requirements:  reqCache,
+		quotes:        quoteBook,
+		events:        broker,
 	}
 }
 
 func (h *PayoutHandler) CreateQuote(c *gin.Context) {
-	var req models.PayoutQuoteRequest
+	var input models.PayoutQuoteInput
 
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"error":   "Invalid request",
@@ -28,6 +44,24 @@ func (h *PayoutHandler) CreateQuote(c *gin.Context) {
 		})
 		return
 	}
+	req := input.PayoutQuoteRequest
+	req.Country = input.Country
+
+	// Quotes are refused while the limits cannot be checked
+	limits, err := h.requirements.Limits()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"error":   "Transaction limits unavailable",
+			"details": err.Error(),
+		})
+		return
+	}
+	err = requirements.CheckQuote(limits, req)
+	if err != nil && !errors.Is(err, requirements.ErrUnconverted) {
+		writeLimitError(c, err)
+		return
+	}
 
 	response, err := h.bitnobClient.CreatePayoutQuote(req)
 	if err != nil {
@@ -39,6 +73,22 @@ func (h *PayoutHandler) CreateQuote(c *gin.Context) {
 		return
 	}
 
+	// Amounts in other assets are checked at the quoted rate
+	var quote models.PayoutQuoteResponse
+	if err := models.Decode(response, &quote); err != nil {
+		writeLimitError(c, err)
+		return
+	}
+	if err := requirements.CheckQuoted(limits, req, quote); err != nil {
+		writeLimitError(c, err)
+		return
+	}
+
+	// Remember the quote so initialize and finalize can check its expiry
+	if _, err := h.quotes.RecordPayout(req, response); err != nil {
+		log.Printf("Failed to record payout quote: %v", err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    response,
@@ -57,7 +107,34 @@ func (h *PayoutHandler) InitializePayout(c *gin.Context) {
 		return
 	}
 
+	if err := h.quotes.Check(req.QuoteID, time.Now()); err != nil {
+		writeQuoteError(c, err)
+		return
+	}
+
+	// Expand a saved beneficiary into inline details before calling Bitnob
+	if req.SavedBeneficiaryID != "" {
+		details, err := h.beneficiaries.Details(req.SavedBeneficiaryID, req.Country)
+		if err != nil {
+			writeBeneficiaryError(c, "Invalid saved beneficiary", err)
+			return
+		}
+		req.Beneficiary = details
+		req.SavedBeneficiaryID = ""
+	}
+
+	if req.Beneficiary != nil {
+		if err := h.validateBeneficiary(req.Country, *req.Beneficiary); err != nil {
+			writeBeneficiaryError(c, "Invalid beneficiary details", err)
+			return
+		}
+	}
+
 	response, err := h.bitnobClient.InitializePayout(req)
+	if ledger.Rejected(err) {
+		writeLedgerError(c, "Payout rejected by ledger", err)
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -66,6 +143,7 @@ func (h *PayoutHandler) InitializePayout(c *gin.Context) {
 		})
 		return
 	}
+	h.events.Publish("payout:"+req.QuoteID, "payout.initialized", response)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -85,8 +163,19 @@ func (h *PayoutHandler) FinalizePayout(c *gin.Context) {
 		return
 	}
 
+	// Finalizing consumes the quote; reject reuse before calling Bitnob
+	if err := h.quotes.Claim(req.QuoteID, time.Now()); err != nil {
+		writeQuoteError(c, err)
+		return
+	}
+
 	response, err := h.bitnobClient.FinalizePayout(req)
 	if err != nil {
+		h.quotes.Release(req.QuoteID)
+		if ledger.Rejected(err) {
+			writeLedgerError(c, "Payout rejected by ledger", err)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"error":   "Failed to finalize payout",
@@ -95,6 +184,12 @@ func (h *PayoutHandler) FinalizePayout(c *gin.Context) {
 		return
 	}
 
+	var payout models.InitializePayoutResponse
+	if err := models.Decode(response, &payout); err == nil {
+		h.quotes.Complete(req.QuoteID, payout.ID)
+	}
+	h.events.Publish("payout:"+req.QuoteID, "payout.finalized", response)
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    response,
@@ -104,7 +199,7 @@ func (h *PayoutHandler) FinalizePayout(c *gin.Context) {
 func (h *PayoutHandler) GetCountryRequirements(c *gin.Context) {
 	country := c.Param("country")
 
-	response, err := h.bitnobClient.GetCountryRequirements(country)
+	response, err := h.requirements.GetCountryRequirements(country)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -114,14 +209,20 @@ func (h *PayoutHandler) GetCountryRequirements(c *gin.Context) {
 		return
 	}
 
+	schema, err := h.requirements.Schema(country)
+	if err != nil {
+		log.Printf("Failed to parse requirements schema for %s: %v", country, err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    response,
+		"schema":  schema,
 	})
 }
 
 func (h *PayoutHandler) GetTransactionLimits(c *gin.Context) {
-	response, err := h.bitnobClient.GetTransactionLimits()
+	response, err := h.requirements.GetTransactionLimits()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -136,3 +237,34 @@ func (h *PayoutHandler) GetTransactionLimits(c *gin.Context) {
 		"data":    response,
 	})
 }
+
+// validateBeneficiary checks details against the cached country schema. If the
+// schema cannot be loaded the payout proceeds and Bitnob validates upstream.
+func (h *PayoutHandler) validateBeneficiary(country string, details models.BeneficiaryDetails) error {
+	schema, err := h.requirements.Schema(country)
+	if err != nil {
+		log.Printf("Skipping beneficiary validation for %s: %v", country, err)
+		return nil
+	}
+	return schema.Validate(details)
+}
+
+// writeLimitError rejects a quote outside the transaction limits, or one
+// whose amount could not be checked against them
+func writeLimitError(c *gin.Context, err error) {
+	var limitErr *requirements.LimitError
+	if errors.As(err, &limitErr) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"success": false,
+			"error":   "Amount outside transaction limits",
+			"details": limitErr,
+		})
+		return
+	}
+
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"success": false,
+		"error":   "Failed to check transaction limits",
+		"details": err.Error(),
+	})
+}
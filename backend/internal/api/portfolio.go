@@ -0,0 +1,146 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/portfolio"
+	"github.com/gin-gonic/gin"
+)
+
+type PortfolioHandler struct {
+	portfolio *portfolio.Service
+}
+
+func NewPortfolioHandler(service *portfolio.Service) *PortfolioHandler {
+	return &PortfolioHandler{
+		portfolio: service,
+	}
+}
+
+// GetPortfolio values positions with ?method=fifo|lifo|average, defaulting
+// to the configured cost basis method
+func (h *PortfolioHandler) GetPortfolio(c *gin.Context) {
+	p, err := h.portfolio.Value(c.DefaultQuery("method", h.portfolio.Method()))
+	if err != nil {
+		writePortfolioError(c, "Failed to value portfolio", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    p,
+	})
+}
+
+// ListSnapshots returns the valuation time series, optionally bounded by
+// ?from and ?to
+func (h *PortfolioHandler) ListSnapshots(c *gin.Context) {
+	from, err := parseTimeQuery(c, "from")
+	var to time.Time
+	if err == nil {
+		to, err = parseTimeQuery(c, "to")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid query",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.portfolio.Snapshots(from, to),
+	})
+}
+
+// CreateSnapshot values the portfolio now and appends it to the time series
+func (h *PortfolioHandler) CreateSnapshot(c *gin.Context) {
+	p, err := h.portfolio.Snapshot()
+	if err != nil {
+		writePortfolioError(c, "Failed to snapshot portfolio", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    p,
+	})
+}
+
+// GetTaxReport returns the capital gains realized in ?year (default the
+// current year) in ?currency, as JSON or with ?format=csv or ?format=8949
+// as a CSV download
+func (h *PortfolioHandler) GetTaxReport(c *gin.Context) {
+	year := time.Now().UTC().Year()
+	if value := c.Query("year"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1970 || parsed > 9999 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid query",
+				"details": "year must be a four digit year",
+			})
+			return
+		}
+		year = parsed
+	}
+
+	report, err := h.portfolio.TaxReport(
+		year,
+		c.DefaultQuery("method", h.portfolio.Method()),
+		c.DefaultQuery("currency", h.portfolio.Currency()),
+	)
+	if err != nil {
+		writePortfolioError(c, "Failed to build tax report", err)
+		return
+	}
+
+	var buf bytes.Buffer
+	switch format := c.DefaultQuery("format", "json"); format {
+	case "json":
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    report,
+		})
+		return
+	case "csv":
+		err = report.WriteCSV(&buf)
+	case "8949":
+		err = report.WriteForm8949(&buf)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid query",
+			"details": "format must be json, csv or 8949",
+		})
+		return
+	}
+	if err != nil {
+		writePortfolioError(c, "Failed to export tax report", err)
+		return
+	}
+
+	filename := fmt.Sprintf("capital-gains-%d-%s.csv", year, c.Query("format"))
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, "text/csv", buf.Bytes())
+}
+
+func writePortfolioError(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, portfolio.ErrInvalidMethod) || errors.Is(err, portfolio.ErrNoRate) {
+		status = http.StatusBadRequest
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"error":   message,
+		"details": err.Error(),
+	})
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/quotes"
+	"github.com/gin-gonic/gin"
+)
+
+type QuoteHandler struct {
+	quotes *quotes.Book
+}
+
+func NewQuoteHandler(quoteBook *quotes.Book) *QuoteHandler {
+	return &QuoteHandler{
+		quotes: quoteBook,
+	}
+}
+
+func (h *QuoteHandler) GetQuote(c *gin.Context) {
+	q, ok := h.quotes.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "Quote not found",
+			"details": quotes.ErrUnknownQuote.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    q.View(time.Now()),
+	})
+}
+
+func writeQuoteError(c *gin.Context, err error) {
+	var moved *quotes.PriceMovedError
+	switch {
+	case errors.As(err, &moved):
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"error":   "Price moved",
+			"details": moved.Requote,
+		})
+	case errors.Is(err, quotes.ErrExpired):
+		c.JSON(http.StatusGone, gin.H{
+			"success": false,
+			"error":   "Quote expired",
+			"details": err.Error(),
+		})
+	case errors.Is(err, quotes.ErrUsed):
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"error":   "Quote already used",
+			"details": err.Error(),
+		})
+	case errors.Is(err, quotes.ErrUnknownQuote):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Unknown quote",
+			"details": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to requote",
+			"details": err.Error(),
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bitnob-api-demo/internal/reconcile"
+	"github.com/bitnob-api-demo/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+type ReconciliationHandler struct {
+	reconcile *reconcile.Service
+}
+
+func NewReconciliationHandler(service *reconcile.Service) *ReconciliationHandler {
+	return &ReconciliationHandler{
+		reconcile: service,
+	}
+}
+
+// GetReport returns the latest run and the discrepancies, optionally
+// filtered by ?state and ?kind
+func (h *ReconciliationHandler) GetReport(c *gin.Context) {
+	discrepancies := h.reconcile.List(c.Query("state"), c.Query("kind"))
+	summary := map[string]int{}
+	for _, d := range h.reconcile.List("", "") {
+		summary[d.State]++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"last_run":      h.reconcile.LastRun(),
+			"summary":       summary,
+			"discrepancies": discrepancies,
+		},
+	})
+}
+
+func (h *ReconciliationHandler) ListRuns(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.reconcile.Runs(),
+	})
+}
+
+// CreateRun reconciles immediately instead of waiting for the schedule
+func (h *ReconciliationHandler) CreateRun(c *gin.Context) {
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    h.reconcile.Run(),
+	})
+}
+
+func (h *ReconciliationHandler) GetDiscrepancy(c *gin.Context) {
+	d, ok := h.reconcile.Get(c.Param("id"))
+	if !ok {
+		writeReconciliationError(c, "Failed to get discrepancy", store.ErrNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    d,
+	})
+}
+
+func (h *ReconciliationHandler) AcknowledgeDiscrepancy(c *gin.Context) {
+	var req reconcile.Resolution
+
+	// The body is optional; it only carries a note and resolution options
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid request",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	d, err := h.reconcile.Acknowledge(c.Param("id"), req.Note, actorFrom(c))
+	if err != nil {
+		writeReconciliationError(c, "Failed to acknowledge discrepancy", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    d,
+	})
+}
+
+func (h *ReconciliationHandler) ResolveDiscrepancy(c *gin.Context) {
+	var req reconcile.Resolution
+
+	// The body is optional; it only carries a note and resolution options
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid request",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	d, err := h.reconcile.Resolve(c.Param("id"), req, actorFrom(c))
+	if err != nil {
+		writeReconciliationError(c, "Failed to resolve discrepancy", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    d,
+	})
+}
+
+func writeReconciliationError(c *gin.Context, message string, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, reconcile.ErrInvalidState):
+		status = http.StatusConflict
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"error":   message,
+		"details": err.Error(),
+	})
+}
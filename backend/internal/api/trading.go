@@ -1,19 +1,37 @@
 package api
 
 import (
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/bitnob-api-demo/internal/events"
 	"github.com/bitnob-api-demo/internal/models"
+	"github.com/bitnob-api-demo/internal/orders"
+	"github.com/bitnob-api-demo/internal/quotes"
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
 )
 
+// streamHeartbeat is how often an idle event stream sends a keep-alive comment
+const streamHeartbeat = 15 * time.Second
+
 type TradingHandler struct {
 	bitnobClient BitnobClient
+	quotes       *quotes.Book
+	orders       *orders.Store
+	events       *events.Broker
 }
 
-func NewTradingHandler(client BitnobClient) *TradingHandler {
+func NewTradingHandler(client BitnobClient, quoteBook *quotes.Book, orderStore *orders.Store, broker *events.Broker) *TradingHandler {
 	return &TradingHandler{
 		bitnobClient: client,
+		quotes:       quoteBook,
+		orders:       orderStore,
+		events:       broker,
 	}
 }
 
@@ -39,6 +57,11 @@ func (h *TradingHandler) CreateQuote(c *gin.Context) {
 		return
 	}
 
+	// Remember the quote so orders can check its expiry later
+	if _, err := h.quotes.RecordTrading(req, response); err != nil {
+		log.Printf("Failed to record trading quote: %v", err)
+	}
+
 	// Return quote details at the top level for frontend compatibility
 	c.JSON(http.StatusOK, response)
 }
@@ -55,8 +78,36 @@ func (h *TradingHandler) CreateOrder(c *gin.Context) {
 		return
 	}
 
+	// With ?requote=true an expired quote is replaced transparently, as long
+	// as the new price is within max_slippage_bps of the order price
+	var requote *quotes.Requote
+	if c.Query("requote") == "true" {
+		maxSlippage, err := strconv.ParseFloat(c.DefaultQuery("max_slippage_bps", "0"), 64)
+		if err != nil || maxSlippage < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid request",
+				"details": "max_slippage_bps must be a non-negative number",
+			})
+			return
+		}
+
+		requote, err = h.quotes.Refresh(h.bitnobClient, &req, maxSlippage, time.Now())
+		if err != nil {
+			writeQuoteError(c, err)
+			return
+		}
+	}
+
+	// Reject expired or already used quotes before calling Bitnob
+	if err := h.quotes.Claim(req.QuoteID, time.Now()); err != nil {
+		writeQuoteError(c, err)
+		return
+	}
+
 	response, err := h.bitnobClient.CreateOrder(req)
 	if err != nil {
+		h.quotes.Release(req.QuoteID)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"error":   "Failed to create order",
@@ -65,22 +116,69 @@ func (h *TradingHandler) CreateOrder(c *gin.Context) {
 		return
 	}
 
-	// Return order details at the top level for frontend compatibility  
+	if order, err := h.orders.Record(response); err == nil {
+		h.quotes.Complete(req.QuoteID, order.ID)
+	} else {
+		log.Printf("Failed to record order: %v", err)
+	}
+
+	if requote != nil {
+		c.Header("X-Requoted", "true")
+		if body, ok := response.(map[string]interface{}); ok {
+			body["requote"] = requote
+		}
+	}
+
+	// Return order details at the top level for frontend compatibility
 	c.JSON(http.StatusOK, response)
 }
 
+// GetOrders serves the local orders table, which is synced from Bitnob in
+// the background. Filters: pair, side, status, from, to; paging: sort, limit, cursor.
 func (h *TradingHandler) GetOrders(c *gin.Context) {
-	response, err := h.bitnobClient.GetOrders()
+	filter := orders.Filter{
+		Pair:   c.Query("pair"),
+		Side:   c.Query("side"),
+		Status: c.Query("status"),
+		Sort:   c.Query("sort"),
+		Cursor: c.Query("cursor"),
+	}
+
+	var err error
+	if filter.From, err = parseTimeQuery(c, "from"); err == nil {
+		filter.To, err = parseTimeQuery(c, "to")
+	}
+	if err == nil && c.Query("limit") != "" {
+		filter.Limit, err = strconv.Atoi(c.Query("limit"))
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"error":   "Failed to get orders",
+			"error":   "Invalid query",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	page, err := h.orders.Query(filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid query",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    page.Orders,
+		"pagination": gin.H{
+			"next_cursor": page.NextCursor,
+			"limit":       page.Limit,
+			"total":       page.Total,
+		},
+	})
 }
 
 func (h *TradingHandler) GetOrderByID(c *gin.Context) {
@@ -96,5 +194,79 @@ func (h *TradingHandler) GetOrderByID(c *gin.Context) {
 		return
 	}
 
+	if _, err := h.orders.Record(response); err != nil {
+		log.Printf("Failed to record order %s: %v", id, err)
+	}
+
 	c.JSON(http.StatusOK, response)
 }
+
+// StreamOrders pushes order updates as Server-Sent Events. Pass order_id
+// (repeatable) to follow specific orders; by default all orders are streamed.
+// Reconnecting clients resume after the Last-Event-ID header; if that point
+// is no longer buffered a "reset" event tells them to refetch.
+func (h *TradingHandler) StreamOrders(c *gin.Context) {
+	topics := []string{"order:*"}
+	ids := c.QueryArray("order_id")
+	if len(ids) > 0 {
+		topics = topics[:0]
+		for _, id := range ids {
+			topics = append(topics, "order:"+id)
+		}
+	}
+
+	since, _ := strconv.ParseUint(c.GetHeader("Last-Event-ID"), 10, 64)
+	sub, replayed := h.events.Subscribe(events.TopicMatcher(topics...), since)
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	if !replayed {
+		c.Render(-1, sse.Event{Event: "reset", Data: gin.H{"seq": h.events.Seq()}})
+	}
+	// Followed orders start with their current state
+	for _, id := range ids {
+		if o, ok := h.orders.Get(id); ok {
+			c.Render(-1, sse.Event{Event: "order.snapshot", Data: o})
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case ev, ok := <-sub.C:
+			if !ok {
+				return false
+			}
+			c.Render(-1, sse.Event{Id: strconv.FormatUint(ev.Seq, 10), Event: ev.Type, Data: ev})
+			return true
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			return true
+		}
+	})
+}
+
+// parseTimeQuery reads an optional RFC 3339 timestamp or YYYY-MM-DD date
+func parseTimeQuery(c *gin.Context, key string) (time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp or YYYY-MM-DD date", key)
+	}
+	return t, nil
+}
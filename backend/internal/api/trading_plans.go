@@ -0,0 +1,153 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bitnob-api-demo/internal/dca"
+	"github.com/bitnob-api-demo/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+type TradingPlanHandler struct {
+	plans *dca.Service
+}
+
+func NewTradingPlanHandler(plans *dca.Service) *TradingPlanHandler {
+	return &TradingPlanHandler{
+		plans: plans,
+	}
+}
+
+func (h *TradingPlanHandler) CreatePlan(c *gin.Context) {
+	var req dca.Definition
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	plan, err := h.plans.Create(req, actorFrom(c))
+	if err != nil {
+		writePlanError(c, "Failed to create trading plan", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    plan,
+	})
+}
+
+func (h *TradingPlanHandler) ListPlans(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.plans.List(),
+	})
+}
+
+func (h *TradingPlanHandler) GetPlan(c *gin.Context) {
+	plan, ok := h.plans.Get(c.Param("id"))
+	if !ok {
+		writePlanError(c, "Failed to get trading plan", store.ErrNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    plan,
+	})
+}
+
+func (h *TradingPlanHandler) UpdatePlan(c *gin.Context) {
+	var req dca.Definition
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	plan, err := h.plans.Update(c.Param("id"), req, actorFrom(c))
+	if err != nil {
+		writePlanError(c, "Failed to update trading plan", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    plan,
+	})
+}
+
+func (h *TradingPlanHandler) DeletePlan(c *gin.Context) {
+	if err := h.plans.Delete(c.Param("id"), actorFrom(c)); err != nil {
+		writePlanError(c, "Failed to delete trading plan", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+	})
+}
+
+func (h *TradingPlanHandler) PausePlan(c *gin.Context) {
+	plan, err := h.plans.Pause(c.Param("id"), actorFrom(c))
+	if err != nil {
+		writePlanError(c, "Failed to pause trading plan", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    plan,
+	})
+}
+
+func (h *TradingPlanHandler) ResumePlan(c *gin.Context) {
+	plan, err := h.plans.Resume(c.Param("id"), actorFrom(c))
+	if err != nil {
+		writePlanError(c, "Failed to resume trading plan", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    plan,
+	})
+}
+
+func (h *TradingPlanHandler) ListRuns(c *gin.Context) {
+	if _, ok := h.plans.Get(c.Param("id")); !ok {
+		writePlanError(c, "Failed to list trading plan runs", store.ErrNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.plans.Runs(c.Param("id")),
+	})
+}
+
+func writePlanError(c *gin.Context, message string, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, dca.ErrInvalidState):
+		status = http.StatusConflict
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"error":   message,
+		"details": err.Error(),
+	})
+}
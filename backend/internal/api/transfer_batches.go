@@ -0,0 +1,93 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"github.com/bitnob-api-demo/internal/batch"
+	"github.com/bitnob-api-demo/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+type TransferBatchHandler struct {
+	batches *batch.TransferService
+}
+
+func NewTransferBatchHandler(batches *batch.TransferService) *TransferBatchHandler {
+	return &TransferBatchHandler{
+		batches: batches,
+	}
+}
+
+func (h *TransferBatchHandler) CreateBatch(c *gin.Context) {
+	data, _, _, err := readUpload(c)
+	if err != nil {
+		writeUploadError(c, "Invalid request", err)
+		return
+	}
+
+	transfers, err := batch.ParseTransfers(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	b, err := h.batches.Submit(transfers, c.Query("dry_run") == "true", actorFrom(c))
+	if errors.Is(err, batch.ErrRejected) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"success": false,
+			"error":   "Transfer batch rejected",
+			"data":    b,
+		})
+		return
+	}
+	if err != nil {
+		writeBatchError(c, "Failed to create transfer batch", err)
+		return
+	}
+
+	status := http.StatusAccepted
+	if b.Status == batch.StatusDryRun {
+		status = http.StatusOK
+	}
+	c.JSON(status, gin.H{
+		"success": true,
+		"data":    b,
+	})
+}
+
+func (h *TransferBatchHandler) ListBatches(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.batches.List(),
+	})
+}
+
+func (h *TransferBatchHandler) GetBatch(c *gin.Context) {
+	b, ok := h.batches.Get(c.Param("id"))
+	if !ok {
+		writeBatchError(c, "Failed to get transfer batch", store.ErrNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    b,
+	})
+}
+
+func (h *TransferBatchHandler) DownloadReport(c *gin.Context) {
+	var buf bytes.Buffer
+	if err := h.batches.WriteReportCSV(c.Param("id"), &buf); err != nil {
+		writeBatchError(c, "Failed to export transfer batch", err)
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+c.Param("id")+`-report.csv"`)
+	c.Data(http.StatusOK, "text/csv", buf.Bytes())
+}
@@ -1,19 +1,33 @@
 package api
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
+	"github.com/bitnob-api-demo/internal/address"
+	"github.com/bitnob-api-demo/internal/addressbook"
+	"github.com/bitnob-api-demo/internal/events"
 	"github.com/bitnob-api-demo/internal/models"
+	"github.com/bitnob-api-demo/internal/velocity"
 	"github.com/gin-gonic/gin"
 )
 
 type TransferHandler struct {
 	bitnobClient BitnobClient
+	network      address.Network
+	addressBook  *addressbook.Book
+	velocity     *velocity.Tracker
+	events       *events.Broker
 }
 
-func NewTransferHandler(client BitnobClient) *TransferHandler {
+func NewTransferHandler(client BitnobClient, network address.Network, book *addressbook.Book, tracker *velocity.Tracker, broker *events.Broker) *TransferHandler {
 	return &TransferHandler{
 		bitnobClient: client,
+		network:      network,
+		addressBook:  book,
+		velocity:     tracker,
+		events:       broker,
 	}
 }
 
@@ -29,8 +43,56 @@ func (h *TransferHandler) CreateTransfer(c *gin.Context) {
 		return
 	}
 
+	if err := h.validateDestination(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid destination address",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if _, err := h.addressBook.Authorize(req.Chain, req.Currency, req.ToAddress, time.Now()); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error":   "Destination address not approved",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	amount, err := velocity.ParseAmount(req.Amount)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request",
+			"details": "amount must be a positive number",
+		})
+		return
+	}
+
+	reservation, err := h.velocity.Reserve(req.Currency, amount, time.Now())
+	if err != nil {
+		var limitErr *velocity.LimitError
+		if !errors.As(err, &limitErr) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Invalid request",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"success": false,
+			"error":   "Transfer velocity limit exceeded",
+			"details": limitErr,
+		})
+		return
+	}
+
 	response, err := h.bitnobClient.CreateTransfer(req)
 	if err != nil {
+		h.velocity.Release(reservation)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"error":   "Failed to create transfer",
@@ -39,8 +101,23 @@ func (h *TransferHandler) CreateTransfer(c *gin.Context) {
 		return
 	}
 
+	var transfer models.TransferResponse
+	if err := models.Decode(response, &transfer); err == nil && transfer.TransactionID != "" {
+		h.events.Publish("transfer:"+transfer.TransactionID, "transfer.created", transfer)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    response,
 	})
 }
+
+// validateDestination checks the address against its chain and, for Lightning
+// invoices with a fixed amount, that the requested BTC amount matches
+func (h *TransferHandler) validateDestination(req models.TransferRequest) error {
+	result, err := address.Validate(req.Chain, req.ToAddress, h.network)
+	if err != nil {
+		return err
+	}
+	return address.CheckInvoiceAmount(result.Invoice, req.Amount, req.Currency)
+}
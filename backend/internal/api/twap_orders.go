@@ -0,0 +1,93 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bitnob-api-demo/internal/store"
+	"github.com/bitnob-api-demo/internal/twap"
+	"github.com/gin-gonic/gin"
+)
+
+type TWAPHandler struct {
+	orders *twap.Service
+}
+
+func NewTWAPHandler(orders *twap.Service) *TWAPHandler {
+	return &TWAPHandler{
+		orders: orders,
+	}
+}
+
+func (h *TWAPHandler) CreateOrder(c *gin.Context) {
+	var req twap.Request
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	order, err := h.orders.Create(req, actorFrom(c))
+	if err != nil {
+		writeTWAPError(c, "Failed to start TWAP order", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"data":    order,
+	})
+}
+
+func (h *TWAPHandler) ListOrders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    h.orders.List(),
+	})
+}
+
+func (h *TWAPHandler) GetOrder(c *gin.Context) {
+	order, ok := h.orders.Get(c.Param("id"))
+	if !ok {
+		writeTWAPError(c, "Failed to get TWAP order", store.ErrNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    order,
+	})
+}
+
+func (h *TWAPHandler) CancelOrder(c *gin.Context) {
+	order, err := h.orders.Cancel(c.Param("id"))
+	if err != nil {
+		writeTWAPError(c, "Failed to cancel TWAP order", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    order,
+	})
+}
+
+func writeTWAPError(c *gin.Context, message string, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, twap.ErrNotRunning):
+		status = http.StatusConflict
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"error":   message,
+		"details": err.Error(),
+	})
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxUploadBytes limits the size of batch uploads
+const maxUploadBytes = 10 << 20
+
+// multipartOverhead allows for the form envelope around an uploaded file
+const multipartOverhead = 1 << 20
+
+var errUploadTooLarge = fmt.Errorf("upload exceeds %d bytes", maxUploadBytes)
+
+// readUpload returns the uploaded file from a multipart "file" field, or the
+// raw request body otherwise, along with its filename and content type
+func readUpload(c *gin.Context) ([]byte, string, string, error) {
+	if !strings.HasPrefix(c.ContentType(), "multipart/") {
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadBytes)
+		data, err := io.ReadAll(body)
+		return data, "", c.ContentType(), uploadError(err)
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadBytes+multipartOverhead)
+	file, err := c.FormFile("file")
+	if err != nil {
+		return nil, "", "", uploadError(err)
+	}
+	if file.Size > maxUploadBytes {
+		return nil, "", "", errUploadTooLarge
+	}
+	f, err := file.Open()
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	return data, file.Filename, file.Header.Get("Content-Type"), err
+}
+
+// uploadError reports a body cut off by the size limit as errUploadTooLarge
+func uploadError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return errUploadTooLarge
+	}
+	return err
+}
+
+// writeUploadError rejects an unreadable upload, with 413 when it is too large
+func writeUploadError(c *gin.Context, message string, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, errUploadTooLarge) {
+		status = http.StatusRequestEntityTooLarge
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"error":   message,
+		"details": err.Error(),
+	})
+}
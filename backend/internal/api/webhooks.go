@@ -0,0 +1,183 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bitnob-api-demo/internal/events"
+	"github.com/bitnob-api-demo/internal/ledger"
+	"github.com/bitnob-api-demo/internal/reconcile"
+	"github.com/gin-gonic/gin"
+)
+
+// webhookSignatureHeader carries the hex HMAC-SHA512 of the raw body
+const webhookSignatureHeader = "X-Bitnob-Signature"
+
+// maxWebhookBody bounds the size of an accepted webhook payload
+const maxWebhookBody = 1 << 20
+
+type WebhookHandler struct {
+	secret    string
+	events    *events.Broker
+	reconcile *reconcile.Service
+	ledger    *ledger.Ledger
+}
+
+// NewWebhookHandler republishes signed webhooks to broker and feeds them to
+// reconciliation and customer balances. Unsigned webhooks could be forged by
+// anyone, so without a secret every webhook is refused.
+func NewWebhookHandler(secret string, broker *events.Broker, reconciler *reconcile.Service, customerLedger *ledger.Ledger) *WebhookHandler {
+	return &WebhookHandler{
+		secret:    secret,
+		events:    broker,
+		reconcile: reconciler,
+		ledger:    customerLedger,
+	}
+}
+
+// webhookPayload is the envelope Bitnob posts for account events
+type webhookPayload struct {
+	Event string                 `json:"event"`
+	Data  map[string]interface{} `json:"data"`
+}
+
+// ReceiveBitnob verifies a Bitnob webhook and republishes it to event subscribers
+func (h *WebhookHandler) ReceiveBitnob(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if h.secret == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"error":   "Webhooks are disabled",
+			"details": "set BITNOB_WEBHOOK_SECRET, or webhook_secret for the tenant, to accept signed webhooks",
+		})
+		return
+	}
+	if !validWebhookSignature(h.secret, body, c.GetHeader(webhookSignatureHeader)) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Invalid webhook signature",
+		})
+		return
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Event == "" {
+		details := "event is required"
+		if err != nil {
+			details = err.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid webhook payload",
+			"details": details,
+		})
+		return
+	}
+
+	if obs, ok := webhookObservation(payload); ok {
+		h.reconcile.Observe(obs)
+		if obs.Kind == reconcile.KindPayout {
+			// Settle or refund the payout in customer balances
+			h.ledger.Observe(obs.Reference, obs.Status)
+		}
+	}
+	ev := h.events.Publish(webhookTopic(payload), payload.Event, payload.Data)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    gin.H{"seq": ev.Seq, "topic": ev.Topic},
+	})
+}
+
+func validWebhookSignature(secret string, body []byte, signature string) bool {
+	got, err := hex.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha512.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// webhookResource identifies the resource a webhook concerns. Payout events
+// are keyed by quote ID, matching how payouts are tracked everywhere else;
+// events that name no resource return an empty kind.
+func webhookResource(p webhookPayload) (kind, id string) {
+	name := strings.ToLower(p.Event)
+	switch {
+	case strings.Contains(name, "payout"):
+		if id := webhookString(p, "quoteId", "quote_id"); id != "" {
+			return reconcile.KindPayout, id
+		}
+	case strings.Contains(name, "order") || strings.Contains(name, "trade"):
+		if id := webhookString(p, "id", "order_id", "orderId"); id != "" {
+			return reconcile.KindOrder, id
+		}
+	case strings.Contains(name, "send") || strings.Contains(name, "transfer") || strings.Contains(name, "withdraw"):
+		if id := webhookString(p, "transaction_id", "id", "reference"); id != "" {
+			return reconcile.KindTransfer, id
+		}
+	}
+	return "", ""
+}
+
+// webhookTopic routes a webhook to its resource topic, or the account topic
+// when it names no resource
+func webhookTopic(p webhookPayload) string {
+	if kind, id := webhookResource(p); kind != "" {
+		return kind + ":" + id
+	}
+	return events.AccountTopic
+}
+
+func webhookString(p webhookPayload, keys ...string) string {
+	for _, k := range keys {
+		if v, ok := p.Data[k].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// webhookObservation reads Bitnob's reported state of a transfer or payout
+// for reconciliation
+func webhookObservation(p webhookPayload) (reconcile.Observation, bool) {
+	kind, id := webhookResource(p)
+	if kind != reconcile.KindTransfer && kind != reconcile.KindPayout {
+		return reconcile.Observation{}, false
+	}
+
+	status := webhookString(p, "status")
+	if status == "" {
+		// Fall back to the event suffix, e.g. "transfer.success"
+		status = p.Event[strings.LastIndexAny(p.Event, "._")+1:]
+	}
+	obs := reconcile.Observation{
+		Kind:      kind,
+		Reference: id,
+		Status:    status,
+		Currency:  strings.ToUpper(webhookString(p, "currency", "fromAsset")),
+	}
+	switch v := p.Data["amount"].(type) {
+	case float64:
+		obs.Amount = v
+	case string:
+		obs.Amount, _ = strconv.ParseFloat(v, 64)
+	}
+	return obs, true
+}
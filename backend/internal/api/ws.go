@@ -0,0 +1,293 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/events"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// wsHeartbeat is how often the server sends a heartbeat message
+const wsHeartbeat = 20 * time.Second
+
+// wsWriteTimeout bounds how long a single message write may block
+const wsWriteTimeout = 10 * time.Second
+
+type WebSocketHandler struct {
+	events  *events.Broker
+	grants  events.Grants
+	origins []string
+	keyed   bool
+}
+
+// NewWebSocketHandler serves events to browsers from origins. keyed reports
+// whether the tenant gateway already authenticated each request by API key.
+func NewWebSocketHandler(broker *events.Broker, grants events.Grants, origins []string, keyed bool) *WebSocketHandler {
+	return &WebSocketHandler{
+		events:  broker,
+		grants:  grants,
+		origins: origins,
+		keyed:   keyed,
+	}
+}
+
+// wsRequest is a client message. Subscribe with Since set to the last
+// sequence number received to replay events missed while disconnected.
+type wsRequest struct {
+	Op     string   `json:"op"`
+	Topics []string `json:"topics"`
+	Since  uint64   `json:"since"`
+}
+
+// wsMessage is a server message
+type wsMessage struct {
+	Type   string        `json:"type"`
+	Seq    uint64        `json:"seq,omitempty"`
+	Topics []string      `json:"topics,omitempty"`
+	Event  *events.Event `json:"event,omitempty"`
+	Error  string        `json:"error,omitempty"`
+	At     time.Time     `json:"at"`
+}
+
+// Connect authenticates the WebSocket key from the Authorization bearer
+// header or the token query parameter, then upgrades to a WebSocket. Without
+// configured WebSocket keys the tenant API key suffices; a tenant with
+// neither refuses connections. Browsers must connect from a CORS origin.
+func (h *WebSocketHandler) Connect(c *gin.Context) {
+	key := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if key == "" {
+		key = c.Query("token")
+	}
+	if len(h.grants) > 0 && !h.grants.Authenticate(key) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Invalid or missing API key",
+		})
+		return
+	}
+	if len(h.grants) == 0 && !h.keyed {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Event WebSocket requires an API key",
+			"details": "Set WS_API_KEYS or tenant API keys",
+		})
+		return
+	}
+
+	server := websocket.Server{
+		// The CORS middleware does not apply to upgrades, so the handshake
+		// checks the Origin itself
+		Handshake: func(_ *websocket.Config, r *http.Request) error { return h.checkOrigin(r.Header.Get("Origin")) },
+		Handler:   func(ws *websocket.Conn) { h.serve(ws, key) },
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// checkOrigin accepts the tenant's CORS origins. Clients that send no Origin
+// are not browsers and are authenticated by key alone.
+func (h *WebSocketHandler) checkOrigin(origin string) error {
+	if origin == "" {
+		return nil
+	}
+	for _, allowed := range h.origins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %q is not allowed", origin)
+}
+
+// serve runs one connection. A single loop owns the broker subscription and
+// all writes; a reader goroutine forwards client requests to it.
+func (h *WebSocketHandler) serve(ws *websocket.Conn, key string) {
+	defer ws.Close()
+
+	// done releases the reader if it is handing over a request after the
+	// loop has returned
+	requests := make(chan wsRequest)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(requests)
+		for {
+			var req wsRequest
+			if err := websocket.JSON.Receive(ws, &req); err != nil {
+				return
+			}
+			select {
+			case requests <- req:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	send := func(m wsMessage) bool {
+		m.At = time.Now().UTC()
+		ws.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		return websocket.JSON.Send(ws, m) == nil
+	}
+
+	var (
+		topics   []string
+		sub      *events.Subscription
+		incoming <-chan events.Event
+		lastSeq  uint64
+		// After a resubscribe, events up to swapSeq were already sent unless
+		// they belong to a newly added topic being replayed
+		swapSeq uint64
+		fresh   func(events.Event) bool
+	)
+	defer func() {
+		if sub != nil {
+			sub.Close()
+		}
+	}()
+
+	heartbeat := time.NewTicker(wsHeartbeat)
+	defer heartbeat.Stop()
+
+	if !send(wsMessage{Type: "welcome", Seq: h.events.Seq()}) {
+		return
+	}
+
+	for {
+		select {
+		case req, ok := <-requests:
+			if !ok {
+				return
+			}
+
+			switch req.Op {
+			case "ping":
+				if !send(wsMessage{Type: "pong", Seq: h.events.Seq()}) {
+					return
+				}
+				continue
+			case "subscribe", "unsubscribe":
+			default:
+				if !send(wsMessage{Type: "error", Error: fmt.Sprintf("unknown op %q", req.Op)}) {
+					return
+				}
+				continue
+			}
+
+			added, denied := h.authorize(key, req.Topics)
+			if req.Op == "unsubscribe" {
+				topics = removeTopics(topics, req.Topics)
+				added, denied = nil, nil
+			} else {
+				topics = appendTopics(topics, added)
+			}
+			for _, msg := range denied {
+				if !send(wsMessage{Type: "error", Error: msg}) {
+					return
+				}
+			}
+
+			since := lastSeq
+			if req.Since > 0 {
+				since = req.Since
+			}
+			if sub != nil {
+				sub.Close()
+				sub, incoming = nil, nil
+			}
+			if len(topics) > 0 {
+				var replayed bool
+				sub, replayed = h.events.Subscribe(events.TopicMatcher(patternsOf(topics)...), since)
+				incoming = sub.C
+				swapSeq = lastSeq
+				fresh = events.TopicMatcher(patternsOf(added)...)
+				if len(added) == 0 {
+					fresh = func(events.Event) bool { return false }
+				}
+				if !replayed && !send(wsMessage{Type: "reset", Seq: h.events.Seq(), Error: "events since the requested sequence are no longer available"}) {
+					return
+				}
+			}
+			if !send(wsMessage{Type: req.Op + "d", Topics: topics, Seq: h.events.Seq()}) {
+				return
+			}
+
+		case ev, ok := <-incoming:
+			if !ok {
+				// The broker dropped this connection for falling behind
+				send(wsMessage{Type: "error", Error: "connection too slow, reconnect with since to resume"})
+				return
+			}
+			if ev.Seq <= swapSeq && !fresh(ev) {
+				continue
+			}
+			if !send(wsMessage{Type: "event", Seq: ev.Seq, Event: &ev}) {
+				return
+			}
+			lastSeq = max(lastSeq, ev.Seq)
+
+		case <-heartbeat.C:
+			if !send(wsMessage{Type: "heartbeat", Seq: h.events.Seq()}) {
+				log.Printf("WebSocket heartbeat failed, closing connection")
+				return
+			}
+		}
+	}
+}
+
+// authorize splits requested topics into those the key may subscribe to and
+// error messages for the rest
+func (h *WebSocketHandler) authorize(key string, topics []string) (allowed, denied []string) {
+	for _, t := range topics {
+		t = strings.TrimSpace(t)
+		if err := events.ValidateTopic(t); err != nil {
+			denied = append(denied, err.Error())
+			continue
+		}
+		if len(h.grants) > 0 && !h.grants.Allows(key, t) {
+			denied = append(denied, fmt.Sprintf("not authorized for topic %q", t))
+			continue
+		}
+		allowed = append(allowed, t)
+	}
+	return allowed, denied
+}
+
+func appendTopics(topics, add []string) []string {
+	for _, t := range add {
+		if !containsTopic(topics, t) {
+			topics = append(topics, t)
+		}
+	}
+	return topics
+}
+
+func removeTopics(topics, remove []string) []string {
+	out := topics[:0]
+	for _, t := range topics {
+		if !containsTopic(remove, t) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func containsTopic(topics []string, t string) bool {
+	for _, x := range topics {
+		if x == t {
+			return true
+		}
+	}
+	return false
+}
+
+func patternsOf(topics []string) []string {
+	out := make([]string, len(topics))
+	for i, t := range topics {
+		out[i] = events.Pattern(t)
+	}
+	return out
+}
@@ -0,0 +1,69 @@
+package audit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/store"
+)
+
+// Entry records a single change made through the gateway
+type Entry struct {
+	ID         string      `json:"id"`
+	Time       time.Time   `json:"time"`
+	Actor      string      `json:"actor"`
+	Action     string      `json:"action"`
+	Resource   string      `json:"resource"`
+	ResourceID string      `json:"resource_id"`
+	Before     interface{} `json:"before,omitempty"`
+	After      interface{} `json:"after,omitempty"`
+}
+
+// Log is an append-only audit trail
+type Log struct {
+	mu      sync.RWMutex
+	entries []Entry
+}
+
+// NewLog creates an empty audit log
+func NewLog() *Log {
+	return &Log{}
+}
+
+// Record appends an entry to the log
+func (l *Log) Record(actor, action, resource, resourceID string, before, after interface{}) Entry {
+	entry := Entry{
+		ID:         store.NewID("aud"),
+		Time:       time.Now().UTC(),
+		Actor:      actor,
+		Action:     action,
+		Resource:   resource,
+		ResourceID: resourceID,
+		Before:     before,
+		After:      after,
+	}
+
+	l.mu.Lock()
+	l.entries = append(l.entries, entry)
+	l.mu.Unlock()
+
+	return entry
+}
+
+// List returns entries for a resource type, optionally narrowed to one resource ID
+func (l *Log) List(resource, resourceID string) []Entry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	out := make([]Entry, 0)
+	for _, e := range l.entries {
+		if resource != "" && e.Resource != resource {
+			continue
+		}
+		if resourceID != "" && e.ResourceID != resourceID {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
@@ -0,0 +1,108 @@
+package batch
+
+import (
+	"context"
+	"sync"
+)
+
+// Status is the lifecycle state of a batch
+type Status string
+
+const (
+	StatusDryRun    Status = "dry_run"
+	StatusRunning   Status = "running"
+	StatusCompleted Status = "completed"
+	StatusPartial   Status = "partially_failed"
+	StatusFailed    Status = "failed"
+	StatusRejected  Status = "rejected"
+)
+
+// ItemStatus is the state of a single row in a batch
+type ItemStatus string
+
+const (
+	ItemValid     ItemStatus = "valid"
+	ItemInvalid   ItemStatus = "invalid"
+	ItemSkipped   ItemStatus = "skipped"
+	ItemPending   ItemStatus = "pending"
+	ItemRunning   ItemStatus = "running"
+	ItemSucceeded ItemStatus = "succeeded"
+	ItemFailed    ItemStatus = "failed"
+)
+
+// Summary counts rows by status
+type Summary struct {
+	Total     int `json:"total"`
+	Valid     int `json:"valid"`
+	Invalid   int `json:"invalid"`
+	Skipped   int `json:"skipped"`
+	Pending   int `json:"pending"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
+
+// Add counts one row in the summary
+func (s *Summary) Add(status ItemStatus) {
+	s.Total++
+	switch status {
+	case ItemValid:
+		s.Valid++
+	case ItemInvalid:
+		s.Invalid++
+	case ItemSkipped:
+		s.Skipped++
+	case ItemPending, ItemRunning:
+		s.Pending++
+	case ItemSucceeded:
+		s.Succeeded++
+	case ItemFailed:
+		s.Failed++
+	}
+}
+
+// notStarted is the error of rows skipped because the server shut down
+// before they ran; they can be retried
+const notStarted = "not started before shutdown"
+
+// FinalStatus derives the batch status once all rows have run
+func (s Summary) FinalStatus() Status {
+	switch {
+	case s.Pending > 0:
+		return StatusRunning
+	case s.Failed == 0:
+		return StatusCompleted
+	case s.Succeeded == 0:
+		return StatusFailed
+	default:
+		return StatusPartial
+	}
+}
+
+// ForEach calls fn for every index with at most concurrency calls in flight.
+// Indexes not yet started when ctx is cancelled are skipped.
+func ForEach(ctx context.Context, indexes []int, concurrency int, fn func(ctx context.Context, i int)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for _, i := range indexes {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(ctx, i)
+		}(i)
+	}
+
+	wg.Wait()
+}
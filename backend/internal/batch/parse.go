@@ -0,0 +1,147 @@
+package batch
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/bitnob-api-demo/internal/models"
+)
+
+// Format identifies an upload encoding
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatJSON Format = "json"
+)
+
+// DetectFormat picks an upload format from a filename or content type,
+// falling back to sniffing the first non-space byte
+func DetectFormat(filename, contentType string, data []byte) Format {
+	name := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(name, ".csv"), strings.Contains(contentType, "csv"):
+		return FormatCSV
+	case strings.HasSuffix(name, ".json"), strings.Contains(contentType, "json"):
+		return FormatJSON
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		return FormatJSON
+	}
+	return FormatCSV
+}
+
+// ParsePayoutRows decodes payout rows from a CSV or JSON upload. JSON may be
+// an array of rows or an object with a "rows" array.
+func ParsePayoutRows(data []byte, format Format) ([]models.PayoutBatchRow, error) {
+	if format == FormatJSON {
+		var rows []models.PayoutBatchRow
+		if err := json.Unmarshal(data, &rows); err == nil {
+			return rows, nil
+		}
+		var wrapped struct {
+			Rows []models.PayoutBatchRow `json:"rows"`
+		}
+		if err := json.Unmarshal(data, &wrapped); err != nil {
+			return nil, fmt.Errorf("invalid JSON upload: %w", err)
+		}
+		return wrapped.Rows, nil
+	}
+
+	records, header, err := readCSV(data)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]models.PayoutBatchRow, 0, len(records))
+	for n, record := range records {
+		get := func(names ...string) string {
+			for _, name := range names {
+				if i, ok := header[strings.ToLower(name)]; ok && i < len(record) {
+					return strings.TrimSpace(record[i])
+				}
+			}
+			return ""
+		}
+
+		amount, err := parseOptionalFloat(get("amount"))
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid amount: %w", n+1, err)
+		}
+		settlement, err := parseOptionalFloat(get("settlementAmount"))
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid settlementAmount: %w", n+1, err)
+		}
+
+		row := models.PayoutBatchRow{
+			Reference:          get("reference"),
+			CustomerID:         get("customerId"),
+			Country:            get("country"),
+			Source:             get("source"),
+			FromAsset:          get("fromAsset"),
+			ToCurrency:         get("toCurrency"),
+			Chain:              get("chain"),
+			Amount:             amount,
+			SettlementAmount:   settlement,
+			PaymentReason:      get("paymentReason"),
+			SavedBeneficiaryID: get("savedBeneficiaryId"),
+		}
+
+		details := models.BeneficiaryDetails{
+			Type:          get("beneficiaryType", "type"),
+			BankCode:      get("bankCode"),
+			AccountName:   get("accountName"),
+			AccountNumber: get("accountNumber"),
+			Network:       get("network"),
+			PhoneNumber:   get("phoneNumber"),
+		}
+		if details != (models.BeneficiaryDetails{}) {
+			row.Beneficiary = &details
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// readCSV returns the data records and a lower-cased header index
+func readCSV(data []byte) ([][]string, map[string]int, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	head, err := r.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, errors.New("upload is empty")
+		}
+		return nil, nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+
+	header := make(map[string]int, len(head))
+	for i, name := range head {
+		header[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	return records, header, nil
+}
+
+func parseOptionalFloat(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), 64)
+}
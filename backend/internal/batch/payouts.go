@@ -0,0 +1,551 @@
+package batch
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/beneficiary"
+	"github.com/bitnob-api-demo/internal/models"
+	"github.com/bitnob-api-demo/internal/requirements"
+	"github.com/bitnob-api-demo/internal/spreadsheet"
+	"github.com/bitnob-api-demo/internal/store"
+)
+
+// MaxRows caps the number of rows accepted in a single upload
+const MaxRows = 5000
+
+// Payout pipeline steps
+const (
+	StepQuote      = "quote"
+	StepInitialize = "initialize"
+	StepFinalize   = "finalize"
+	StepDone       = "done"
+)
+
+var (
+	ErrNotDryRun      = errors.New("batch has already been confirmed")
+	ErrInvalidRows    = errors.New("batch has invalid rows; fix them or confirm with skipInvalid")
+	ErrNotRetryable   = errors.New("batch is still running")
+	ErrNothingToRetry = errors.New("batch has no failed rows")
+)
+
+// PayoutClient is the subset of the Bitnob client used to execute payouts
+type PayoutClient interface {
+	CreatePayoutQuote(req interface{}) (interface{}, error)
+	InitializePayout(req interface{}) (interface{}, error)
+	FinalizePayout(req interface{}) (interface{}, error)
+}
+
+// PayoutItem tracks one recipient row through validation and execution
+type PayoutItem struct {
+	Row              int                   `json:"row"`
+	Input            models.PayoutBatchRow `json:"input"`
+	Status           ItemStatus            `json:"status"`
+	Errors           []string              `json:"errors,omitempty"`
+	Step             string                `json:"step,omitempty"`
+	QuoteID          string                `json:"quoteId,omitempty"`
+	PayoutID         string                `json:"payoutId,omitempty"`
+	ExchangeRate     float64               `json:"exchangeRate,omitempty"`
+	SettlementAmount float64               `json:"settlementAmount,omitempty"`
+	Fees             float64               `json:"fees,omitempty"`
+	Attempts         int                   `json:"attempts"`
+	Error            string                `json:"error,omitempty"`
+	UpdatedAt        time.Time             `json:"updatedAt"`
+}
+
+// MarshalJSON masks inline account numbers, which are only needed to execute
+// the row
+func (i PayoutItem) MarshalJSON() ([]byte, error) {
+	type item PayoutItem
+	out := item(i)
+	if out.Input.Beneficiary != nil {
+		masked := out.Input.Beneficiary.Masked()
+		out.Input.Beneficiary = &masked
+	}
+	return json.Marshal(out)
+}
+
+// PayoutBatch is an uploaded set of payouts
+type PayoutBatch struct {
+	ID          string             `json:"id"`
+	Status      Status             `json:"status"`
+	CreatedBy   string             `json:"createdBy"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	ConfirmedAt *time.Time         `json:"confirmedAt,omitempty"`
+	CompletedAt *time.Time         `json:"completedAt,omitempty"`
+	Summary     Summary            `json:"summary"`
+	Totals      map[string]float64 `json:"totals"`
+	Items       []PayoutItem       `json:"items,omitempty"`
+}
+
+// PayoutService validates and executes payout batches
+type PayoutService struct {
+	ctx           context.Context
+	client        PayoutClient
+	beneficiaries *beneficiary.Store
+	requirements  *requirements.Cache
+	concurrency   int
+	logger        *log.Logger
+
+	mu      sync.Mutex
+	batches *store.Table[*PayoutBatch]
+}
+
+// NewPayoutService creates a payout batch service. Batches run until ctx is cancelled.
+func NewPayoutService(ctx context.Context, client PayoutClient, beneficiaries *beneficiary.Store, reqCache *requirements.Cache, concurrency int) *PayoutService {
+	return &PayoutService{
+		ctx:           ctx,
+		client:        client,
+		beneficiaries: beneficiaries,
+		requirements:  reqCache,
+		concurrency:   concurrency,
+		batches:       store.NewTable[*PayoutBatch](),
+		logger:        log.Default(),
+	}
+}
+
+// WithLogger sends logs of batch progress to logger, e.g. one tagged with the tenant
+func (s *PayoutService) WithLogger(logger *log.Logger) *PayoutService {
+	s.logger = logger
+	return s
+}
+
+// Create validates every row and stores the batch as a dry run
+func (s *PayoutService) Create(rows []models.PayoutBatchRow, actor string) (PayoutBatch, error) {
+	if len(rows) == 0 {
+		return PayoutBatch{}, errors.New("upload contains no rows")
+	}
+	if len(rows) > MaxRows {
+		return PayoutBatch{}, fmt.Errorf("upload has %d rows, the maximum is %d", len(rows), MaxRows)
+	}
+
+	b := &PayoutBatch{
+		ID:        store.NewID("pbat"),
+		Status:    StatusDryRun,
+		CreatedBy: actor,
+		CreatedAt: time.Now().UTC(),
+		Items:     make([]PayoutItem, len(rows)),
+	}
+
+	// Rows stay invalid while the limits cannot be checked
+	limits, err := s.requirements.Limits()
+	if err != nil {
+		s.logger.Printf("Batch %s: transaction limits unavailable: %v", b.ID, err)
+	}
+
+	references := make(map[string]int)
+	for i, row := range rows {
+		if row.Reference == "" {
+			row.Reference = fmt.Sprintf("%s-%d", b.ID, i+1)
+		}
+		item := PayoutItem{Row: i + 1, Input: row, Status: ItemValid, UpdatedAt: b.CreatedAt}
+		item.Errors = s.validateRow(row, limits)
+		if first, dup := references[row.Reference]; dup {
+			item.Errors = append(item.Errors, fmt.Sprintf("reference duplicates row %d", first))
+		}
+		references[row.Reference] = i + 1
+		if len(item.Errors) > 0 {
+			item.Status = ItemInvalid
+		}
+		b.Items[i] = item
+	}
+	s.summarize(b)
+
+	s.batches.Put(b.ID, b)
+	return s.snapshot(b), nil
+}
+
+// Get returns a copy of a batch
+func (s *PayoutService) Get(id string) (PayoutBatch, bool) {
+	b, ok := s.batches.Get(id)
+	if !ok {
+		return PayoutBatch{}, false
+	}
+	return s.snapshot(b), true
+}
+
+// List returns copies of all batches without their items
+func (s *PayoutService) List() []PayoutBatch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]PayoutBatch, 0)
+	for _, b := range s.batches.List(nil) {
+		copied := *b
+		copied.Items = nil
+		out = append(out, copied)
+	}
+	return out
+}
+
+// Confirm starts executing a dry-run batch
+func (s *PayoutService) Confirm(id string, skipInvalid bool) (PayoutBatch, error) {
+	b, ok := s.batches.Get(id)
+	if !ok {
+		return PayoutBatch{}, store.ErrNotFound
+	}
+
+	s.mu.Lock()
+	if b.Status != StatusDryRun {
+		s.mu.Unlock()
+		return PayoutBatch{}, ErrNotDryRun
+	}
+	if b.Summary.Invalid > 0 && !skipInvalid {
+		s.mu.Unlock()
+		return PayoutBatch{}, ErrInvalidRows
+	}
+
+	var indexes []int
+	for i := range b.Items {
+		switch b.Items[i].Status {
+		case ItemInvalid:
+			b.Items[i].Status = ItemSkipped
+		case ItemValid:
+			b.Items[i].Status = ItemPending
+			b.Items[i].Step = StepQuote
+			indexes = append(indexes, i)
+		}
+	}
+	now := time.Now().UTC()
+	b.ConfirmedAt = &now
+	b.Status = StatusRunning
+	s.summarize(b)
+	s.mu.Unlock()
+
+	go s.run(b, indexes)
+	return s.snapshot(b), nil
+}
+
+// Retry re-runs the failed rows of a finished batch
+func (s *PayoutService) Retry(id string) (PayoutBatch, error) {
+	b, ok := s.batches.Get(id)
+	if !ok {
+		return PayoutBatch{}, store.ErrNotFound
+	}
+
+	s.mu.Lock()
+	if b.Status == StatusDryRun || b.Status == StatusRunning {
+		s.mu.Unlock()
+		return PayoutBatch{}, ErrNotRetryable
+	}
+
+	var indexes []int
+	for i := range b.Items {
+		item := &b.Items[i]
+		if item.Status != ItemFailed {
+			continue
+		}
+		// A failed finalize can be retried with the same quote; earlier
+		// failures restart from a fresh quote
+		if item.Step != StepFinalize {
+			item.Step = StepQuote
+			item.QuoteID = ""
+			item.PayoutID = ""
+		}
+		item.Status = ItemPending
+		item.Error = ""
+		indexes = append(indexes, i)
+	}
+	if len(indexes) == 0 {
+		s.mu.Unlock()
+		return PayoutBatch{}, ErrNothingToRetry
+	}
+	b.Status = StatusRunning
+	b.CompletedAt = nil
+	s.summarize(b)
+	s.mu.Unlock()
+
+	go s.run(b, indexes)
+	return s.snapshot(b), nil
+}
+
+// WriteResultsCSV writes the per-row outcome of a batch
+func (s *PayoutService) WriteResultsCSV(id string, w io.Writer) error {
+	b, ok := s.Get(id)
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"row", "reference", "customerId", "country", "fromAsset", "toCurrency", "amount", "settlementAmount",
+		"status", "step", "quoteId", "payoutId", "exchangeRate", "fees", "attempts", "error"})
+	for _, item := range b.Items {
+		errText := item.Error
+		if errText == "" && len(item.Errors) > 0 {
+			errText = strings.Join(item.Errors, "; ")
+		}
+		cw.Write([]string{
+			strconv.Itoa(item.Row),
+			spreadsheet.Cell(item.Input.Reference),
+			spreadsheet.Cell(item.Input.CustomerID),
+			spreadsheet.Cell(item.Input.Country),
+			spreadsheet.Cell(item.Input.FromAsset),
+			spreadsheet.Cell(item.Input.ToCurrency),
+			formatFloat(item.Input.Amount),
+			formatFloat(firstNonZero(item.SettlementAmount, item.Input.SettlementAmount)),
+			string(item.Status),
+			item.Step,
+			item.QuoteID,
+			item.PayoutID,
+			formatFloat(item.ExchangeRate),
+			formatFloat(item.Fees),
+			strconv.Itoa(item.Attempts),
+			spreadsheet.Cell(errText),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (s *PayoutService) run(b *PayoutBatch, indexes []int) {
+	ForEach(s.ctx, indexes, s.concurrency, func(ctx context.Context, i int) {
+		s.execute(b, i)
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	for _, i := range indexes {
+		if item := &b.Items[i]; item.Status == ItemPending {
+			item.Status = ItemFailed
+			item.Error = notStarted
+			item.UpdatedAt = now
+		}
+	}
+	s.summarize(b)
+	b.Status = b.Summary.FinalStatus()
+	b.CompletedAt = &now
+	s.logger.Printf("Payout batch %s finished: %d succeeded, %d failed", b.ID, b.Summary.Succeeded, b.Summary.Failed)
+}
+
+// execute runs quote → initialize → finalize for one row, resuming from its current step
+func (s *PayoutService) execute(b *PayoutBatch, i int) {
+	s.mu.Lock()
+	item := b.Items[i]
+	b.Items[i].Status = ItemRunning
+	b.Items[i].Attempts++
+	s.mu.Unlock()
+
+	row := item.Input
+	fail := func(step string, err error) {
+		s.update(b, i, func(it *PayoutItem) {
+			it.Status = ItemFailed
+			it.Step = step
+			it.Error = err.Error()
+		})
+	}
+
+	if item.Step == StepQuote || item.QuoteID == "" {
+		limits, err := s.requirements.Limits()
+		if err != nil {
+			fail(StepQuote, fmt.Errorf("transaction limits: %w", err))
+			return
+		}
+		quoteReq := quoteRequest(row)
+		raw, err := s.client.CreatePayoutQuote(quoteReq)
+		if err != nil {
+			fail(StepQuote, err)
+			return
+		}
+		var quote models.PayoutQuoteResponse
+		if err := models.Decode(raw, &quote); err != nil {
+			fail(StepQuote, err)
+			return
+		}
+		if err := requirements.CheckQuoted(limits, quoteReq, quote); err != nil {
+			fail(StepQuote, err)
+			return
+		}
+		item.QuoteID = firstNonEmpty(quote.QuoteID, quote.ID)
+		if item.QuoteID == "" {
+			fail(StepQuote, errors.New("quote response did not include a quote ID"))
+			return
+		}
+		s.update(b, i, func(it *PayoutItem) {
+			it.QuoteID = item.QuoteID
+			it.ExchangeRate = quote.ExchangeRate
+			it.SettlementAmount = quote.SettlementAmount
+			it.Step = StepInitialize
+		})
+		item.Step = StepInitialize
+	}
+
+	if item.Step == StepInitialize {
+		details, err := s.resolveBeneficiary(row)
+		if err != nil {
+			fail(StepInitialize, err)
+			return
+		}
+		raw, err := s.client.InitializePayout(models.InitializePayoutRequest{
+			QuoteID:       item.QuoteID,
+			CustomerID:    row.CustomerID,
+			Country:       row.Country,
+			Reference:     row.Reference,
+			PaymentReason: row.PaymentReason,
+			Beneficiary:   details,
+		})
+		if err != nil {
+			fail(StepInitialize, err)
+			return
+		}
+		var init models.InitializePayoutResponse
+		if err := models.Decode(raw, &init); err != nil {
+			s.logger.Printf("Batch %s row %d: could not decode initialize response: %v", b.ID, item.Row, err)
+		}
+		s.update(b, i, func(it *PayoutItem) {
+			it.PayoutID = init.ID
+			it.Fees = init.Fees
+			it.Step = StepFinalize
+		})
+	}
+
+	if _, err := s.client.FinalizePayout(models.FinalizePayoutRequest{QuoteID: item.QuoteID}); err != nil {
+		fail(StepFinalize, err)
+		return
+	}
+
+	s.update(b, i, func(it *PayoutItem) {
+		it.Status = ItemSucceeded
+		it.Step = StepDone
+		it.Error = ""
+	})
+}
+
+func (s *PayoutService) update(b *PayoutBatch, i int, fn func(*PayoutItem)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&b.Items[i])
+	b.Items[i].UpdatedAt = time.Now().UTC()
+	s.summarize(b)
+}
+
+// validateRow returns every problem with a row, without calling Bitnob for anything but cached requirements
+func (s *PayoutService) validateRow(row models.PayoutBatchRow, limits []models.TransactionLimits) []string {
+	var errs []string
+	required := map[string]string{
+		"customerId":    row.CustomerID,
+		"country":       row.Country,
+		"source":        row.Source,
+		"fromAsset":     row.FromAsset,
+		"toCurrency":    row.ToCurrency,
+		"paymentReason": row.PaymentReason,
+	}
+	for _, name := range []string{"customerId", "country", "source", "fromAsset", "toCurrency", "paymentReason"} {
+		if strings.TrimSpace(required[name]) == "" {
+			errs = append(errs, name+" is required")
+		}
+	}
+	if row.Amount <= 0 && row.SettlementAmount <= 0 {
+		errs = append(errs, "amount or settlementAmount must be positive")
+	}
+	if row.Country == "" {
+		return errs
+	}
+
+	if _, err := s.resolveBeneficiary(row); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	if limits == nil {
+		errs = append(errs, "transaction limits are unavailable")
+	} else if err := requirements.CheckQuote(limits, quoteRequest(row)); err != nil && !errors.Is(err, requirements.ErrUnconverted) {
+		errs = append(errs, err.Error())
+	}
+
+	return errs
+}
+
+func quoteRequest(row models.PayoutBatchRow) models.PayoutQuoteRequest {
+	return models.PayoutQuoteRequest{
+		Source:           row.Source,
+		FromAsset:        row.FromAsset,
+		ToCurrency:       row.ToCurrency,
+		Chain:            row.Chain,
+		Amount:           row.Amount,
+		SettlementAmount: row.SettlementAmount,
+		Country:          row.Country,
+	}
+}
+
+// resolveBeneficiary expands a saved beneficiary or validates inline details
+func (s *PayoutService) resolveBeneficiary(row models.PayoutBatchRow) (*models.BeneficiaryDetails, error) {
+	if row.SavedBeneficiaryID != "" {
+		return s.beneficiaries.Details(row.SavedBeneficiaryID, row.Country)
+	}
+	if row.Beneficiary == nil {
+		return nil, errors.New("beneficiary details or savedBeneficiaryId is required")
+	}
+
+	schema, err := s.requirements.Schema(row.Country)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load requirements for %s: %w", row.Country, err)
+	}
+	if err := schema.Validate(*row.Beneficiary); err != nil {
+		return nil, err
+	}
+	return row.Beneficiary, nil
+}
+
+// summarize recomputes counts and totals; callers hold s.mu
+func (s *PayoutService) summarize(b *PayoutBatch) {
+	b.Summary = Summary{}
+	b.Totals = make(map[string]float64)
+	for _, item := range b.Items {
+		b.Summary.Add(item.Status)
+		if item.Status == ItemInvalid || item.Status == ItemSkipped {
+			continue
+		}
+		if item.Input.Amount > 0 {
+			b.Totals[strings.ToUpper(item.Input.FromAsset)] += item.Input.Amount
+		}
+		if item.Input.SettlementAmount > 0 {
+			b.Totals[strings.ToUpper(item.Input.ToCurrency)] += item.Input.SettlementAmount
+		}
+	}
+}
+
+func (s *PayoutService) snapshot(b *PayoutBatch) PayoutBatch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := *b
+	copied.Items = append([]PayoutItem(nil), b.Items...)
+	copied.Totals = make(map[string]float64, len(b.Totals))
+	for k, v := range b.Totals {
+		copied.Totals[k] = v
+	}
+	return copied
+}
+
+func formatFloat(v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func firstNonZero(values ...float64) float64 {
+	for _, v := range values {
+		if v != 0 {
+			return v
+		}
+	}
+	return 0
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
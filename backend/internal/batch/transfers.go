@@ -0,0 +1,518 @@
+package batch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/address"
+	"github.com/bitnob-api-demo/internal/addressbook"
+	"github.com/bitnob-api-demo/internal/models"
+	"github.com/bitnob-api-demo/internal/spreadsheet"
+	"github.com/bitnob-api-demo/internal/store"
+	"github.com/bitnob-api-demo/internal/velocity"
+)
+
+// ErrRejected is returned when a transfer batch fails validation or aggregate checks
+var ErrRejected = errors.New("transfer batch rejected")
+
+// sentRetention is how long a sent transfer's idempotency key is remembered,
+// which bounds how late a resubmitted batch is still recognised
+const sentRetention = 24 * time.Hour
+
+// TransferClient is the subset of the Bitnob client used to execute transfers
+type TransferClient interface {
+	CreateTransfer(req interface{}) (interface{}, error)
+	GetWalletBalances() (interface{}, error)
+}
+
+// Check is the outcome of an aggregate pre-flight check
+type Check struct {
+	Name     string `json:"name"`
+	Currency string `json:"currency"`
+	Passed   bool   `json:"passed"`
+	Message  string `json:"message,omitempty"`
+}
+
+// TransferItem tracks one transfer in a batch
+type TransferItem struct {
+	Index          int                    `json:"index"`
+	Input          models.TransferRequest `json:"input"`
+	Status         ItemStatus             `json:"status"`
+	Errors         []string               `json:"errors,omitempty"`
+	IdempotencyKey string                 `json:"idempotencyKey"`
+	TransactionID  string                 `json:"transactionId,omitempty"`
+	UpstreamStatus string                 `json:"upstreamStatus,omitempty"`
+	Attempts       int                    `json:"attempts"`
+	Error          string                 `json:"error,omitempty"`
+	UpdatedAt      time.Time              `json:"updatedAt"`
+}
+
+// TransferBatch is a set of on-chain sends submitted together
+type TransferBatch struct {
+	ID          string             `json:"id"`
+	Status      Status             `json:"status"`
+	CreatedBy   string             `json:"createdBy"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	CompletedAt *time.Time         `json:"completedAt,omitempty"`
+	Summary     Summary            `json:"summary"`
+	Totals      map[string]float64 `json:"totals"`
+	Checks      []Check            `json:"checks"`
+	Items       []TransferItem     `json:"items,omitempty"`
+}
+
+// TransferService validates and executes transfer batches
+type TransferService struct {
+	ctx         context.Context
+	client      TransferClient
+	network     address.Network
+	book        *addressbook.Book
+	velocity    *velocity.Tracker
+	concurrency int
+	logger      *log.Logger
+
+	mu      sync.Mutex
+	batches *store.Table[*TransferBatch]
+
+	// sent tracks idempotency keys in flight or sent, so a resubmitted batch
+	// never sends a transfer twice
+	sent map[string]sentTransfer
+}
+
+type sentTransfer struct {
+	transactionID string
+	done          bool
+	at            time.Time
+}
+
+// NewTransferService creates a transfer batch service. Batches run until ctx is cancelled.
+func NewTransferService(ctx context.Context, client TransferClient, network address.Network, book *addressbook.Book, tracker *velocity.Tracker, concurrency int) *TransferService {
+	return &TransferService{
+		ctx:         ctx,
+		client:      client,
+		network:     network,
+		book:        book,
+		velocity:    tracker,
+		concurrency: concurrency,
+		batches:     store.NewTable[*TransferBatch](),
+		sent:        make(map[string]sentTransfer),
+		logger:      log.Default(),
+	}
+}
+
+// WithLogger sends logs of batch progress to logger, e.g. one tagged with the tenant
+func (s *TransferService) WithLogger(logger *log.Logger) *TransferService {
+	s.logger = logger
+	return s
+}
+
+// ParseTransfers decodes a JSON array of transfers, or an object with a "transfers" array
+func ParseTransfers(data []byte) ([]models.TransferRequest, error) {
+	var list []models.TransferRequest
+	if err := json.Unmarshal(data, &list); err == nil {
+		return list, nil
+	}
+	var wrapped struct {
+		Transfers []models.TransferRequest `json:"transfers"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err != nil {
+		return nil, fmt.Errorf("invalid JSON body: %w", err)
+	}
+	return wrapped.Transfers, nil
+}
+
+// Submit validates every transfer and the batch as a whole, then starts
+// execution unless dryRun is set. Rejected batches are stored and returned
+// alongside ErrRejected so the caller can report why.
+func (s *TransferService) Submit(reqs []models.TransferRequest, dryRun bool, actor string) (TransferBatch, error) {
+	if len(reqs) == 0 {
+		return TransferBatch{}, errors.New("batch contains no transfers")
+	}
+	if len(reqs) > MaxRows {
+		return TransferBatch{}, fmt.Errorf("batch has %d transfers, the maximum is %d", len(reqs), MaxRows)
+	}
+
+	now := time.Now().UTC()
+	b := &TransferBatch{
+		ID:        store.NewID("tbat"),
+		Status:    StatusDryRun,
+		CreatedBy: actor,
+		CreatedAt: now,
+		Items:     make([]TransferItem, len(reqs)),
+	}
+
+	keys := make(map[string]int)
+	occurrences := make(map[string]int)
+	for i, req := range reqs {
+		key := req.Reference
+		if key == "" {
+			key = contentKey(req, occurrences)
+		}
+		req.Reference = key
+		req.IdempotencyKey = key
+
+		item := TransferItem{Index: i, Input: req, Status: ItemValid, IdempotencyKey: key, UpdatedAt: now}
+		item.Errors = s.validate(req, now)
+		if first, dup := keys[key]; dup {
+			item.Errors = append(item.Errors, fmt.Sprintf("reference duplicates transfer %d", first))
+		}
+		keys[key] = i
+		if len(item.Errors) > 0 {
+			item.Status = ItemInvalid
+		}
+		b.Items[i] = item
+	}
+	s.summarize(b)
+	b.Checks = s.aggregateChecks(b.Totals, now)
+
+	rejected := b.Summary.Invalid > 0
+	for _, check := range b.Checks {
+		rejected = rejected || !check.Passed
+	}
+
+	switch {
+	case rejected:
+		b.Status = StatusRejected
+	case !dryRun:
+		b.Status = StatusRunning
+		for i := range b.Items {
+			b.Items[i].Status = ItemPending
+		}
+		s.summarize(b)
+	}
+
+	s.batches.Put(b.ID, b)
+	if rejected {
+		return s.snapshot(b), ErrRejected
+	}
+	if !dryRun {
+		indexes := make([]int, len(b.Items))
+		for i := range indexes {
+			indexes[i] = i
+		}
+		go s.run(b, indexes)
+	}
+	return s.snapshot(b), nil
+}
+
+// contentKey derives an idempotency key for a transfer without a reference
+// from its contents, so resubmitting the same batch yields the same keys.
+// Identical transfers within a batch are told apart by their occurrence.
+func contentKey(req models.TransferRequest, occurrences map[string]int) string {
+	content := strings.Join([]string{
+		strings.ToLower(strings.TrimSpace(req.Chain)),
+		strings.TrimSpace(req.ToAddress),
+		strings.TrimSpace(req.Amount),
+		strings.ToUpper(strings.TrimSpace(req.Currency)),
+		req.Description,
+	}, "\x00")
+	occurrences[content]++
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d", content, occurrences[content])))
+	return "tx-" + hex.EncodeToString(sum[:12])
+}
+
+// Get returns a copy of a batch
+func (s *TransferService) Get(id string) (TransferBatch, bool) {
+	b, ok := s.batches.Get(id)
+	if !ok {
+		return TransferBatch{}, false
+	}
+	return s.snapshot(b), true
+}
+
+// List returns copies of all batches without their items
+func (s *TransferService) List() []TransferBatch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]TransferBatch, 0)
+	for _, b := range s.batches.List(nil) {
+		copied := *b
+		copied.Items = nil
+		out = append(out, copied)
+	}
+	return out
+}
+
+// WriteReportCSV writes the per-transfer outcome of a batch
+func (s *TransferService) WriteReportCSV(id string, w io.Writer) error {
+	b, ok := s.Get(id)
+	if !ok {
+		return store.ErrNotFound
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"index", "idempotencyKey", "toAddress", "chain", "currency", "amount",
+		"status", "transactionId", "upstreamStatus", "attempts", "error"})
+	for _, item := range b.Items {
+		errText := item.Error
+		if errText == "" && len(item.Errors) > 0 {
+			errText = strings.Join(item.Errors, "; ")
+		}
+		cw.Write([]string{
+			strconv.Itoa(item.Index),
+			item.IdempotencyKey,
+			spreadsheet.Cell(item.Input.ToAddress),
+			spreadsheet.Cell(item.Input.Chain),
+			spreadsheet.Cell(item.Input.Currency),
+			spreadsheet.Cell(item.Input.Amount),
+			string(item.Status),
+			item.TransactionID,
+			item.UpstreamStatus,
+			strconv.Itoa(item.Attempts),
+			spreadsheet.Cell(errText),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (s *TransferService) validate(req models.TransferRequest, now time.Time) []string {
+	var errs []string
+	for _, field := range [][2]string{
+		{"to_address", req.ToAddress},
+		{"amount", req.Amount},
+		{"currency", req.Currency},
+		{"chain", req.Chain},
+	} {
+		if strings.TrimSpace(field[1]) == "" {
+			errs = append(errs, field[0]+" is required")
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if _, err := velocity.ParseAmount(req.Amount); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	result, err := address.Validate(req.Chain, req.ToAddress, s.network)
+	if err != nil {
+		errs = append(errs, err.Error())
+	} else if err := address.CheckInvoiceAmount(result.Invoice, req.Amount, req.Currency); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	if _, err := s.book.Authorize(req.Chain, req.Currency, req.ToAddress, now); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	return errs
+}
+
+// aggregateChecks compares per-currency totals with wallet balances and velocity limits
+func (s *TransferService) aggregateChecks(totals map[string]float64, now time.Time) []Check {
+	checks := make([]Check, 0, len(totals)*2)
+
+	balances, balanceErr := s.balances()
+	if balanceErr != nil {
+		s.logger.Printf("Transfer batch balance check unavailable: %v", balanceErr)
+	}
+
+	for currency, total := range totals {
+		// A batch only runs against a balance that was confirmed
+		balance := Check{Name: "balance", Currency: currency}
+		switch available, ok := balances[currency]; {
+		case balanceErr != nil:
+			balance.Message = "balance could not be verified: " + balanceErr.Error()
+		case !ok:
+			balance.Message = "no wallet balance reported for currency"
+		case total > available:
+			balance.Message = fmt.Sprintf("batch total %g exceeds available balance %g", total, available)
+		default:
+			balance.Passed = true
+			balance.Message = fmt.Sprintf("batch total %g within available balance %g", total, available)
+		}
+
+		limit := Check{Name: "velocity", Currency: currency, Passed: true}
+		if err := s.velocity.Check(currency, total, now); err != nil {
+			limit.Passed = false
+			limit.Message = err.Error()
+		}
+
+		checks = append(checks, balance, limit)
+	}
+
+	return checks
+}
+
+// balances returns available balances keyed by upper-case currency
+func (s *TransferService) balances() (map[string]float64, error) {
+	raw, err := s.client.GetWalletBalances()
+	if err != nil {
+		return nil, err
+	}
+
+	var wallets []map[string]interface{}
+	if err := models.Decode(raw, &wallets); err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]float64)
+	for _, w := range wallets {
+		currency, _ := w["currency"].(string)
+		if currency == "" {
+			continue
+		}
+		for _, key := range []string{"available_balance", "availableBalance", "available", "balance"} {
+			if v, ok := toFloat(w[key]); ok {
+				out[strings.ToUpper(currency)] += v
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+func (s *TransferService) run(b *TransferBatch, indexes []int) {
+	ForEach(s.ctx, indexes, s.concurrency, func(ctx context.Context, i int) {
+		s.execute(b, i)
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	for _, i := range indexes {
+		if item := &b.Items[i]; item.Status == ItemPending {
+			item.Status = ItemFailed
+			item.Error = notStarted
+			item.UpdatedAt = now
+		}
+	}
+	s.summarize(b)
+	b.Status = b.Summary.FinalStatus()
+	b.CompletedAt = &now
+	s.logger.Printf("Transfer batch %s finished: %d succeeded, %d failed", b.ID, b.Summary.Succeeded, b.Summary.Failed)
+}
+
+func (s *TransferService) execute(b *TransferBatch, i int) {
+	s.mu.Lock()
+	item := b.Items[i]
+	if sent, ok := s.sent[item.IdempotencyKey]; ok {
+		// Never resend a transfer that already went through under this key
+		if sent.done {
+			b.Items[i].Status = ItemSucceeded
+			b.Items[i].TransactionID = sent.transactionID
+			b.Items[i].UpstreamStatus = "duplicate"
+		} else {
+			b.Items[i].Status = ItemFailed
+			b.Items[i].Error = "a transfer with idempotency key " + item.IdempotencyKey + " is in flight"
+		}
+		b.Items[i].UpdatedAt = time.Now().UTC()
+		s.summarize(b)
+		s.mu.Unlock()
+		return
+	}
+	// Holding the key while the transfer is in flight stops a concurrent
+	// resubmission from sending it too
+	s.sent[item.IdempotencyKey] = sentTransfer{}
+	b.Items[i].Status = ItemRunning
+	b.Items[i].Attempts++
+	s.mu.Unlock()
+
+	amount, _ := velocity.ParseAmount(item.Input.Amount)
+	reservation, err := s.velocity.Reserve(item.Input.Currency, amount, time.Now())
+	if err != nil {
+		s.unsend(item.IdempotencyKey)
+		s.update(b, i, func(it *TransferItem) {
+			it.Status = ItemFailed
+			it.Error = err.Error()
+		})
+		return
+	}
+
+	raw, err := s.client.CreateTransfer(item.Input)
+	if err != nil {
+		s.velocity.Release(reservation)
+		s.unsend(item.IdempotencyKey)
+		s.update(b, i, func(it *TransferItem) {
+			it.Status = ItemFailed
+			it.Error = err.Error()
+		})
+		return
+	}
+
+	var resp models.TransferResponse
+	if err := models.Decode(raw, &resp); err != nil {
+		s.logger.Printf("Transfer batch %s item %d: could not decode response: %v", b.ID, i, err)
+	}
+	s.mu.Lock()
+	for key, old := range s.sent {
+		if old.done && time.Since(old.at) > sentRetention {
+			delete(s.sent, key)
+		}
+	}
+	s.sent[item.IdempotencyKey] = sentTransfer{transactionID: resp.TransactionID, done: true, at: time.Now()}
+	s.mu.Unlock()
+	s.update(b, i, func(it *TransferItem) {
+		it.Status = ItemSucceeded
+		it.TransactionID = resp.TransactionID
+		it.UpstreamStatus = resp.Status
+		it.Error = ""
+	})
+}
+
+// unsend frees an idempotency key whose transfer was not sent
+func (s *TransferService) unsend(key string) {
+	s.mu.Lock()
+	delete(s.sent, key)
+	s.mu.Unlock()
+}
+
+func (s *TransferService) update(b *TransferBatch, i int, fn func(*TransferItem)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&b.Items[i])
+	b.Items[i].UpdatedAt = time.Now().UTC()
+	s.summarize(b)
+}
+
+// summarize recomputes counts and totals; callers hold s.mu or own b exclusively
+func (s *TransferService) summarize(b *TransferBatch) {
+	b.Summary = Summary{}
+	b.Totals = make(map[string]float64)
+	for _, item := range b.Items {
+		b.Summary.Add(item.Status)
+		if item.Status == ItemInvalid {
+			continue
+		}
+		if amount, err := velocity.ParseAmount(item.Input.Amount); err == nil {
+			b.Totals[strings.ToUpper(item.Input.Currency)] += amount
+		}
+	}
+}
+
+func (s *TransferService) snapshot(b *TransferBatch) TransferBatch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := *b
+	copied.Items = append([]TransferItem(nil), b.Items...)
+	copied.Checks = append([]Check(nil), b.Checks...)
+	copied.Totals = make(map[string]float64, len(b.Totals))
+	for k, v := range b.Totals {
+		copied.Totals[k] = v
+	}
+	return copied
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
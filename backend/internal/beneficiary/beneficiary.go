@@ -0,0 +1,251 @@
+package beneficiary
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/audit"
+	"github.com/bitnob-api-demo/internal/models"
+	"github.com/bitnob-api-demo/internal/requirements"
+	"github.com/bitnob-api-demo/internal/secretbox"
+	"github.com/bitnob-api-demo/internal/store"
+)
+
+// auditResource is the resource name used for beneficiary audit entries
+const auditResource = "beneficiary"
+
+// Name verification states
+const (
+	VerificationUnverified = "unverified"
+	VerificationPending    = "pending"
+	VerificationVerified   = "verified"
+	VerificationFailed     = "failed"
+)
+
+var ErrCountryMismatch = errors.New("beneficiary is registered for a different country")
+
+// Beneficiary is a saved payout recipient. The account number is stored
+// encrypted and only exposed in masked form.
+type Beneficiary struct {
+	ID                 string    `json:"id"`
+	Label              string    `json:"label"`
+	Country            string    `json:"country"`
+	CustomerID         string    `json:"customerId,omitempty"`
+	Type               string    `json:"type"`
+	BankCode           string    `json:"bankCode,omitempty"`
+	AccountName        string    `json:"accountName,omitempty"`
+	AccountNumberLast4 string    `json:"accountNumberLast4,omitempty"`
+	Network            string    `json:"network,omitempty"`
+	PhoneNumber        string    `json:"phoneNumber,omitempty"`
+	VerificationStatus string    `json:"verificationStatus"`
+	VerifiedName       string    `json:"verifiedName,omitempty"`
+	VerifiedAt         time.Time `json:"verifiedAt,omitempty"`
+	CreatedBy          string    `json:"createdBy"`
+	CreatedAt          time.Time `json:"createdAt"`
+	UpdatedAt          time.Time `json:"updatedAt"`
+
+	accountNumber string
+}
+
+// Store holds saved beneficiaries
+type Store struct {
+	rows         *store.Table[Beneficiary]
+	box          *secretbox.Box
+	requirements requirements.SchemaProvider
+	audit        *audit.Log
+}
+
+// NewStore creates an empty beneficiary store
+func NewStore(box *secretbox.Box, src requirements.SchemaProvider, auditLog *audit.Log) *Store {
+	return &Store{
+		rows:         store.NewTable[Beneficiary](),
+		box:          box,
+		requirements: src,
+		audit:        auditLog,
+	}
+}
+
+// Create validates the details against the country's requirements and saves them
+func (s *Store) Create(label, country, customerID string, details models.BeneficiaryDetails, actor string) (Beneficiary, error) {
+	country = strings.ToUpper(country)
+	if err := s.validate(country, details); err != nil {
+		return Beneficiary{}, err
+	}
+
+	now := time.Now().UTC()
+	b := Beneficiary{
+		ID:                 store.NewID("ben"),
+		Label:              label,
+		Country:            country,
+		CustomerID:         customerID,
+		VerificationStatus: VerificationUnverified,
+		CreatedBy:          actor,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	if err := s.applyDetails(&b, details); err != nil {
+		return Beneficiary{}, err
+	}
+
+	s.rows.Put(b.ID, b)
+	s.audit.Record(actor, "create", auditResource, b.ID, nil, b)
+	return b, nil
+}
+
+// Get returns a beneficiary by ID
+func (s *Store) Get(id string) (Beneficiary, bool) {
+	return s.rows.Get(id)
+}
+
+// List returns beneficiaries matching the non-empty filters
+func (s *Store) List(country, customerID string) []Beneficiary {
+	return s.rows.List(func(b Beneficiary) bool {
+		return (country == "" || strings.EqualFold(b.Country, country)) &&
+			(customerID == "" || b.CustomerID == customerID)
+	})
+}
+
+// Update revalidates and replaces a beneficiary's details. Changing the
+// account resets its name-verification status.
+func (s *Store) Update(id, label, country, customerID string, details models.BeneficiaryDetails, actor string) (Beneficiary, error) {
+	country = strings.ToUpper(country)
+	if err := s.validate(country, details); err != nil {
+		return Beneficiary{}, err
+	}
+
+	var before Beneficiary
+	updated, err := s.rows.Update(id, func(b *Beneficiary) error {
+		before = *b
+		accountChanged := b.BankCode != details.BankCode || b.PhoneNumber != details.PhoneNumber ||
+			b.Network != details.Network || s.decrypt(b.accountNumber) != details.AccountNumber
+		b.Label = label
+		b.Country = country
+		b.CustomerID = customerID
+		if err := s.applyDetails(b, details); err != nil {
+			return err
+		}
+		if accountChanged {
+			b.VerificationStatus = VerificationUnverified
+			b.VerifiedName = ""
+			b.VerifiedAt = time.Time{}
+		}
+		b.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+	if err != nil {
+		return Beneficiary{}, err
+	}
+
+	s.audit.Record(actor, "update", auditResource, id, before, updated)
+	return updated, nil
+}
+
+// Delete removes a beneficiary
+func (s *Store) Delete(id, actor string) error {
+	removed, ok := s.rows.Delete(id)
+	if !ok {
+		return store.ErrNotFound
+	}
+	s.audit.Record(actor, "delete", auditResource, id, removed, nil)
+	return nil
+}
+
+// SetVerification records the outcome of an account-name check
+func (s *Store) SetVerification(id, status, verifiedName, actor string) (Beneficiary, error) {
+	switch status {
+	case VerificationUnverified, VerificationPending, VerificationVerified, VerificationFailed:
+	default:
+		return Beneficiary{}, fmt.Errorf("invalid verification status %q", status)
+	}
+
+	var before Beneficiary
+	updated, err := s.rows.Update(id, func(b *Beneficiary) error {
+		before = *b
+		b.VerificationStatus = status
+		b.VerifiedName = verifiedName
+		b.VerifiedAt = time.Now().UTC()
+		b.UpdatedAt = b.VerifiedAt
+		return nil
+	})
+	if err != nil {
+		return Beneficiary{}, err
+	}
+
+	s.audit.Record(actor, "verify", auditResource, id, before, updated)
+	return updated, nil
+}
+
+// Details returns the decrypted beneficiary details for a payout to country
+func (s *Store) Details(id, country string) (*models.BeneficiaryDetails, error) {
+	b, ok := s.rows.Get(id)
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	if country != "" && !strings.EqualFold(b.Country, country) {
+		return nil, ErrCountryMismatch
+	}
+
+	details := &models.BeneficiaryDetails{
+		Type:        b.Type,
+		BankCode:    b.BankCode,
+		AccountName: b.AccountName,
+		Network:     b.Network,
+		PhoneNumber: b.PhoneNumber,
+	}
+	if b.accountNumber != "" {
+		number, err := s.box.Open(b.accountNumber)
+		if err != nil {
+			return nil, err
+		}
+		details.AccountNumber = number
+	}
+	return details, nil
+}
+
+func (s *Store) validate(country string, details models.BeneficiaryDetails) error {
+	schema, err := s.requirements.Schema(country)
+	if err != nil {
+		return fmt.Errorf("failed to load requirements for %s: %w", country, err)
+	}
+	return schema.Validate(details)
+}
+
+func (s *Store) applyDetails(b *Beneficiary, details models.BeneficiaryDetails) error {
+	b.Type = details.Type
+	b.BankCode = details.BankCode
+	b.AccountName = details.AccountName
+	b.Network = details.Network
+	b.PhoneNumber = details.PhoneNumber
+	b.accountNumber = ""
+	b.AccountNumberLast4 = ""
+
+	if details.AccountNumber != "" {
+		sealed, err := s.box.Seal(details.AccountNumber)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt account number: %w", err)
+		}
+		b.accountNumber = sealed
+		b.AccountNumberLast4 = last4(details.AccountNumber)
+	}
+	return nil
+}
+
+func (s *Store) decrypt(sealed string) string {
+	if sealed == "" {
+		return ""
+	}
+	plain, err := s.box.Open(sealed)
+	if err != nil {
+		return ""
+	}
+	return plain
+}
+
+func last4(s string) string {
+	if len(s) <= 4 {
+		return s
+	}
+	return s[len(s)-4:]
+}
@@ -7,32 +7,57 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/bitnob-api-demo/internal/models"
 )
 
+// APIError is a non-2xx response from Bitnob
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
 type Client struct {
-	baseURL      string
-	clientID     string
-	clientSecret string
-	httpClient   *http.Client
+	baseURL    string
+	keys       atomic.Pointer[keyring]
+	keysMu     sync.Mutex
+	retired    map[string]bool
+	usage      keyUsage
+	httpClient *http.Client
+	logger     *log.Logger
 }
 
 // NewClient creates a new Bitnob API client
 func NewClient(baseURL, clientID, clientSecret string) *Client {
-	return &Client{
-		baseURL:      baseURL,
-		clientID:     clientID,
-		clientSecret: clientSecret,
+	c := &Client{
+		baseURL: baseURL,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		logger: log.Default(),
 	}
+	c.SetCredentials(clientID, clientSecret)
+	return c
 }
 
-// makeRequest is a generic method to make authenticated requests to Bitnob API
-func (c *Client) makeRequest(method, endpoint string, body interface{}, response interface{}) error {
-	var payload string
-	var bodyReader io.Reader
+// WithLogger sends request logs to logger, e.g. one tagged with the tenant
+func (c *Client) WithLogger(logger *log.Logger) *Client {
+	c.logger = logger
+	return c
+}
+
+// makeRequest is a generic method to make authenticated requests to Bitnob API.
+// A request rejected for its credentials is retried once with the other key
+// pair, so calls keep working while keys are rotated.
+func (c *Client) makeRequest(method, endpoint string, body interface{}, header http.Header, response interface{}) error {
+	var payload []byte
 
 	// Prepare payload
 	if body != nil {
@@ -40,18 +65,38 @@ func (c *Client) makeRequest(method, endpoint string, body interface{}, response
 		if err != nil {
 			return fmt.Errorf("failed to marshal request body: %w", err)
 		}
-		payload = string(jsonData)
-		bodyReader = bytes.NewBuffer(jsonData)
-	} else {
-		payload = ""
-		bodyReader = nil
+		payload = jsonData
+	}
+
+	c.logger.Printf("Making request to: %s%s", c.baseURL, endpoint)
+	c.logger.Printf("Request payload: %s", redact(payload))
+
+	creds := c.keys.Load().primary
+	err := c.send(method, endpoint, payload, header, creds, response)
+	c.usage.record(creds, err, false)
+	if !IsAuthFailure(err) {
+		return err
 	}
 
-	log.Printf("Making request to: %s%s", c.baseURL, endpoint)
-	log.Printf("Request payload: %s", payload)
+	retry := c.keys.Load().other(creds)
+	if retry == nil {
+		return err
+	}
+	c.logger.Printf("Key %s was rejected, retrying with key %s", creds.clientID, retry.clientID)
+	err = c.send(method, endpoint, payload, header, retry, response)
+	c.usage.record(retry, err, true)
+	return err
+}
+
+// send signs and makes one request with creds
+func (c *Client) send(method, endpoint string, payload []byte, header http.Header, creds *credentials, response interface{}) error {
+	var bodyReader io.Reader
+	if payload != nil {
+		bodyReader = bytes.NewReader(payload)
+	}
 
 	// Generate auth headers
-	authHeaders, err := GenerateAuthHeaders(c.clientID, c.clientSecret, payload)
+	authHeaders, err := GenerateAuthHeaders(creds.clientID, creds.clientSecret, string(payload))
 	if err != nil {
 		return fmt.Errorf("failed to generate auth headers: %w", err)
 	}
@@ -69,6 +114,9 @@ func (c *Client) makeRequest(method, endpoint string, body interface{}, response
 	req.Header.Set("X-Auth-Timestamp", authHeaders.Timestamp)
 	req.Header.Set("X-Auth-Nonce", authHeaders.Nonce)
 	req.Header.Set("X-Auth-Signature", authHeaders.Signature)
+	for name, values := range header {
+		req.Header[name] = values
+	}
 
 	// Make request
 	resp, err := c.httpClient.Do(req)
@@ -84,11 +132,11 @@ func (c *Client) makeRequest(method, endpoint string, body interface{}, response
 	}
 
 	// Check status code
-	log.Printf("Response status: %d", resp.StatusCode)
-	log.Printf("Response body: %s", string(respBody))
+	c.logger.Printf("Response status: %d", resp.StatusCode)
+	c.logger.Printf("Response body: %s", redact(respBody))
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(respBody))
+		return &APIError{StatusCode: resp.StatusCode, Body: string(respBody)}
 	}
 
 	// Parse response
@@ -103,18 +151,28 @@ func (c *Client) makeRequest(method, endpoint string, body interface{}, response
 
 // GET makes a GET request
 func (c *Client) GET(endpoint string, response interface{}) error {
-	return c.makeRequest(http.MethodGet, endpoint, nil, response)
+	return c.makeRequest(http.MethodGet, endpoint, nil, nil, response)
 }
 
 // POST makes a POST request
 func (c *Client) POST(endpoint string, body interface{}, response interface{}) error {
-	return c.makeRequest(http.MethodPost, endpoint, body, response)
+	return c.makeRequest(http.MethodPost, endpoint, body, nil, response)
 }
 
 // Transfer Methods
 func (c *Client) CreateTransfer(req interface{}) (interface{}, error) {
 	var response interface{}
-	err := c.POST("/api/wallets/transfers", req, &response)
+	var header http.Header
+	if t, ok := req.(models.TransferRequest); ok && t.IdempotencyKey != "" {
+		header = http.Header{"Idempotency-Key": {t.IdempotencyKey}}
+	}
+	err := c.makeRequest(http.MethodPost, "/api/wallets/transfers", req, header, &response)
+	return response, err
+}
+
+func (c *Client) GetWalletBalances() (interface{}, error) {
+	var response interface{}
+	err := c.GET("/api/wallets/balances", &response)
 	return response, err
 }
 
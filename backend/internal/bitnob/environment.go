@@ -0,0 +1,78 @@
+package bitnob
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Environments
+const (
+	Sandbox    = "sandbox"
+	Production = "production"
+)
+
+// BaseURLs are the API hosts of each environment
+var BaseURLs = map[string]string{
+	Sandbox:    "https://sandboxapi.bitnob.co",
+	Production: "https://api.bitnob.co",
+}
+
+// probeTimeout bounds each request of the startup environment check
+const probeTimeout = 10 * time.Second
+
+var (
+	ErrInvalidEnvironment = errors.New("environment must be sandbox or production")
+	ErrWrongEnvironment   = errors.New("credentials do not match the declared environment")
+	ErrUnverified         = errors.New("environment could not be verified")
+)
+
+// ParseEnvironment normalizes an environment name
+func ParseEnvironment(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if _, ok := BaseURLs[name]; !ok {
+		return "", fmt.Errorf("%w, got %q", ErrInvalidEnvironment, name)
+	}
+	return name, nil
+}
+
+// CheckEnvironment verifies that baseURL and the credentials belong to env.
+// The credentials must authenticate against baseURL, and sandbox credentials
+// must not also work in production, which catches a development build
+// pointed at live keys. ErrUnverified is returned when Bitnob cannot be
+// reached, so callers may start anyway.
+func CheckEnvironment(env, baseURL, clientID, clientSecret string) error {
+	baseURL = strings.TrimRight(baseURL, "/")
+	for other, url := range BaseURLs {
+		if other != env && baseURL == url {
+			return fmt.Errorf("%w: %s is the %s API, not %s", ErrWrongEnvironment, baseURL, other, env)
+		}
+	}
+
+	if err := probe(baseURL, clientID, clientSecret); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden) {
+			return fmt.Errorf("%w: %s rejected the credentials with status %d", ErrWrongEnvironment, baseURL, apiErr.StatusCode)
+		}
+		return fmt.Errorf("%w: %v", ErrUnverified, err)
+	}
+
+	if env == Sandbox && baseURL != BaseURLs[Production] {
+		if probe(BaseURLs[Production], clientID, clientSecret) == nil {
+			return fmt.Errorf("%w: the sandbox credentials are accepted by the production API", ErrWrongEnvironment)
+		}
+	}
+	return nil
+}
+
+// probe makes an authenticated read that moves no funds
+func probe(baseURL, clientID, clientSecret string) error {
+	c := NewClient(baseURL, clientID, clientSecret).WithLogger(log.New(io.Discard, "", 0))
+	c.httpClient.Timeout = probeTimeout
+	_, err := c.GetWalletBalances()
+	return err
+}
@@ -0,0 +1,226 @@
+package bitnob
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Key roles
+const (
+	KeyPrimary   = "primary"
+	KeySecondary = "secondary"
+	KeyRetired   = "retired"
+)
+
+var ErrNoSecondary = errors.New("no secondary credentials are staged")
+
+// credentials are swapped as a pair so a request never signs with a
+// mismatched ID and secret
+type credentials struct {
+	clientID     string
+	clientSecret string
+	fingerprint  string
+}
+
+func newCredentials(clientID, clientSecret string) *credentials {
+	sum := sha256.Sum256([]byte(clientID + ":" + clientSecret))
+	return &credentials{clientID: clientID, clientSecret: clientSecret, fingerprint: hex.EncodeToString(sum[:4])}
+}
+
+// keyring holds the primary pair and, during a rotation, the secondary pair
+// Bitnob also accepts
+type keyring struct {
+	primary   *credentials
+	secondary *credentials
+}
+
+// other returns the pair to retry with after used was rejected
+func (k *keyring) other(used *credentials) *credentials {
+	if k.primary.fingerprint != used.fingerprint {
+		return k.primary
+	}
+	return k.secondary
+}
+
+func (k *keyring) role(fingerprint string) string {
+	switch {
+	case k.primary.fingerprint == fingerprint:
+		return KeyPrimary
+	case k.secondary != nil && k.secondary.fingerprint == fingerprint:
+		return KeySecondary
+	}
+	return KeyRetired
+}
+
+// KeyInfo identifies a key pair without its secret. The fingerprint tells
+// apart pairs that share a client ID.
+type KeyInfo struct {
+	ClientID    string `json:"client_id"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+func (c *credentials) info() KeyInfo {
+	return KeyInfo{ClientID: c.clientID, Fingerprint: c.fingerprint}
+}
+
+// KeyUsage counts the calls a key pair signed
+type KeyUsage struct {
+	KeyInfo
+	Role         string     `json:"role"`
+	Requests     int        `json:"requests"`
+	Succeeded    int        `json:"succeeded"`
+	AuthFailures int        `json:"auth_failures"`
+	Failed       int        `json:"failed"`
+	Retries      int        `json:"retries"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+}
+
+// KeyStatus is the current key pairs and the usage of every pair the client
+// has signed with
+type KeyStatus struct {
+	Primary   KeyInfo    `json:"primary"`
+	Secondary *KeyInfo   `json:"secondary"`
+	Usage     []KeyUsage `json:"usage"`
+}
+
+// keyUsage tallies calls per key pair fingerprint
+type keyUsage struct {
+	mu    sync.Mutex
+	byKey map[string]*KeyUsage
+}
+
+func (u *keyUsage) record(creds *credentials, err error, retry bool) {
+	now := time.Now().UTC()
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.byKey == nil {
+		u.byKey = make(map[string]*KeyUsage)
+	}
+	usage, ok := u.byKey[creds.fingerprint]
+	if !ok {
+		usage = &KeyUsage{KeyInfo: creds.info()}
+		u.byKey[creds.fingerprint] = usage
+	}
+	usage.Requests++
+	usage.LastUsedAt = &now
+	if retry {
+		usage.Retries++
+	}
+	switch {
+	case err == nil:
+		usage.Succeeded++
+	case IsAuthFailure(err):
+		usage.AuthFailures++
+	default:
+		usage.Failed++
+	}
+}
+
+func (u *keyUsage) snapshot(keys *keyring) []KeyUsage {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	out := make([]KeyUsage, 0, len(u.byKey))
+	for _, usage := range u.byKey {
+		entry := *usage
+		entry.Role = keys.role(entry.Fingerprint)
+		out = append(out, entry)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Fingerprint < out[j].Fingerprint
+	})
+	return out
+}
+
+// IsAuthFailure reports whether Bitnob rejected the request's credentials
+func IsAuthFailure(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden)
+}
+
+// SetCredentials replaces the primary key pair; requests already signed keep
+// the previous pair. A pair retired by Promote is never reinstated, so a
+// credentials source still holding it cannot undo the promotion.
+func (c *Client) SetCredentials(clientID, clientSecret string) {
+	c.keysMu.Lock()
+	defer c.keysMu.Unlock()
+
+	next := &keyring{primary: newCredentials(clientID, clientSecret)}
+	if c.retired[next.primary.fingerprint] {
+		c.logger.Printf("Ignoring retired Bitnob key %s from the credentials source", clientID)
+		return
+	}
+	if current := c.keys.Load(); current != nil && current.secondary != nil && current.secondary.fingerprint != next.primary.fingerprint {
+		next.secondary = current.secondary
+	}
+	c.keys.Store(next)
+}
+
+// SetSecondary stages a second key pair for a rotation. Requests still sign
+// with the primary pair and retry with the secondary one on an auth failure.
+// Staging the primary pair clears the secondary.
+func (c *Client) SetSecondary(clientID, clientSecret string) {
+	c.keysMu.Lock()
+	defer c.keysMu.Unlock()
+
+	current := c.keys.Load()
+	next := &keyring{primary: current.primary, secondary: newCredentials(clientID, clientSecret)}
+	if next.secondary.fingerprint == current.primary.fingerprint {
+		next.secondary = nil
+	}
+	c.keys.Store(next)
+}
+
+// ClearSecondary drops the staged secondary pair, which is returned
+func (c *Client) ClearSecondary() (KeyInfo, error) {
+	c.keysMu.Lock()
+	defer c.keysMu.Unlock()
+
+	current := c.keys.Load()
+	if current.secondary == nil {
+		return KeyInfo{}, ErrNoSecondary
+	}
+	c.keys.Store(&keyring{primary: current.primary})
+	return current.secondary.info(), nil
+}
+
+// Promote makes the secondary pair primary and retires the old primary,
+// which is returned. The promotion holds until the primary credentials
+// source supplies another new pair or the process restarts, so the source
+// should be updated to the promoted pair.
+func (c *Client) Promote() (KeyInfo, error) {
+	c.keysMu.Lock()
+	defer c.keysMu.Unlock()
+
+	current := c.keys.Load()
+	if current.secondary == nil {
+		return KeyInfo{}, ErrNoSecondary
+	}
+	if c.retired == nil {
+		c.retired = make(map[string]bool)
+	}
+	c.retired[current.primary.fingerprint] = true
+	c.keys.Store(&keyring{primary: current.secondary})
+	c.logger.Printf("Promoted Bitnob key %s, retired %s", current.secondary.clientID, current.primary.clientID)
+	return current.primary.info(), nil
+}
+
+// Keys reports the current key pairs and how often each pair signed a call
+func (c *Client) Keys() KeyStatus {
+	keys := c.keys.Load()
+	status := KeyStatus{
+		Primary: keys.primary.info(),
+		Usage:   c.usage.snapshot(keys),
+	}
+	if keys.secondary != nil {
+		info := keys.secondary.info()
+		status.Secondary = &info
+	}
+	return status
+}
@@ -0,0 +1,58 @@
+package bitnob
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// redactedFields are masked in logged payloads, matched case-insensitively
+// and without underscores
+var redactedFields = map[string]bool{
+	"accountnumber": true,
+	"iban":          true,
+}
+
+// redact masks account numbers in a JSON payload before it is logged,
+// keeping the last four characters. Other payloads are returned unchanged.
+func redact(payload []byte) string {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	if len(payload) == 0 || dec.Decode(&v) != nil || !redactValue(v) {
+		return string(payload)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return "[redacted]"
+	}
+	return string(out)
+}
+
+// redactValue masks sensitive fields in place and reports whether any were found
+func redactValue(v interface{}) bool {
+	found := false
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for key, value := range t {
+			if s, ok := value.(string); ok && redactedFields[strings.ToLower(strings.ReplaceAll(key, "_", ""))] {
+				t[key] = maskValue(s)
+				found = true
+				continue
+			}
+			found = redactValue(value) || found
+		}
+	case []interface{}:
+		for _, value := range t {
+			found = redactValue(value) || found
+		}
+	}
+	return found
+}
+
+func maskValue(s string) string {
+	if len(s) <= 4 {
+		return strings.Repeat("*", len(s))
+	}
+	return strings.Repeat("*", len(s)-4) + s[len(s)-4:]
+}
@@ -0,0 +1,221 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// maxEntries bounds the number of keys, since keys may come from callers
+	maxEntries = 1024
+
+	// maxErrorTTL caps how long a failed load is remembered
+	maxErrorTTL = 30 * time.Second
+)
+
+// Loader fetches the value for a key from the origin
+type Loader[V any] func(key string) (V, error)
+
+type entry[V any] struct {
+	value    V
+	err      error
+	loadedAt time.Time
+	readAt   time.Time
+	failedAt time.Time
+}
+
+// call is a load in progress that concurrent readers of the key wait on
+type call[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// TTL is a read-through cache. Entries older than the TTL are reloaded on
+// access, and a background refresher reloads entries before they expire so
+// that callers rarely wait on the origin. Concurrent misses share one load,
+// failed loads are remembered briefly, and keys nobody reads are dropped.
+type TTL[V any] struct {
+	mu       sync.RWMutex
+	ttl      time.Duration
+	errorTTL time.Duration
+	idle     time.Duration
+	load     Loader[V]
+	entries  map[string]*entry[V]
+	inflight map[string]*call[V]
+	logger   *log.Logger
+}
+
+// NewTTL creates a cache that loads missing or expired keys with load. Keys
+// not read for three TTLs are no longer refreshed and are evicted.
+func NewTTL[V any](ttl time.Duration, load Loader[V]) *TTL[V] {
+	errorTTL := ttl / 10
+	if errorTTL > maxErrorTTL {
+		errorTTL = maxErrorTTL
+	}
+	return &TTL[V]{
+		ttl:      ttl,
+		errorTTL: errorTTL,
+		idle:     3 * ttl,
+		load:     load,
+		entries:  make(map[string]*entry[V]),
+		inflight: make(map[string]*call[V]),
+		logger:   log.Default(),
+	}
+}
+
+// WithLogger sends stale reads and refresh failures to logger
+func (c *TTL[V]) WithLogger(logger *log.Logger) *TTL[V] {
+	c.logger = logger
+	return c
+}
+
+// Get returns the cached value, loading it if missing or expired. If the
+// reload fails, an expired value is served rather than an error.
+func (c *TTL[V]) Get(key string) (V, error) {
+	now := time.Now()
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if ok {
+		e.readAt = now
+	}
+	var cached entry[V]
+	if ok {
+		cached = *e
+	}
+	c.mu.Unlock()
+
+	switch {
+	case ok && cached.err == nil && now.Sub(cached.loadedAt) < c.ttl:
+		return cached.value, nil
+	case ok && cached.err != nil && now.Sub(cached.loadedAt) < c.errorTTL:
+		return cached.value, cached.err
+	case ok && cached.err == nil && now.Sub(cached.failedAt) < c.errorTTL:
+		return cached.value, nil
+	}
+
+	value, err := c.refresh(key)
+	if err != nil && ok && cached.err == nil {
+		c.logger.Printf("Serving stale cache entry for %q: %v", key, err)
+		return cached.value, nil
+	}
+	return value, err
+}
+
+// Invalidate drops a cached key
+func (c *TTL[V]) Invalidate(key string) {
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.mu.Unlock()
+}
+
+// LoadedAt reports when the key was last loaded
+func (c *TTL[V]) LoadedAt(key string) (time.Time, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return time.Time{}, false
+	}
+	return e.loadedAt, true
+}
+
+// StartRefresher reloads every cached key once it is older than refreshAfter,
+// checking at the given interval until ctx is cancelled
+func (c *TTL[V]) StartRefresher(ctx context.Context, interval, refreshAfter time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				for _, key := range c.staleKeys(refreshAfter) {
+					if _, err := c.refresh(key); err != nil {
+						c.logger.Printf("Cache refresh failed for %q: %v", key, err)
+					}
+				}
+			}
+		}
+	}()
+}
+
+// staleKeys evicts idle keys and returns the others older than age
+func (c *TTL[V]) staleKeys(age time.Duration) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	keys := make([]string, 0)
+	for key, e := range c.entries {
+		switch {
+		case now.Sub(e.readAt) >= c.idle:
+			delete(c.entries, key)
+		case e.err == nil && now.Sub(e.loadedAt) >= age && c.inflight[key] == nil:
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// refresh loads key, sharing the load with any concurrent caller
+func (c *TTL[V]) refresh(key string) (V, error) {
+	c.mu.Lock()
+	if pending, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		<-pending.done
+		return pending.value, pending.err
+	}
+	pending := &call[V]{done: make(chan struct{})}
+	c.inflight[key] = pending
+	c.mu.Unlock()
+
+	pending.value, pending.err = c.load(key)
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	c.store(key, pending.value, pending.err)
+	c.mu.Unlock()
+	close(pending.done)
+
+	return pending.value, pending.err
+}
+
+// store records a load result; c.mu must be held. A failure never replaces
+// a good value, which is still served while stale.
+func (c *TTL[V]) store(key string, value V, err error) {
+	now := time.Now()
+	e, ok := c.entries[key]
+	if !ok {
+		if len(c.entries) >= maxEntries {
+			c.evictOldestRead()
+		}
+		e = &entry[V]{readAt: now}
+		c.entries[key] = e
+	}
+	if err != nil {
+		if ok && e.err == nil {
+			e.failedAt = now
+			return
+		}
+		var zero V
+		e.value, e.err, e.loadedAt = zero, err, now
+		return
+	}
+	e.value, e.err, e.loadedAt, e.failedAt = value, nil, now, time.Time{}
+}
+
+// evictOldestRead drops the least recently read key; c.mu must be held
+func (c *TTL[V]) evictOldestRead() {
+	var oldest string
+	var oldestAt time.Time
+	for key, e := range c.entries {
+		if oldest == "" || e.readAt.Before(oldestAt) {
+			oldest, oldestAt = key, e.readAt
+		}
+	}
+	delete(c.entries, oldest)
+}
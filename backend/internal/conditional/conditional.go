@@ -0,0 +1,424 @@
+package conditional
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/audit"
+	"github.com/bitnob-api-demo/internal/models"
+	"github.com/bitnob-api-demo/internal/schedule"
+	"github.com/bitnob-api-demo/internal/store"
+)
+
+// auditResource is the resource name used for conditional order audit entries
+const auditResource = "conditional_order"
+
+// Order types
+const (
+	TypeLimit      = "limit"
+	TypeStopLoss   = "stop_loss"
+	TypeTakeProfit = "take_profit"
+	TypeOCO        = "oco"
+)
+
+// Order states
+const (
+	StatusOpen      = "open"
+	StatusTriggered = "triggered"
+	StatusExecuted  = "executed"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+	StatusExpired   = "expired"
+)
+
+// History event types
+const (
+	EventCreated         = "created"
+	EventTriggered       = "triggered"
+	EventExecuted        = "executed"
+	EventExecutionFailed = "execution_failed"
+	EventQuoteFailed     = "quote_failed"
+	EventCancelled       = "cancelled"
+	EventExpired         = "expired"
+)
+
+// maxAttempts is how many times a triggered order is tried before it fails
+const maxAttempts = 3
+
+var ErrNotOpen = errors.New("order is no longer open")
+
+// TradingClient is the subset of the Bitnob client used to watch prices and execute
+type TradingClient interface {
+	CreateTradingQuote(req interface{}) (interface{}, error)
+	CreateOrder(req interface{}) (interface{}, error)
+}
+
+// Request describes a new conditional order. OCO requests set both
+// TakeProfitPrice and StopPrice and create a linked pair of orders.
+type Request struct {
+	Type            string     `json:"type" binding:"required"`
+	BaseCurrency    string     `json:"base_currency" binding:"required"`
+	QuoteCurrency   string     `json:"quote_currency" binding:"required"`
+	Side            string     `json:"side" binding:"required"`
+	Quantity        string     `json:"quantity" binding:"required"`
+	TriggerPrice    float64    `json:"trigger_price,omitempty"`
+	TakeProfitPrice float64    `json:"take_profit_price,omitempty"`
+	StopPrice       float64    `json:"stop_price,omitempty"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+}
+
+// Event is one entry in an order's trigger history
+type Event struct {
+	At      time.Time `json:"at"`
+	Type    string    `json:"type"`
+	Price   float64   `json:"price,omitempty"`
+	QuoteID string    `json:"quote_id,omitempty"`
+	OrderID string    `json:"order_id,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+// Order is a conditional order held by the gateway until its trigger is crossed
+type Order struct {
+	ID            string     `json:"id"`
+	Type          string     `json:"type"`
+	OCOGroup      string     `json:"oco_group,omitempty"`
+	BaseCurrency  string     `json:"base_currency"`
+	QuoteCurrency string     `json:"quote_currency"`
+	Side          string     `json:"side"`
+	Quantity      string     `json:"quantity"`
+	TriggerPrice  float64    `json:"trigger_price"`
+	Direction     string     `json:"direction"`
+	Status        string     `json:"status"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	CreatedBy     string     `json:"created_by"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	LastPrice     float64    `json:"last_price,omitempty"`
+	LastCheckedAt *time.Time `json:"last_checked_at,omitempty"`
+	Checks        int        `json:"checks"`
+	Attempts      int        `json:"attempts"`
+	ExecutedOrder string     `json:"executed_order_id,omitempty"`
+	History       []Event    `json:"history"`
+}
+
+// Service holds conditional orders and executes them when triggered
+type Service struct {
+	client TradingClient
+	audit  *audit.Log
+	logger *log.Logger
+
+	mu     sync.Mutex
+	orders *store.Table[*Order]
+}
+
+// NewService creates an empty conditional order book
+func NewService(client TradingClient, auditLog *audit.Log) *Service {
+	return &Service{
+		client: client,
+		audit:  auditLog,
+		orders: store.NewTable[*Order](),
+		logger: log.Default(),
+	}
+}
+
+// WithLogger sends logs of executions to logger, e.g. one tagged with the tenant
+func (s *Service) WithLogger(logger *log.Logger) *Service {
+	s.logger = logger
+	return s
+}
+
+// Start polls prices for open orders at the given interval until ctx is cancelled
+func (s *Service) Start(ctx context.Context, interval time.Duration) {
+	schedule.Every(ctx, interval, s.poll)
+}
+
+// Create validates a request and stores the resulting order, or both legs of an OCO pair
+func (s *Service) Create(req Request, actor string) ([]Order, error) {
+	req.Type = strings.ToLower(strings.TrimSpace(req.Type))
+	req.Side = strings.ToLower(strings.TrimSpace(req.Side))
+	req.BaseCurrency = strings.ToUpper(strings.TrimSpace(req.BaseCurrency))
+	req.QuoteCurrency = strings.ToUpper(strings.TrimSpace(req.QuoteCurrency))
+
+	if req.Side != "buy" && req.Side != "sell" {
+		return nil, fmt.Errorf("side must be buy or sell, got %q", req.Side)
+	}
+	if qty, err := strconv.ParseFloat(req.Quantity, 64); err != nil || qty <= 0 {
+		return nil, fmt.Errorf("invalid quantity %q", req.Quantity)
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("expires_at must be in the future")
+	}
+
+	type leg struct {
+		kind    string
+		trigger float64
+	}
+	var legs []leg
+	switch req.Type {
+	case TypeLimit, TypeStopLoss, TypeTakeProfit:
+		legs = []leg{{req.Type, req.TriggerPrice}}
+	case TypeOCO:
+		legs = []leg{{TypeTakeProfit, req.TakeProfitPrice}, {TypeStopLoss, req.StopPrice}}
+		if req.TakeProfitPrice > 0 && req.StopPrice > 0 {
+			// A sell takes profit above the stop; a buy takes profit below it
+			if (req.Side == "sell") != (req.TakeProfitPrice > req.StopPrice) {
+				return nil, fmt.Errorf("take_profit_price must be on the profitable side of stop_price for a %s", req.Side)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported order type %q", req.Type)
+	}
+	for _, l := range legs {
+		if l.trigger <= 0 {
+			return nil, fmt.Errorf("%s order requires a positive trigger price", l.kind)
+		}
+	}
+
+	now := time.Now().UTC()
+	group := ""
+	if len(legs) > 1 {
+		group = store.NewID("oco")
+	}
+
+	out := make([]Order, 0, len(legs))
+	for _, l := range legs {
+		o := &Order{
+			ID:            store.NewID("cond"),
+			Type:          l.kind,
+			OCOGroup:      group,
+			BaseCurrency:  req.BaseCurrency,
+			QuoteCurrency: req.QuoteCurrency,
+			Side:          req.Side,
+			Quantity:      req.Quantity,
+			TriggerPrice:  l.trigger,
+			Direction:     direction(l.kind, req.Side),
+			Status:        StatusOpen,
+			ExpiresAt:     req.ExpiresAt,
+			CreatedBy:     actor,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+			History:       []Event{{At: now, Type: EventCreated, Price: l.trigger}},
+		}
+		s.audit.Record(actor, "create", auditResource, o.ID, nil, *o)
+		out = append(out, s.snapshot(o))
+		s.orders.Put(o.ID, o)
+	}
+	return out, nil
+}
+
+// Get returns an order with its full history
+func (s *Service) Get(id string) (Order, bool) {
+	o, ok := s.orders.Get(id)
+	if !ok {
+		return Order{}, false
+	}
+	return s.snapshot(o), true
+}
+
+// List returns orders, optionally filtered by status
+func (s *Service) List(status string) []Order {
+	rows := s.orders.List(nil)
+	out := make([]Order, 0, len(rows))
+	for _, o := range rows {
+		snap := s.snapshot(o)
+		if status == "" || snap.Status == status {
+			out = append(out, snap)
+		}
+	}
+	return out
+}
+
+// Cancel cancels an open order and the other leg of its OCO pair
+func (s *Service) Cancel(id, actor string) (Order, error) {
+	o, ok := s.orders.Get(id)
+	if !ok {
+		return Order{}, store.ErrNotFound
+	}
+
+	s.mu.Lock()
+	if o.Status != StatusOpen {
+		status := o.Status
+		s.mu.Unlock()
+		return Order{}, fmt.Errorf("%w: order is %s", ErrNotOpen, status)
+	}
+	now := time.Now().UTC()
+	s.finish(o, StatusCancelled, Event{At: now, Type: EventCancelled, Message: "cancelled by " + actor})
+	s.cancelSiblings(o, now, "other OCO leg cancelled")
+	s.mu.Unlock()
+
+	s.audit.Record(actor, "cancel", auditResource, id, StatusOpen, StatusCancelled)
+	return s.snapshot(o), nil
+}
+
+// direction reports whether an order triggers when the price rises to its
+// trigger ("above") or falls to it ("below")
+func direction(kind, side string) string {
+	buy := side == "buy"
+	switch kind {
+	case TypeStopLoss:
+		if buy {
+			return "above"
+		}
+		return "below"
+	default: // limit and take-profit orders trigger on a favourable price
+		if buy {
+			return "below"
+		}
+		return "above"
+	}
+}
+
+func crossed(o *Order, price float64) bool {
+	if o.Direction == "above" {
+		return price >= o.TriggerPrice
+	}
+	return price <= o.TriggerPrice
+}
+
+func (s *Service) poll(now time.Time) {
+	open := s.orders.List(func(o *Order) bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return o.Status == StatusOpen
+	})
+
+	for _, o := range open {
+		s.check(o, now.UTC())
+	}
+}
+
+// check expires, quotes and, if the trigger is crossed, executes one order
+// with the same quote that crossed it
+func (s *Service) check(o *Order, now time.Time) {
+	s.mu.Lock()
+	if o.Status != StatusOpen {
+		s.mu.Unlock()
+		return
+	}
+	if o.ExpiresAt != nil && !now.Before(*o.ExpiresAt) {
+		s.finish(o, StatusExpired, Event{At: now, Type: EventExpired})
+		s.mu.Unlock()
+		return
+	}
+	req := models.CreateQuoteRequest{
+		BaseCurrency:  o.BaseCurrency,
+		QuoteCurrency: o.QuoteCurrency,
+		Side:          o.Side,
+		Quantity:      o.Quantity,
+	}
+	s.mu.Unlock()
+
+	quote, price, err := s.quote(req)
+
+	s.mu.Lock()
+	if o.Status != StatusOpen {
+		s.mu.Unlock()
+		return
+	}
+	o.Checks++
+	o.LastCheckedAt = &now
+	if err != nil {
+		s.appendEvent(o, Event{At: now, Type: EventQuoteFailed, Message: err.Error()})
+		s.mu.Unlock()
+		return
+	}
+	o.LastPrice = price
+	if !crossed(o, price) {
+		s.mu.Unlock()
+		return
+	}
+	o.Status = StatusTriggered
+	o.Attempts++
+	s.appendEvent(o, Event{At: now, Type: EventTriggered, Price: price, QuoteID: quote.ID})
+	order := models.CreateOrderRequest{
+		BaseCurrency:  o.BaseCurrency,
+		QuoteCurrency: o.QuoteCurrency,
+		Side:          o.Side,
+		Quantity:      o.Quantity,
+		Price:         quote.Price,
+		QuoteID:       quote.ID,
+		Metadata:      map[string]interface{}{"conditional_order_id": o.ID, "trigger": o.Type},
+	}
+	s.mu.Unlock()
+
+	raw, err := s.client.CreateOrder(order)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	done := time.Now().UTC()
+	if err != nil {
+		ev := Event{At: done, Type: EventExecutionFailed, Price: price, QuoteID: quote.ID, Message: err.Error()}
+		if o.Attempts >= maxAttempts {
+			s.finish(o, StatusFailed, ev)
+		} else {
+			// Re-arm so the next poll can retry with a fresh quote
+			o.Status = StatusOpen
+			s.appendEvent(o, ev)
+		}
+		s.logger.Printf("Conditional order %s execution failed: %v", o.ID, err)
+		return
+	}
+
+	var resp models.OrderResponse
+	if err := models.Decode(raw, &resp); err == nil {
+		o.ExecutedOrder = resp.ID
+	}
+	s.finish(o, StatusExecuted, Event{At: done, Type: EventExecuted, Price: price, QuoteID: quote.ID, OrderID: o.ExecutedOrder})
+	s.cancelSiblings(o, done, "other OCO leg executed")
+	s.logger.Printf("Conditional order %s executed as %s at %s", o.ID, o.ExecutedOrder, quote.Price)
+}
+
+func (s *Service) quote(req models.CreateQuoteRequest) (models.CreateQuoteResponse, float64, error) {
+	var quote models.CreateQuoteResponse
+
+	raw, err := s.client.CreateTradingQuote(req)
+	if err != nil {
+		return quote, 0, err
+	}
+	if err := models.Decode(raw, &quote); err != nil {
+		return quote, 0, err
+	}
+	price, err := strconv.ParseFloat(quote.Price, 64)
+	if err != nil || price <= 0 {
+		return quote, 0, fmt.Errorf("invalid quoted price %q", quote.Price)
+	}
+	return quote, price, nil
+}
+
+// cancelSiblings cancels the other open legs of o's OCO group; callers hold s.mu
+func (s *Service) cancelSiblings(o *Order, now time.Time, reason string) {
+	if o.OCOGroup == "" {
+		return
+	}
+	for _, sib := range s.orders.List(func(x *Order) bool { return x.OCOGroup == o.OCOGroup && x.ID != o.ID }) {
+		if sib.Status == StatusOpen {
+			s.finish(sib, StatusCancelled, Event{At: now, Type: EventCancelled, Message: reason})
+		}
+	}
+}
+
+// finish moves an order to a terminal status; callers hold s.mu
+func (s *Service) finish(o *Order, status string, ev Event) {
+	o.Status = status
+	s.appendEvent(o, ev)
+}
+
+// appendEvent records a history entry; callers hold s.mu
+func (s *Service) appendEvent(o *Order, ev Event) {
+	o.History = append(o.History, ev)
+	o.UpdatedAt = ev.At
+}
+
+func (s *Service) snapshot(o *Order) Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := *o
+	out.History = append([]Event(nil), o.History...)
+	return out
+}
@@ -0,0 +1,473 @@
+package dca
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/audit"
+	"github.com/bitnob-api-demo/internal/models"
+	"github.com/bitnob-api-demo/internal/schedule"
+	"github.com/bitnob-api-demo/internal/store"
+)
+
+// auditResource is the resource name used for plan audit entries
+const auditResource = "trading_plan"
+
+// Plan states
+const (
+	StatusActive    = "active"
+	StatusPaused    = "paused"
+	StatusCompleted = "completed"
+)
+
+// Run outcomes
+const (
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+	RunSkipped   = "skipped"
+)
+
+// quantityPrecision is the number of decimals base quantities are rounded down to
+const quantityPrecision = 8
+
+var ErrInvalidState = errors.New("plan cannot change to the requested state")
+
+// TradingClient is the subset of the Bitnob client used to place plan orders
+type TradingClient interface {
+	CreateTradingQuote(req interface{}) (interface{}, error)
+	CreateOrder(req interface{}) (interface{}, error)
+}
+
+// Definition is the user-editable part of a plan. Each run buys either a
+// fixed Spend in the quote currency or a fixed base Quantity.
+type Definition struct {
+	Name          string        `json:"name" binding:"required"`
+	BaseCurrency  string        `json:"base_currency" binding:"required"`
+	QuoteCurrency string        `json:"quote_currency" binding:"required"`
+	Spend         float64       `json:"spend,omitempty"`
+	Quantity      float64       `json:"quantity,omitempty"`
+	Timing        schedule.Spec `json:"timing"`
+	MaxPrice      float64       `json:"max_price,omitempty"`
+	MaxSpreadBps  float64       `json:"max_spread_bps,omitempty"`
+	TotalBudget   float64       `json:"total_budget,omitempty"`
+}
+
+// Plan is a recurring buy and its progress
+type Plan struct {
+	Definition
+	ID         string      `json:"id"`
+	Status     string      `json:"status"`
+	CreatedBy  string      `json:"created_by"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+	NextRunAt  *time.Time  `json:"next_run_at,omitempty"`
+	LastRunAt  *time.Time  `json:"last_run_at,omitempty"`
+	Spent      float64     `json:"spent"`
+	Acquired   float64     `json:"acquired"`
+	AvgPrice   float64     `json:"avg_price,omitempty"`
+	OrderCount int         `json:"order_count"`
+	Remaining  *float64    `json:"remaining_budget,omitempty"`
+	Upcoming   []time.Time `json:"upcoming,omitempty"`
+
+	compiled schedule.Schedule
+}
+
+// Run records one execution attempt of a plan
+type Run struct {
+	ID           string    `json:"id"`
+	PlanID       string    `json:"plan_id"`
+	ScheduledFor time.Time `json:"scheduled_for"`
+	StartedAt    time.Time `json:"started_at"`
+	FinishedAt   time.Time `json:"finished_at"`
+	Status       string    `json:"status"`
+	Reason       string    `json:"reason,omitempty"`
+	QuoteID      string    `json:"quote_id,omitempty"`
+	Price        float64   `json:"price,omitempty"`
+	SpreadBps    float64   `json:"spread_bps,omitempty"`
+	Quantity     string    `json:"quantity,omitempty"`
+	Cost         float64   `json:"cost,omitempty"`
+	OrderID      string    `json:"order_id,omitempty"`
+}
+
+// Service stores buy plans and executes them when due
+type Service struct {
+	client TradingClient
+	audit  *audit.Log
+	logger *log.Logger
+
+	mu    sync.Mutex
+	plans *store.Table[*Plan]
+	runs  *store.Table[Run]
+}
+
+// NewService creates an empty plan service
+func NewService(client TradingClient, auditLog *audit.Log) *Service {
+	return &Service{
+		client: client,
+		audit:  auditLog,
+		plans:  store.NewTable[*Plan](),
+		runs:   store.NewTable[Run](),
+		logger: log.Default(),
+	}
+}
+
+// WithLogger sends logs of plan runs to logger, e.g. one tagged with the tenant
+func (s *Service) WithLogger(logger *log.Logger) *Service {
+	s.logger = logger
+	return s
+}
+
+// Start checks for due plans at the given interval until ctx is cancelled
+func (s *Service) Start(ctx context.Context, interval time.Duration) {
+	schedule.Every(ctx, interval, s.runDue)
+}
+
+// Create validates a definition and activates the plan
+func (s *Service) Create(def Definition, actor string) (Plan, error) {
+	compiled, err := validate(&def)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	now := time.Now().UTC()
+	p := &Plan{
+		Definition: def,
+		ID:         store.NewID("plan"),
+		Status:     StatusActive,
+		CreatedBy:  actor,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		compiled:   compiled,
+	}
+	s.advance(p, now)
+
+	s.plans.Put(p.ID, p)
+	s.audit.Record(actor, "create", auditResource, p.ID, nil, p.Definition)
+	return s.snapshot(p), nil
+}
+
+// Get returns a plan with its next few run times
+func (s *Service) Get(id string) (Plan, bool) {
+	p, ok := s.plans.Get(id)
+	if !ok {
+		return Plan{}, false
+	}
+	out := s.snapshot(p)
+	s.mu.Lock()
+	compiled := p.compiled
+	s.mu.Unlock()
+	if out.Status == StatusActive {
+		out.Upcoming = schedule.Upcoming(compiled, time.Now(), 5)
+	}
+	return out, true
+}
+
+// List returns all plans
+func (s *Service) List() []Plan {
+	rows := s.plans.List(nil)
+	out := make([]Plan, 0, len(rows))
+	for _, p := range rows {
+		out = append(out, s.snapshot(p))
+	}
+	return out
+}
+
+// Update replaces a plan's definition; progress towards the budget is kept
+func (s *Service) Update(id string, def Definition, actor string) (Plan, error) {
+	p, ok := s.plans.Get(id)
+	if !ok {
+		return Plan{}, store.ErrNotFound
+	}
+	compiled, err := validate(&def)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	s.mu.Lock()
+	before := p.Definition
+	p.Definition = def
+	p.compiled = compiled
+	p.UpdatedAt = time.Now().UTC()
+	if p.Status == StatusCompleted && !budgetExhausted(p) {
+		p.Status = StatusActive
+	}
+	if p.Status == StatusActive {
+		s.advance(p, p.UpdatedAt)
+	}
+	s.mu.Unlock()
+
+	s.audit.Record(actor, "update", auditResource, id, before, def)
+	return s.snapshot(p), nil
+}
+
+// Delete removes a plan; its run history is kept
+func (s *Service) Delete(id, actor string) error {
+	p, ok := s.plans.Delete(id)
+	if !ok {
+		return store.ErrNotFound
+	}
+	s.audit.Record(actor, "delete", auditResource, id, p.Definition, nil)
+	return nil
+}
+
+// Pause stops an active plan from running
+func (s *Service) Pause(id, actor string) (Plan, error) {
+	return s.transition(id, actor, "pause", StatusActive, StatusPaused)
+}
+
+// Resume reactivates a paused plan from the next occurrence after now
+func (s *Service) Resume(id, actor string) (Plan, error) {
+	return s.transition(id, actor, "resume", StatusPaused, StatusActive)
+}
+
+// Runs returns the run history of a plan, newest first
+func (s *Service) Runs(id string) []Run {
+	runs := s.runs.List(func(r Run) bool { return r.PlanID == id })
+	for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+		runs[i], runs[j] = runs[j], runs[i]
+	}
+	return runs
+}
+
+func (s *Service) transition(id, actor, action, from, to string) (Plan, error) {
+	p, ok := s.plans.Get(id)
+	if !ok {
+		return Plan{}, store.ErrNotFound
+	}
+
+	s.mu.Lock()
+	if p.Status != from {
+		s.mu.Unlock()
+		return Plan{}, fmt.Errorf("%w: plan is %s", ErrInvalidState, p.Status)
+	}
+	p.Status = to
+	p.UpdatedAt = time.Now().UTC()
+	if to == StatusActive {
+		s.advance(p, p.UpdatedAt)
+	} else {
+		p.NextRunAt = nil
+	}
+	s.mu.Unlock()
+
+	s.audit.Record(actor, action, auditResource, id, from, to)
+	return s.snapshot(p), nil
+}
+
+func validate(def *Definition) (schedule.Schedule, error) {
+	compiled, err := def.Timing.Compile()
+	if err != nil {
+		return nil, fmt.Errorf("invalid timing: %w", err)
+	}
+
+	def.BaseCurrency = strings.ToUpper(strings.TrimSpace(def.BaseCurrency))
+	def.QuoteCurrency = strings.ToUpper(strings.TrimSpace(def.QuoteCurrency))
+	if def.BaseCurrency == def.QuoteCurrency {
+		return nil, errors.New("base_currency and quote_currency must differ")
+	}
+	if (def.Spend > 0) == (def.Quantity > 0) {
+		return nil, errors.New("exactly one of spend or quantity is required")
+	}
+	if def.MaxPrice < 0 || def.MaxSpreadBps < 0 || def.TotalBudget < 0 {
+		return nil, errors.New("max_price, max_spread_bps and total_budget must not be negative")
+	}
+	if def.TotalBudget > 0 && def.Spend > def.TotalBudget {
+		return nil, errors.New("spend must not exceed total_budget")
+	}
+
+	return compiled, nil
+}
+
+// advance computes the next run after t, completing the plan once its budget
+// is used up; callers hold s.mu or own p exclusively
+func (s *Service) advance(p *Plan, t time.Time) {
+	p.NextRunAt = nil
+
+	next := p.compiled.Next(t)
+	if next.IsZero() || budgetExhausted(p) {
+		p.Status = StatusCompleted
+		return
+	}
+	p.NextRunAt = &next
+}
+
+// budgetExhausted reports whether another fixed-spend run would overrun the
+// budget. Fixed-quantity plans complete once the budget is fully spent.
+func budgetExhausted(p *Plan) bool {
+	if p.TotalBudget <= 0 {
+		return false
+	}
+	if p.Spend > 0 {
+		return p.Spent+p.Spend > p.TotalBudget+1e-9
+	}
+	return p.Spent >= p.TotalBudget
+}
+
+func (s *Service) runDue(now time.Time) {
+	due := s.plans.List(func(p *Plan) bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return p.Status == StatusActive && p.NextRunAt != nil && !p.NextRunAt.After(now)
+	})
+
+	for _, p := range due {
+		s.runOnce(p, now)
+	}
+}
+
+func (s *Service) runOnce(p *Plan, now time.Time) {
+	s.mu.Lock()
+	scheduledFor := *p.NextRunAt
+	def := p.Definition
+	remaining := math.Inf(1)
+	if def.TotalBudget > 0 {
+		remaining = def.TotalBudget - p.Spent
+	}
+	s.mu.Unlock()
+
+	run := Run{
+		ID:           store.NewID("plrun"),
+		PlanID:       p.ID,
+		ScheduledFor: scheduledFor,
+		StartedAt:    now.UTC(),
+	}
+	s.execute(&run, def, remaining)
+	run.FinishedAt = time.Now().UTC()
+	s.runs.Put(run.ID, run)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if run.Status == RunSucceeded {
+		qty, _ := strconv.ParseFloat(run.Quantity, 64)
+		p.Spent += run.Cost
+		p.Acquired += qty
+		p.OrderCount++
+		if p.Acquired > 0 {
+			p.AvgPrice = p.Spent / p.Acquired
+		}
+	}
+	p.LastRunAt = &run.StartedAt
+	if p.Status == StatusActive {
+		s.advance(p, scheduledFor)
+	}
+	s.logger.Printf("Trading plan %s run %s: %s %s", p.ID, run.ID, run.Status, run.Reason)
+}
+
+// execute quotes the buy, applies the price, spread and budget guards and
+// places the order with the quote's ID. Fixed-spend plans first take an
+// indicative quote for one base unit to size the order.
+func (s *Service) execute(run *Run, def Definition, remaining float64) {
+	fail := func(status string, err error) {
+		run.Status = status
+		run.Reason = err.Error()
+	}
+
+	quantity := def.Quantity
+	if def.Spend > 0 {
+		probe, err := s.quote(def, 1)
+		if err != nil {
+			fail(RunFailed, fmt.Errorf("indicative quote: %w", err))
+			return
+		}
+		quantity = def.Spend / probe.price
+	}
+	quantity = math.Floor(quantity*math.Pow10(quantityPrecision)) / math.Pow10(quantityPrecision)
+	if quantity <= 0 {
+		fail(RunFailed, errors.New("order quantity rounds to zero"))
+		return
+	}
+
+	q, err := s.quote(def, quantity)
+	if err != nil {
+		fail(RunFailed, fmt.Errorf("quote: %w", err))
+		return
+	}
+	run.QuoteID = q.raw.ID
+	run.Price = q.price
+	run.SpreadBps = q.raw.SpreadBps
+	run.Quantity = formatQuantity(quantity)
+	run.Cost = quantity * q.price
+
+	switch {
+	case def.MaxPrice > 0 && q.price > def.MaxPrice:
+		fail(RunSkipped, fmt.Errorf("price %s above max price %s", formatPrice(q.price), formatPrice(def.MaxPrice)))
+		return
+	case def.MaxSpreadBps > 0 && q.raw.SpreadBps > def.MaxSpreadBps:
+		fail(RunSkipped, fmt.Errorf("spread %.1f bps above max %.1f bps", q.raw.SpreadBps, def.MaxSpreadBps))
+		return
+	case run.Cost > remaining+1e-9:
+		fail(RunSkipped, fmt.Errorf("cost %s %s exceeds remaining budget %s", formatPrice(run.Cost), def.QuoteCurrency, formatPrice(remaining)))
+		return
+	}
+
+	raw, err := s.client.CreateOrder(models.CreateOrderRequest{
+		BaseCurrency:  def.BaseCurrency,
+		QuoteCurrency: def.QuoteCurrency,
+		Side:          "buy",
+		Quantity:      run.Quantity,
+		Price:         q.raw.Price,
+		QuoteID:       q.raw.ID,
+		Metadata:      map[string]interface{}{"plan_id": run.PlanID, "run_id": run.ID},
+	})
+	if err != nil {
+		fail(RunFailed, fmt.Errorf("order: %w", err))
+		return
+	}
+	var order models.OrderResponse
+	if err := models.Decode(raw, &order); err == nil {
+		run.OrderID = order.ID
+	}
+
+	run.Status = RunSucceeded
+}
+
+type pricedQuote struct {
+	raw   models.CreateQuoteResponse
+	price float64
+}
+
+func (s *Service) quote(def Definition, quantity float64) (pricedQuote, error) {
+	raw, err := s.client.CreateTradingQuote(models.CreateQuoteRequest{
+		BaseCurrency:  def.BaseCurrency,
+		QuoteCurrency: def.QuoteCurrency,
+		Side:          "buy",
+		Quantity:      formatQuantity(quantity),
+	})
+	if err != nil {
+		return pricedQuote{}, err
+	}
+
+	var q pricedQuote
+	if err := models.Decode(raw, &q.raw); err != nil {
+		return pricedQuote{}, err
+	}
+	if q.price, err = strconv.ParseFloat(q.raw.Price, 64); err != nil || q.price <= 0 {
+		return pricedQuote{}, fmt.Errorf("invalid quoted price %q", q.raw.Price)
+	}
+	return q, nil
+}
+
+func (s *Service) snapshot(p *Plan) Plan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := *p
+	if p.TotalBudget > 0 {
+		remaining := p.TotalBudget - p.Spent
+		out.Remaining = &remaining
+	}
+	return out
+}
+
+func formatQuantity(q float64) string {
+	return strconv.FormatFloat(q, 'f', -1, 64)
+}
+
+func formatPrice(p float64) string {
+	return strconv.FormatFloat(p, 'f', 2, 64)
+}
@@ -0,0 +1,154 @@
+package events
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// subscriberBuffer is the number of undelivered events a subscriber may
+// queue before it is dropped as too slow
+const subscriberBuffer = 64
+
+// Event is a change published to subscribers. Seq increases by one for
+// every event published on a broker.
+type Event struct {
+	Seq   uint64      `json:"seq"`
+	Topic string      `json:"topic"`
+	Type  string      `json:"type"`
+	At    time.Time   `json:"at"`
+	Data  interface{} `json:"data"`
+}
+
+// Subscription receives matching events on C until it is closed. C is also
+// closed when the subscriber falls too far behind.
+type Subscription struct {
+	C <-chan Event
+
+	c      chan Event
+	match  func(Event) bool
+	broker *Broker
+	once   sync.Once
+}
+
+// Close stops delivery and releases the subscription
+func (s *Subscription) Close() {
+	s.broker.unsubscribe(s)
+}
+
+// Broker fans published events out to subscribers and keeps a bounded
+// history so reconnecting clients can resume from a sequence number
+type Broker struct {
+	mu      sync.Mutex
+	seq     uint64
+	history []Event
+	size    int
+	subs    map[*Subscription]struct{}
+}
+
+// NewBroker creates a broker that retains the last historySize events
+func NewBroker(historySize int) *Broker {
+	return &Broker{
+		size: historySize,
+		subs: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish assigns the next sequence number to an event and delivers it
+func (b *Broker) Publish(topic, eventType string, data interface{}) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	ev := Event{Seq: b.seq, Topic: topic, Type: eventType, At: time.Now().UTC(), Data: data}
+	if b.size > 0 {
+		b.history = append(b.history, ev)
+		if len(b.history) > b.size {
+			b.history = b.history[len(b.history)-b.size:]
+		}
+	}
+
+	for sub := range b.subs {
+		if !sub.match(ev) {
+			continue
+		}
+		select {
+		case sub.c <- ev:
+		default:
+			// Drop slow subscribers rather than block publishers
+			b.remove(sub)
+		}
+	}
+	return ev
+}
+
+// Subscribe registers a subscriber for events accepted by match (all events
+// if nil). Events after since that are still in history are replayed first;
+// replayed reports whether history reached back far enough to cover the gap.
+// A since beyond the last event came from an earlier process and is not
+// replayable.
+func (b *Broker) Subscribe(match func(Event) bool, since uint64) (sub *Subscription, replayed bool) {
+	if match == nil {
+		match = func(Event) bool { return true }
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var backlog []Event
+	replayed = since <= b.seq
+	if since > 0 && since < b.seq {
+		replayed = len(b.history) > 0 && b.history[0].Seq <= since+1
+		for _, ev := range b.history {
+			if ev.Seq > since && match(ev) {
+				backlog = append(backlog, ev)
+			}
+		}
+	}
+
+	c := make(chan Event, subscriberBuffer+len(backlog))
+	for _, ev := range backlog {
+		c <- ev
+	}
+	sub = &Subscription{C: c, c: c, match: match, broker: b}
+	b.subs[sub] = struct{}{}
+	return sub, replayed
+}
+
+// Seq returns the sequence number of the last published event
+func (b *Broker) Seq() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.seq
+}
+
+func (b *Broker) unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(sub)
+}
+
+// remove closes a subscription; callers hold b.mu
+func (b *Broker) remove(sub *Subscription) {
+	delete(b.subs, sub)
+	sub.once.Do(func() { close(sub.c) })
+}
+
+// TopicMatcher matches events whose topic equals one of topics. A topic
+// ending in "*" matches by prefix, so "order:*" matches every order.
+func TopicMatcher(topics ...string) func(Event) bool {
+	return func(ev Event) bool {
+		if len(topics) == 0 {
+			return true
+		}
+		for _, t := range topics {
+			if t == ev.Topic {
+				return true
+			}
+			if prefix, ok := strings.CutSuffix(t, "*"); ok && strings.HasPrefix(ev.Topic, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,85 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AccountTopic subscribes to every event on the account
+const AccountTopic = "account"
+
+// topicKinds are the resource prefixes clients may subscribe to
+var topicKinds = []string{"transfer", "payout", "order"}
+
+// Grants maps an API key to the topic patterns it may subscribe to
+type Grants map[string][]string
+
+// ParseGrants reads "key=pattern|pattern,key2=pattern". A pattern is a
+// topic such as "order:ord_123", a prefix such as "order:*", or "*" for
+// everything including the account-wide topic.
+func ParseGrants(s string) (Grants, error) {
+	grants := Grants{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, patterns, ok := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.TrimSpace(patterns) == "" {
+			return nil, fmt.Errorf("invalid grant %q, expected key=pattern|pattern", entry)
+		}
+		for _, p := range strings.Split(patterns, "|") {
+			if p = strings.TrimSpace(p); p != "" {
+				grants[key] = append(grants[key], p)
+			}
+		}
+	}
+	return grants, nil
+}
+
+// Authenticate reports whether key is a known API key
+func (g Grants) Authenticate(key string) bool {
+	_, ok := g[key]
+	return key != "" && ok
+}
+
+// Allows reports whether key may subscribe to topic
+func (g Grants) Allows(key, topic string) bool {
+	for _, p := range g[key] {
+		switch {
+		case p == "*" || p == topic:
+			return true
+		case topic == AccountTopic:
+			continue
+		case strings.HasSuffix(p, "*") && strings.HasPrefix(topic, strings.TrimSuffix(p, "*")):
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTopic checks that a subscription topic is the account topic or
+// names a known resource kind, e.g. "payout:{quoteId}" or "order:*"
+func ValidateTopic(topic string) error {
+	if topic == AccountTopic {
+		return nil
+	}
+	kind, id, ok := strings.Cut(topic, ":")
+	if ok && id != "" {
+		for _, k := range topicKinds {
+			if kind == k {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("unknown topic %q, expected account or one of transfer:, payout:, order: followed by an id or *", topic)
+}
+
+// Pattern converts a subscription topic to the form used by TopicMatcher
+func Pattern(topic string) string {
+	if topic == AccountTopic {
+		return "*"
+	}
+	return topic
+}
@@ -0,0 +1,427 @@
+package exports
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bitnob-api-demo/internal/activity"
+	"github.com/bitnob-api-demo/internal/portfolio"
+	"github.com/bitnob-api-demo/internal/spreadsheet"
+)
+
+// Account roles a chart of accounts maps. Every journal entry moves the
+// wallet against a clearing account, and fees against the fees account.
+const (
+	RoleWallet   = "wallet"
+	RoleClearing = "clearing"
+	RoleFees     = "fees"
+)
+
+// Export formats
+const (
+	FormatCSV     = "csv"
+	FormatJournal = "journal"
+	FormatOFX     = "ofx"
+)
+
+var ErrInvalidFormat = errors.New("format must be csv, journal or ofx")
+
+var ErrInvalidKind = errors.New("type must be transfer, payout or trade")
+
+// Chart maps a transaction kind, currency and role to a ledger account. A
+// "*" kind or currency matches anything, and "{currency}" in an account name
+// is replaced with the currency.
+type Chart struct {
+	rules []Mapping
+}
+
+// Mapping assigns an account to a kind, currency and role
+type Mapping struct {
+	Kind     string `json:"type"`
+	Currency string `json:"currency"`
+	Role     string `json:"role"`
+	Account  string `json:"account"`
+}
+
+// ParseChart reads "type:currency:role=account,...", e.g.
+// "*:*:wallet=Assets:Bitnob:{currency},payout:NGN:clearing=Expenses:Payouts NG"
+func ParseChart(spec string) (*Chart, error) {
+	c := &Chart{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, account, ok := strings.Cut(part, "=")
+		fields := strings.Split(key, ":")
+		if !ok || len(fields) != 3 || strings.TrimSpace(account) == "" {
+			return nil, fmt.Errorf("invalid account mapping %q, expected type:currency:role=account", part)
+		}
+		r := Mapping{
+			Kind:     strings.ToLower(strings.TrimSpace(fields[0])),
+			Currency: strings.ToUpper(strings.TrimSpace(fields[1])),
+			Role:     strings.ToLower(strings.TrimSpace(fields[2])),
+			Account:  strings.TrimSpace(account),
+		}
+		switch r.Role {
+		case RoleWallet, RoleClearing, RoleFees:
+		default:
+			return nil, fmt.Errorf("invalid account mapping %q, role must be wallet, clearing or fees", part)
+		}
+		c.rules = append(c.rules, r)
+	}
+	return c, nil
+}
+
+// Account returns the most specific mapping for kind, currency and role:
+// exact kind and currency first, then either one, then the wildcard
+func (c *Chart) Account(kind, currency, role string) string {
+	best, bestScore := "", -1
+	for _, r := range c.rules {
+		if r.Role != role {
+			continue
+		}
+		score := 0
+		switch r.Kind {
+		case kind:
+			score += 2
+		case "*":
+		default:
+			continue
+		}
+		switch r.Currency {
+		case currency:
+			score++
+		case "*":
+		default:
+			continue
+		}
+		// Later rules win ties so overrides can be appended
+		if score >= bestScore {
+			best, bestScore = r.Account, score
+		}
+	}
+	if best == "" {
+		best = "Unmapped:" + role
+	}
+	return strings.ReplaceAll(best, "{currency}", currency)
+}
+
+// Rules returns the configured mappings
+func (c *Chart) Rules() []Mapping {
+	return append([]Mapping(nil), c.rules...)
+}
+
+// Entry is one transaction as exported, with trades normalized alongside
+// transfers and payouts
+type Entry struct {
+	activity.Transaction
+	Legs []Leg `json:"legs"`
+}
+
+// Leg is a signed wallet movement in one currency against a counter role.
+// Every leg becomes a balanced pair of journal lines.
+type Leg struct {
+	Currency string  `json:"currency"`
+	Amount   float64 `json:"amount"`
+	Role     string  `json:"role"`
+	Memo     string  `json:"memo"`
+}
+
+// TradeSource supplies executed trades
+type TradeSource interface {
+	Trades() []portfolio.Trade
+}
+
+// Service builds accounting exports from recorded activity
+type Service struct {
+	activity *activity.Log
+	trades   TradeSource
+	chart    *Chart
+}
+
+// NewService creates an export service using chart for account mapping
+func NewService(activityLog *activity.Log, trades TradeSource, chart *Chart) *Service {
+	return &Service{
+		activity: activityLog,
+		trades:   trades,
+		chart:    chart,
+	}
+}
+
+// Chart returns the account mapping in use
+func (s *Service) Chart() *Chart {
+	return s.chart
+}
+
+// ParseKinds reads a comma separated list of kinds; empty selects all
+func ParseKinds(value string) (map[string]bool, error) {
+	kinds := map[string]bool{}
+	for _, k := range strings.Split(value, ",") {
+		switch k = strings.ToLower(strings.TrimSpace(k)); k {
+		case "":
+		case activity.KindTransfer, activity.KindPayout, activity.KindTrade:
+			kinds[k] = true
+		default:
+			return nil, ErrInvalidKind
+		}
+	}
+	if len(kinds) == 0 {
+		kinds = map[string]bool{activity.KindTransfer: true, activity.KindPayout: true, activity.KindTrade: true}
+	}
+	return kinds, nil
+}
+
+// Entries returns transactions of the given kinds created in [from, to),
+// oldest first; zero bounds are open
+func (s *Service) Entries(from, to time.Time, kinds map[string]bool) []Entry {
+	entries := []Entry{}
+	for _, tx := range s.activity.List(from, to) {
+		if kinds[tx.Kind] {
+			entries = append(entries, Entry{Transaction: tx, Legs: legs(tx)})
+		}
+	}
+
+	if kinds[activity.KindTrade] {
+		fills := map[string]int{}
+		for _, t := range s.trades.Trades() {
+			// Number fills per order so IDs stay stable as trades accrue
+			fills[t.OrderID]++
+			if (!from.IsZero() && t.At.Before(from)) || (!to.IsZero() && !t.At.Before(to)) {
+				continue
+			}
+			tx := activity.Transaction{
+				ID:                 fmt.Sprintf("%s-%d", t.OrderID, fills[t.OrderID]),
+				Kind:               activity.KindTrade,
+				Reference:          t.OrderID,
+				Status:             "filled",
+				Currency:           t.BaseCurrency,
+				Amount:             t.Quantity,
+				Fee:                t.Fee,
+				Rate:               t.Price,
+				SettlementCurrency: t.QuoteCurrency,
+				SettlementAmount:   round(t.Quantity * t.Price),
+				Side:               t.Side,
+				Description:        fmt.Sprintf("%s %s %s/%s", t.Side, formatAmount(t.Quantity), t.BaseCurrency, t.QuoteCurrency),
+				CreatedAt:          t.At,
+				UpdatedAt:          t.At,
+			}
+			entries = append(entries, Entry{Transaction: tx, Legs: legs(tx)})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].CreatedAt.Before(entries[j].CreatedAt) })
+	return entries
+}
+
+// legs splits a transaction into signed wallet movements. Transfers and
+// payouts debit the wallet; trades move both currencies of the pair through
+// the trading clearing account so each currency balances on its own.
+func legs(tx activity.Transaction) []Leg {
+	if !tx.Posted() {
+		return nil
+	}
+
+	var out []Leg
+	switch tx.Kind {
+	case activity.KindTrade:
+		sign := 1.0
+		if tx.Side == "sell" {
+			sign = -1
+		}
+		out = append(out,
+			Leg{Currency: tx.Currency, Amount: sign * tx.Amount, Role: RoleClearing, Memo: tx.Description},
+			Leg{Currency: tx.SettlementCurrency, Amount: -sign * tx.SettlementAmount, Role: RoleClearing, Memo: tx.Description},
+		)
+		if tx.Fee > 0 {
+			out = append(out, Leg{Currency: tx.SettlementCurrency, Amount: -tx.Fee, Role: RoleFees, Memo: "Trading fee " + tx.Reference})
+		}
+		return out
+	case activity.KindPayout:
+		memo := fmt.Sprintf("Payout %s", tx.Reference)
+		if tx.SettlementCurrency != "" {
+			memo += fmt.Sprintf(" (%s %s at %s)", formatMoney(tx.SettlementAmount), tx.SettlementCurrency, formatAmount(tx.Rate))
+		}
+		out = append(out, Leg{Currency: tx.Currency, Amount: -tx.Amount, Role: RoleClearing, Memo: memo})
+		if tx.Fee > 0 {
+			out = append(out, Leg{Currency: tx.Currency, Amount: -tx.Fee, Role: RoleFees, Memo: "Payout fee " + tx.Reference})
+		}
+		return out
+	default:
+		out = append(out, Leg{Currency: tx.Currency, Amount: -tx.Amount, Role: RoleClearing, Memo: "Transfer to " + tx.Counterparty})
+		if tx.Fee > 0 {
+			out = append(out, Leg{Currency: tx.Currency, Amount: -tx.Fee, Role: RoleFees, Memo: "Transfer fee " + tx.Reference})
+		}
+		return out
+	}
+}
+
+// WriteCSV writes one row per transaction
+func (s *Service) WriteCSV(w io.Writer, entries []Entry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "date", "type", "reference", "quoteId", "status", "side", "currency", "amount",
+		"fee", "rate", "settlementCurrency", "settlementAmount", "counterparty", "description"})
+	for _, e := range entries {
+		cw.Write([]string{
+			e.ID,
+			e.CreatedAt.UTC().Format(time.RFC3339),
+			e.Kind,
+			spreadsheet.Cell(e.Reference),
+			e.QuoteID,
+			e.Status,
+			e.Side,
+			e.Currency,
+			formatAmount(e.Amount),
+			formatAmount(e.Fee),
+			formatAmount(e.Rate),
+			e.SettlementCurrency,
+			formatAmount(e.SettlementAmount),
+			spreadsheet.Cell(e.Counterparty),
+			spreadsheet.Cell(e.Description),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJournal writes a double-entry journal: each leg is a debit and a
+// credit of equal amount in the leg's currency
+func (s *Service) WriteJournal(w io.Writer, entries []Entry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"entryId", "date", "account", "description", "currency", "debit", "credit", "reference", "type"})
+	for _, e := range entries {
+		for i, leg := range e.Legs {
+			wallet := s.chart.Account(e.Kind, leg.Currency, RoleWallet)
+			counter := s.chart.Account(e.Kind, leg.Currency, leg.Role)
+			debit, credit := counter, wallet
+			if leg.Amount > 0 {
+				debit, credit = wallet, counter
+			}
+			amount := formatAmount(math.Abs(leg.Amount))
+			id := fmt.Sprintf("%s-%d", e.ID, i+1)
+			date := e.CreatedAt.UTC().Format("2006-01-02")
+			memo, reference := spreadsheet.Cell(leg.Memo), spreadsheet.Cell(e.Reference)
+			cw.Write([]string{id, date, debit, memo, leg.Currency, amount, "", reference, e.Kind})
+			cw.Write([]string{id, date, credit, memo, leg.Currency, "", amount, reference, e.Kind})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteOFX writes an OFX 2 document with one statement per currency, the
+// wallet account of that currency being the statement account
+func (s *Service) WriteOFX(w io.Writer, entries []Entry, from, to time.Time) error {
+	byCurrency := map[string][]ofxTxn{}
+	for _, e := range entries {
+		for i, leg := range e.Legs {
+			kind := "DEBIT"
+			switch {
+			case leg.Role == RoleFees:
+				kind = "FEE"
+			case leg.Amount > 0:
+				kind = "CREDIT"
+			}
+			byCurrency[leg.Currency] = append(byCurrency[leg.Currency], ofxTxn{
+				Type:   kind,
+				Posted: e.CreatedAt,
+				Amount: leg.Amount,
+				FITID:  fmt.Sprintf("%s-%d", e.ID, i+1),
+				Name:   e.Kind + " " + e.Reference,
+				Memo:   leg.Memo,
+			})
+		}
+	}
+
+	currencies := make([]string, 0, len(byCurrency))
+	for c := range byCurrency {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+
+	now := time.Now().UTC()
+	if from.IsZero() && len(entries) > 0 {
+		from = entries[0].CreatedAt
+	}
+	if to.IsZero() {
+		to = now
+	}
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="no"?>` + "\n")
+	b.WriteString(`<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>` + "\n")
+	b.WriteString("<OFX>\n<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>")
+	fmt.Fprintf(&b, "<DTSERVER>%s</DTSERVER><LANGUAGE>ENG</LANGUAGE></SONRS></SIGNONMSGSRSV1>\n", ofxTime(now))
+	b.WriteString("<BANKMSGSRSV1>\n")
+	for _, currency := range currencies {
+		txns := byCurrency[currency]
+		balance := 0.0
+		for _, t := range txns {
+			balance += t.Amount
+		}
+		fmt.Fprintf(&b, "<STMTTRNRS><TRNUID>%s</TRNUID><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>\n", xmlEscape(currency))
+		fmt.Fprintf(&b, "<STMTRS><CURDEF>%s</CURDEF>\n", xmlEscape(currency))
+		fmt.Fprintf(&b, "<BANKACCTFROM><BANKID>BITNOB</BANKID><ACCTID>%s</ACCTID><ACCTTYPE>CHECKING</ACCTTYPE></BANKACCTFROM>\n",
+			xmlEscape(s.chart.Account("*", currency, RoleWallet)))
+		fmt.Fprintf(&b, "<BANKTRANLIST><DTSTART>%s</DTSTART><DTEND>%s</DTEND>\n", ofxTime(from), ofxTime(to))
+		for _, t := range txns {
+			fmt.Fprintf(&b, "<STMTTRN><TRNTYPE>%s</TRNTYPE><DTPOSTED>%s</DTPOSTED><TRNAMT>%s</TRNAMT><FITID>%s</FITID><NAME>%s</NAME><MEMO>%s</MEMO></STMTTRN>\n",
+				t.Type, ofxTime(t.Posted), formatAmount(round(t.Amount)), xmlEscape(t.FITID), xmlEscape(truncate(t.Name, 32)), xmlEscape(truncate(t.Memo, 255)))
+		}
+		b.WriteString("</BANKTRANLIST>\n")
+		// The gateway only sees its own activity, so the balance is the net movement
+		fmt.Fprintf(&b, "<LEDGERBAL><BALAMT>%s</BALAMT><DTASOF>%s</DTASOF></LEDGERBAL>\n", formatAmount(round(balance)), ofxTime(to))
+		b.WriteString("</STMTRS></STMTTRNRS>\n")
+	}
+	b.WriteString("</BANKMSGSRSV1>\n</OFX>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type ofxTxn struct {
+	Type   string
+	Posted time.Time
+	Amount float64
+	FITID  string
+	Name   string
+	Memo   string
+}
+
+func ofxTime(t time.Time) string {
+	return t.UTC().Format("20060102150405") + "[0:GMT]"
+}
+
+var xmlReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+func xmlEscape(s string) string {
+	return xmlReplacer.Replace(s)
+}
+
+// truncate keeps at most n characters, never splitting a multi-byte one
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+func round(v float64) float64 {
+	return math.Round(v*1e8) / 1e8
+}
+
+func formatAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func formatMoney(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
@@ -0,0 +1,186 @@
+package ledger
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/models"
+)
+
+// quoteRetention is how long payout quotes are kept to size holds
+const quoteRetention = time.Hour
+
+// Upstream is the Bitnob client whose payouts are checked against the ledger
+type Upstream interface {
+	CreateTransfer(req interface{}) (interface{}, error)
+	GetWalletBalances() (interface{}, error)
+	CreatePayoutQuote(req interface{}) (interface{}, error)
+	InitializePayout(req interface{}) (interface{}, error)
+	FinalizePayout(req interface{}) (interface{}, error)
+	GetCountryRequirements(country string) (interface{}, error)
+	GetTransactionLimits() (interface{}, error)
+	CreateTradingQuote(req interface{}) (interface{}, error)
+	CreateOrder(req interface{}) (interface{}, error)
+	GetOrders() (interface{}, error)
+	GetOrderByID(id string) (interface{}, error)
+}
+
+// Client wraps the Bitnob client so every payout, whether from a handler, a
+// batch or a schedule, is held against the customer's balance on initialize
+// and posted on finalize
+type Client struct {
+	Upstream
+	ledger *Ledger
+	logger *log.Logger
+
+	mu     sync.Mutex
+	quotes map[string]payoutQuote
+}
+
+type payoutQuote struct {
+	asset     string
+	amount    float64
+	expiresAt time.Time
+	at        time.Time
+}
+
+// NewClient wraps upstream so its payouts are enforced by ledger
+func NewClient(upstream Upstream, ledger *Ledger) *Client {
+	return &Client{
+		Upstream: upstream,
+		ledger:   ledger,
+		quotes:   make(map[string]payoutQuote),
+		logger:   log.Default(),
+	}
+}
+
+// WithLogger sends logs of payouts it could not post to logger, e.g. one tagged with the tenant
+func (c *Client) WithLogger(logger *log.Logger) *Client {
+	c.logger = logger
+	return c
+}
+
+func (c *Client) CreatePayoutQuote(req interface{}) (interface{}, error) {
+	response, err := c.Upstream.CreatePayoutQuote(req)
+	if err != nil {
+		return response, err
+	}
+
+	var in models.PayoutQuoteRequest
+	var out models.PayoutQuoteResponse
+	models.Decode(req, &in)
+	if models.Decode(response, &out) == nil {
+		q := payoutQuote{
+			asset:     normalizeAsset(in.FromAsset),
+			amount:    firstNonZero(out.Amount, in.Amount),
+			expiresAt: unixTime(out.ExpiryTimeStamp),
+			at:        time.Now(),
+		}
+		c.mu.Lock()
+		for id, old := range c.quotes {
+			if time.Since(old.at) > quoteRetention {
+				delete(c.quotes, id)
+			}
+		}
+		for _, id := range []string{out.ID, out.QuoteID} {
+			if id != "" {
+				c.quotes[id] = q
+			}
+		}
+		c.mu.Unlock()
+	}
+	return response, nil
+}
+
+// InitializePayout holds the quoted amount before calling Bitnob, then resizes
+// the hold to the initialized amount plus fees. A payout that no longer fits
+// the customer's balance is rejected and its hold released.
+func (c *Client) InitializePayout(req interface{}) (interface{}, error) {
+	var in models.InitializePayoutRequest
+	models.Decode(req, &in)
+	if strings.TrimSpace(in.CustomerID) == "" {
+		return nil, ErrNoCustomer
+	}
+
+	c.mu.Lock()
+	q, known := c.quotes[in.QuoteID]
+	c.mu.Unlock()
+	if known && q.amount > 0 {
+		if _, err := c.ledger.Hold(in.CustomerID, q.asset, q.amount, in.QuoteID, q.expiresAt); err != nil {
+			return nil, err
+		}
+	}
+
+	response, err := c.Upstream.InitializePayout(req)
+	if err != nil {
+		c.ledger.Release(in.QuoteID, "initialize failed")
+		return response, err
+	}
+
+	var out models.InitializePayoutResponse
+	models.Decode(response, &out)
+	asset := firstNonEmpty(normalizeAsset(out.FromAsset), q.asset)
+	amount := firstNonZero(out.Amount, q.amount)
+	if amount > 0 {
+		amount += out.Fees
+	}
+	expiresAt := q.expiresAt
+	if out.ExpiryTimeStamp > 0 {
+		expiresAt = unixTime(out.ExpiryTimeStamp)
+	}
+	if _, err := c.ledger.Hold(in.CustomerID, asset, amount, in.QuoteID, expiresAt); err != nil {
+		c.ledger.Release(in.QuoteID, "rejected after initialize")
+		return nil, err
+	}
+	return response, nil
+}
+
+// FinalizePayout only calls Bitnob for a payout with an active hold, and
+// posts it once Bitnob accepts
+func (c *Client) FinalizePayout(req interface{}) (interface{}, error) {
+	var in models.FinalizePayoutRequest
+	models.Decode(req, &in)
+	if _, err := c.ledger.Claim(in.QuoteID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	response, err := c.Upstream.FinalizePayout(req)
+	if err != nil {
+		c.ledger.Unclaim(in.QuoteID)
+		return response, err
+	}
+
+	var out models.InitializePayoutResponse
+	models.Decode(response, &out)
+	if _, err := c.ledger.Capture(in.QuoteID, out.ID); err != nil {
+		// Bitnob has moved the funds; the posting must not fail the response
+		c.logger.Printf("Ledger failed to post payout %s: %v", in.QuoteID, err)
+	}
+
+	c.mu.Lock()
+	delete(c.quotes, in.QuoteID)
+	c.mu.Unlock()
+	return response, nil
+}
+
+func firstNonZero(values ...float64) float64 {
+	for _, v := range values {
+		if v != 0 {
+			return v
+		}
+	}
+	return 0
+}
+
+// unixTime accepts expiry timestamps in seconds or milliseconds
+func unixTime(ts int64) time.Time {
+	switch {
+	case ts <= 0:
+		return time.Time{}
+	case ts > 1e12:
+		return time.UnixMilli(ts).UTC()
+	}
+	return time.Unix(ts, 0).UTC()
+}
@@ -0,0 +1,609 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/audit"
+	"github.com/bitnob-api-demo/internal/schedule"
+	"github.com/bitnob-api-demo/internal/store"
+)
+
+// Account types. Customer and clearing accounts are liabilities with credit
+// balances; the omnibus account is the pooled Bitnob wallet, an asset.
+const (
+	TypeCustomer = "customer"
+	TypeClearing = "clearing"
+	TypeOmnibus  = "omnibus"
+)
+
+// Entry kinds
+const (
+	KindDeposit    = "deposit"
+	KindPayout     = "payout"
+	KindSettlement = "settlement"
+	KindReversal   = "reversal"
+)
+
+// Hold states. A hold is active until its payout is finalized, when it is
+// captured, and then settles or is reversed once Bitnob reports the outcome.
+// Holds that never finalize are released or expire.
+const (
+	HoldActive     = "active"
+	HoldFinalizing = "finalizing"
+	HoldCaptured   = "captured"
+	HoldSettled    = "settled"
+	HoldReversed   = "reversed"
+	HoldReleased   = "released"
+	HoldExpired    = "expired"
+)
+
+// epsilon absorbs float noise when comparing balances
+const epsilon = 1e-9
+
+var (
+	ErrInsufficientFunds = errors.New("insufficient available balance")
+	ErrNoCustomer        = errors.New("customer ID is required")
+	ErrInvalidAmount     = errors.New("amount must be positive")
+	ErrUnknownAmount     = errors.New("payout amount is unknown")
+	ErrNoHold            = errors.New("no active hold for payout")
+	ErrUnbalanced        = errors.New("entry debits and credits do not balance")
+)
+
+// Rejected reports whether err is the ledger refusing a payout, as opposed to
+// Bitnob failing it
+func Rejected(err error) bool {
+	for _, target := range []error{ErrInsufficientFunds, ErrNoCustomer, ErrUnknownAmount, ErrNoHold} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Posting debits or credits one account
+type Posting struct {
+	Account string  `json:"account"`
+	Debit   float64 `json:"debit,omitempty"`
+	Credit  float64 `json:"credit,omitempty"`
+}
+
+// Entry is a balanced set of postings in one asset
+type Entry struct {
+	ID          string    `json:"id"`
+	Kind        string    `json:"kind"`
+	Asset       string    `json:"asset"`
+	CustomerID  string    `json:"customer_id"`
+	Reference   string    `json:"reference,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Postings    []Posting `json:"postings"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Account holds the running totals of one account. Balance is on the
+// account's normal side.
+type Account struct {
+	ID         string  `json:"id"`
+	Type       string  `json:"type"`
+	Asset      string  `json:"asset"`
+	CustomerID string  `json:"customer_id,omitempty"`
+	Debits     float64 `json:"debits"`
+	Credits    float64 `json:"credits"`
+	Balance    float64 `json:"balance"`
+}
+
+// Balance is what a customer holds in one asset. Available excludes funds
+// reserved by holds.
+type Balance struct {
+	CustomerID string  `json:"customer_id"`
+	Asset      string  `json:"asset"`
+	Account    string  `json:"account"`
+	Balance    float64 `json:"balance"`
+	Held       float64 `json:"held"`
+	Available  float64 `json:"available"`
+}
+
+// Hold reserves part of a customer balance for an initialized payout.
+// Outcome keeps a status Bitnob reported while the payout was finalizing,
+// applied once it is captured.
+type Hold struct {
+	ID         string    `json:"id"`
+	CustomerID string    `json:"customer_id"`
+	Asset      string    `json:"asset"`
+	Amount     float64   `json:"amount"`
+	QuoteID    string    `json:"quote_id"`
+	PayoutID   string    `json:"payout_id,omitempty"`
+	Status     string    `json:"status"`
+	Reason     string    `json:"reason,omitempty"`
+	Outcome    string    `json:"outcome,omitempty"`
+	EntryIDs   []string  `json:"entry_ids,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// reserved reports whether the hold still counts against the balance
+func (h Hold) reserved() bool {
+	return h.Status == HoldActive || h.Status == HoldFinalizing
+}
+
+// Deposit credits a customer with funds received into the pooled account
+type Deposit struct {
+	Asset       string  `json:"asset" binding:"required"`
+	Amount      float64 `json:"amount" binding:"required"`
+	Reference   string  `json:"reference"`
+	Description string  `json:"description"`
+}
+
+// InsufficientFundsError reports that a payout exceeds the available balance
+type InsufficientFundsError struct {
+	CustomerID string  `json:"customer_id"`
+	Asset      string  `json:"asset"`
+	Available  float64 `json:"available"`
+	Requested  float64 `json:"requested"`
+}
+
+func (e *InsufficientFundsError) Error() string {
+	return fmt.Sprintf("customer %s has %g %s available, %g requested", e.CustomerID, e.Available, e.Asset, e.Requested)
+}
+
+func (e *InsufficientFundsError) Unwrap() error {
+	return ErrInsufficientFunds
+}
+
+// Ledger tracks what each customer owns of the pooled Bitnob balance. All
+// changes happen under one lock so a balance check and the hold it guards are
+// atomic.
+type Ledger struct {
+	mu       sync.Mutex
+	accounts map[string]*Account
+	entries  []Entry
+	holds    *store.Table[Hold]
+	audit    *audit.Log
+	holdTTL  time.Duration
+	logger   *log.Logger
+}
+
+// NewLedger creates an empty ledger. Holds without a quote expiry last holdTTL.
+func NewLedger(auditLog *audit.Log, holdTTL time.Duration) *Ledger {
+	return &Ledger{
+		accounts: make(map[string]*Account),
+		holds:    store.NewTable[Hold](),
+		audit:    auditLog,
+		holdTTL:  holdTTL,
+		logger:   log.Default(),
+	}
+}
+
+// WithLogger sends logs of expired holds and failed postings to logger, e.g. one tagged with the tenant
+func (l *Ledger) WithLogger(logger *log.Logger) *Ledger {
+	l.logger = logger
+	return l
+}
+
+// Start expires lapsed holds at the given interval until ctx is cancelled
+func (l *Ledger) Start(ctx context.Context, interval time.Duration) {
+	schedule.Every(ctx, interval, func(now time.Time) {
+		if n := l.Expire(now); n > 0 {
+			l.logger.Printf("Ledger expired %d payout holds", n)
+		}
+	})
+}
+
+// CustomerAccount names a customer's account for an asset
+func CustomerAccount(customerID, asset string) string {
+	return TypeCustomer + ":" + customerID + ":" + asset
+}
+
+func clearingAccount(asset string) string {
+	return TypeClearing + ":" + asset
+}
+
+func omnibusAccount(asset string) string {
+	return TypeOmnibus + ":" + asset
+}
+
+// Deposit credits a customer with funds that arrived in the pooled account
+func (l *Ledger) Deposit(customerID string, d Deposit, actor string) (Entry, error) {
+	customerID, asset := strings.TrimSpace(customerID), normalizeAsset(d.Asset)
+	if customerID == "" {
+		return Entry{}, ErrNoCustomer
+	}
+	if d.Amount <= 0 {
+		return Entry{}, ErrInvalidAmount
+	}
+
+	l.mu.Lock()
+	entry, err := l.post(KindDeposit, asset, customerID, d.Reference, d.Description,
+		Posting{Account: omnibusAccount(asset), Debit: d.Amount},
+		Posting{Account: CustomerAccount(customerID, asset), Credit: d.Amount},
+	)
+	l.mu.Unlock()
+	if err != nil {
+		return Entry{}, err
+	}
+	l.audit.Record(actor, "deposit", "ledger_entry", entry.ID, nil, entry)
+	return entry, nil
+}
+
+// Hold reserves amount of a customer's balance for the payout of quoteID. A
+// repeated hold for the same quote replaces the earlier one, so initializing
+// again re-checks the balance with the final amount.
+func (l *Ledger) Hold(customerID, asset string, amount float64, quoteID string, expiresAt time.Time) (Hold, error) {
+	customerID, asset = strings.TrimSpace(customerID), normalizeAsset(asset)
+	switch {
+	case customerID == "":
+		return Hold{}, ErrNoCustomer
+	case amount <= 0:
+		return Hold{}, ErrUnknownAmount
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now().UTC()
+	h, found := l.activeHold(quoteID)
+	if found && (h.CustomerID != customerID || h.Asset != asset) {
+		// The quote moved to another customer or asset; drop the old hold
+		h.Status, h.Reason, h.UpdatedAt = HoldReleased, "replaced", now
+		l.holds.Put(h.ID, h)
+		found = false
+	}
+
+	available := l.available(customerID, asset)
+	if found {
+		available += h.Amount
+	}
+	if amount > available+epsilon {
+		return Hold{}, &InsufficientFundsError{
+			CustomerID: customerID,
+			Asset:      asset,
+			Available:  round(available),
+			Requested:  round(amount),
+		}
+	}
+
+	if !found {
+		h = Hold{
+			ID:         store.NewID("hold"),
+			CustomerID: customerID,
+			Asset:      asset,
+			QuoteID:    quoteID,
+			Status:     HoldActive,
+			CreatedAt:  now,
+		}
+	}
+	if expiresAt.IsZero() {
+		expiresAt = now.Add(l.holdTTL)
+	}
+	h.Amount = round(amount)
+	h.ExpiresAt = expiresAt.UTC()
+	h.UpdatedAt = now
+	l.holds.Put(h.ID, h)
+	return h, nil
+}
+
+// Claim marks the hold for quoteID as finalizing so it cannot expire while
+// Bitnob is being called
+func (l *Ledger) Claim(quoteID string, now time.Time) (Hold, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h, ok := l.activeHold(quoteID)
+	if !ok || h.Status != HoldActive {
+		return Hold{}, fmt.Errorf("%w: quote %s", ErrNoHold, quoteID)
+	}
+	if now.After(h.ExpiresAt) {
+		h.Status, h.Reason, h.UpdatedAt = HoldExpired, "quote expired", now.UTC()
+		l.holds.Put(h.ID, h)
+		return Hold{}, fmt.Errorf("%w: hold for quote %s expired at %s", ErrNoHold, quoteID, h.ExpiresAt.Format(time.RFC3339))
+	}
+	h.Status, h.UpdatedAt = HoldFinalizing, now.UTC()
+	l.holds.Put(h.ID, h)
+	return h, nil
+}
+
+// Unclaim returns a finalizing hold to active after Bitnob rejected the finalize
+func (l *Ledger) Unclaim(quoteID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if h, ok := l.activeHold(quoteID); ok && h.Status == HoldFinalizing {
+		h.Status, h.UpdatedAt = HoldActive, time.Now().UTC()
+		if payoutOutcome(h.Outcome) == "failed" {
+			h.Status, h.Reason = HoldReleased, "payout "+h.Outcome
+		}
+		l.holds.Put(h.ID, h)
+	}
+}
+
+// Capture posts a finalized payout, moving the held amount from the customer
+// into clearing until Bitnob settles it
+func (l *Ledger) Capture(quoteID, payoutID string) (Hold, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h, ok := l.activeHold(quoteID)
+	if !ok {
+		return Hold{}, fmt.Errorf("%w: quote %s", ErrNoHold, quoteID)
+	}
+	entry, err := l.post(KindPayout, h.Asset, h.CustomerID, firstNonEmpty(payoutID, quoteID), "Payout finalized",
+		Posting{Account: CustomerAccount(h.CustomerID, h.Asset), Debit: h.Amount},
+		Posting{Account: clearingAccount(h.Asset), Credit: h.Amount},
+	)
+	if err != nil {
+		return Hold{}, err
+	}
+	h.Status, h.PayoutID, h.UpdatedAt = HoldCaptured, payoutID, entry.CreatedAt
+	h.EntryIDs = append(h.EntryIDs, entry.ID)
+	// An outcome reported while finalizing is applied now there is a
+	// posting to settle or reverse
+	if h.Outcome != "" {
+		if observed, ok := l.observe(h, h.Outcome); ok {
+			h = observed
+		}
+	}
+	l.holds.Put(h.ID, h)
+	return h, nil
+}
+
+// Release frees an active hold without posting, e.g. when initialize failed
+func (l *Ledger) Release(quoteID, reason string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if h, ok := l.activeHold(quoteID); ok && h.Status == HoldActive {
+		h.Status, h.Reason, h.UpdatedAt = HoldReleased, reason, time.Now().UTC()
+		l.holds.Put(h.ID, h)
+	}
+}
+
+// Observe applies a payout outcome reported by Bitnob. Success settles a
+// captured payout out of the pooled account; failure releases an unfinalized
+// hold or refunds a captured payout to the customer. An outcome that arrives
+// while the payout is finalizing is applied when it is captured.
+func (l *Ledger) Observe(quoteID, status string) {
+	outcome := payoutOutcome(status)
+	if outcome == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h, ok := l.holds.Find(func(h Hold) bool {
+		return h.QuoteID == quoteID && (h.reserved() || h.Status == HoldCaptured)
+	})
+	if !ok {
+		return
+	}
+	if h, ok = l.observe(h, status); ok {
+		l.holds.Put(h.ID, h)
+	}
+}
+
+// observe applies status to h and reports whether h changed; l.mu must be
+// held. Outcomes for a finalizing hold are kept until Capture.
+func (l *Ledger) observe(h Hold, status string) (Hold, bool) {
+	outcome := payoutOutcome(status)
+	quoteID := h.QuoteID
+	now := time.Now().UTC()
+	switch {
+	case h.Status == HoldFinalizing:
+		h.Outcome = status
+	case outcome == "failed" && h.Status == HoldActive:
+		h.Status, h.Reason = HoldReleased, "payout "+status
+	case outcome == "failed" && h.Status == HoldCaptured:
+		entry, err := l.post(KindReversal, h.Asset, h.CustomerID, firstNonEmpty(h.PayoutID, quoteID), "Payout "+status,
+			Posting{Account: clearingAccount(h.Asset), Debit: h.Amount},
+			Posting{Account: CustomerAccount(h.CustomerID, h.Asset), Credit: h.Amount},
+		)
+		if err != nil {
+			l.logger.Printf("Ledger failed to reverse payout %s: %v", quoteID, err)
+			return h, false
+		}
+		h.Status, h.Reason = HoldReversed, "payout "+status
+		h.EntryIDs = append(h.EntryIDs, entry.ID)
+	case outcome == "settled" && h.Status == HoldCaptured:
+		entry, err := l.post(KindSettlement, h.Asset, h.CustomerID, firstNonEmpty(h.PayoutID, quoteID), "Payout settled",
+			Posting{Account: clearingAccount(h.Asset), Debit: h.Amount},
+			Posting{Account: omnibusAccount(h.Asset), Credit: h.Amount},
+		)
+		if err != nil {
+			l.logger.Printf("Ledger failed to settle payout %s: %v", quoteID, err)
+			return h, false
+		}
+		h.Status = HoldSettled
+		h.EntryIDs = append(h.EntryIDs, entry.ID)
+	default:
+		return h, false
+	}
+	h.UpdatedAt = now
+	return h, true
+}
+
+// Expire releases active holds whose quote has expired and returns how many
+func (l *Ledger) Expire(now time.Time) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	expired := l.holds.List(func(h Hold) bool {
+		return h.Status == HoldActive && now.After(h.ExpiresAt)
+	})
+	for _, h := range expired {
+		h.Status, h.Reason, h.UpdatedAt = HoldExpired, "quote expired", now.UTC()
+		l.holds.Put(h.ID, h)
+	}
+	return len(expired)
+}
+
+// Balances returns a customer's balance in every asset they have used
+func (l *Ledger) Balances(customerID string) []Balance {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	assets := map[string]bool{}
+	for _, a := range l.accounts {
+		if a.Type == TypeCustomer && a.CustomerID == customerID {
+			assets[a.Asset] = true
+		}
+	}
+	for _, h := range l.holds.List(func(h Hold) bool { return h.CustomerID == customerID && h.reserved() }) {
+		assets[h.Asset] = true
+	}
+
+	out := make([]Balance, 0, len(assets))
+	for asset := range assets {
+		out = append(out, l.balance(customerID, asset))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Asset < out[j].Asset })
+	return out
+}
+
+// Holds returns a customer's holds, newest first, optionally in one status
+func (l *Ledger) Holds(customerID, status string) []Hold {
+	holds := l.holds.List(func(h Hold) bool {
+		return (customerID == "" || h.CustomerID == customerID) && (status == "" || h.Status == status)
+	})
+	sort.Slice(holds, func(i, j int) bool { return holds[i].CreatedAt.After(holds[j].CreatedAt) })
+	return holds
+}
+
+// Entries returns posted entries oldest first, optionally for one customer
+// and asset
+func (l *Ledger) Entries(customerID, asset string) []Entry {
+	asset = normalizeAsset(asset)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := []Entry{}
+	for _, e := range l.entries {
+		if (customerID == "" || e.CustomerID == customerID) && (asset == "" || e.Asset == asset) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Accounts returns every account, a trial balance of the ledger
+func (l *Ledger) Accounts() []Account {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := make([]Account, 0, len(l.accounts))
+	for _, a := range l.accounts {
+		out = append(out, *a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// post records a balanced entry and updates account totals. The caller holds
+// l.mu.
+func (l *Ledger) post(kind, asset, customerID, reference, description string, postings ...Posting) (Entry, error) {
+	var debits, credits float64
+	for _, p := range postings {
+		debits += p.Debit
+		credits += p.Credit
+	}
+	if math.Abs(debits-credits) > epsilon {
+		return Entry{}, fmt.Errorf("%w: %g debits, %g credits", ErrUnbalanced, debits, credits)
+	}
+
+	entry := Entry{
+		ID:          store.NewID("jrn"),
+		Kind:        kind,
+		Asset:       asset,
+		CustomerID:  customerID,
+		Reference:   reference,
+		Description: description,
+		Postings:    postings,
+		CreatedAt:   time.Now().UTC(),
+	}
+	for _, p := range postings {
+		a := l.account(p.Account, asset, customerID)
+		a.Debits = round(a.Debits + p.Debit)
+		a.Credits = round(a.Credits + p.Credit)
+		if a.Type == TypeOmnibus {
+			a.Balance = round(a.Debits - a.Credits)
+		} else {
+			a.Balance = round(a.Credits - a.Debits)
+		}
+	}
+	l.entries = append(l.entries, entry)
+	return entry, nil
+}
+
+func (l *Ledger) account(id, asset, customerID string) *Account {
+	if a, ok := l.accounts[id]; ok {
+		return a
+	}
+	a := &Account{ID: id, Type: id[:strings.Index(id, ":")], Asset: asset}
+	if a.Type == TypeCustomer {
+		a.CustomerID = customerID
+	}
+	l.accounts[id] = a
+	return a
+}
+
+// activeHold finds the open hold for a quote. The caller holds l.mu.
+func (l *Ledger) activeHold(quoteID string) (Hold, bool) {
+	return l.holds.Find(func(h Hold) bool { return h.QuoteID == quoteID && h.reserved() })
+}
+
+// balance computes a customer balance. The caller holds l.mu.
+func (l *Ledger) balance(customerID, asset string) Balance {
+	b := Balance{CustomerID: customerID, Asset: asset, Account: CustomerAccount(customerID, asset)}
+	if a, ok := l.accounts[b.Account]; ok {
+		b.Balance = a.Balance
+	}
+	for _, h := range l.holds.List(func(h Hold) bool {
+		return h.CustomerID == customerID && h.Asset == asset && h.reserved()
+	}) {
+		b.Held += h.Amount
+	}
+	b.Held = round(b.Held)
+	b.Available = round(b.Balance - b.Held)
+	return b
+}
+
+func (l *Ledger) available(customerID, asset string) float64 {
+	return l.balance(customerID, asset).Available
+}
+
+// payoutOutcome reduces a Bitnob payout status to settled or failed, or ""
+// while the payout is still in progress
+func payoutOutcome(status string) string {
+	switch strings.ToLower(strings.TrimSpace(status)) {
+	case "success", "successful", "succeeded", "completed", "complete", "settled", "paid":
+		return "settled"
+	case "failed", "failure", "cancelled", "canceled", "rejected", "expired", "reversed":
+		return "failed"
+	}
+	return ""
+}
+
+func normalizeAsset(asset string) string {
+	return strings.ToUpper(strings.TrimSpace(asset))
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// round trims float noise from balances
+func round(v float64) float64 {
+	return math.Round(v*1e8) / 1e8
+}
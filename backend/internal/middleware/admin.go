@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminKeyHeader carries the key that authenticates an operator on admin
+// routes, separately from the tenant API key
+const AdminKeyHeader = "X-Admin-Key"
+
+// ActorKey holds the operator an admin key belongs to in the request context
+const ActorKey = "actor"
+
+// ParseAdminKeys reads "operator=key,..." into a map of operator to key
+func ParseAdminKeys(spec string) (map[string]string, error) {
+	keys := map[string]string{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		operator, key, ok := strings.Cut(entry, "=")
+		operator, key = strings.TrimSpace(operator), strings.TrimSpace(key)
+		if !ok || operator == "" || key == "" {
+			return nil, fmt.Errorf("invalid admin key %q, expected operator=key", entry)
+		}
+		keys[operator] = key
+	}
+	return keys, nil
+}
+
+// Admin admits requests bearing one of the operators' admin keys and records
+// the operator as the request's actor. A tenant without API keys serves
+// anyone, so it refuses admin routes outright.
+func Admin(keys map[string]string, open bool) gin.HandlerFunc {
+	operators := make(map[[sha256.Size]byte]string, len(keys))
+	for operator, key := range keys {
+		operators[sha256.Sum256([]byte(key))] = operator
+	}
+	return func(c *gin.Context) {
+		if open || len(operators) == 0 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "Admin routes are disabled",
+				"details": "admin routes need a tenant with API keys and ADMIN_API_KEYS, or admin_keys for the tenant",
+			})
+			return
+		}
+		key := c.GetHeader(AdminKeyHeader)
+		operator, ok := operators[sha256.Sum256([]byte(key))]
+		if key == "" || !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   "Invalid or missing admin key",
+			})
+			return
+		}
+		c.Set(ActorKey, operator)
+		c.Next()
+	}
+}
@@ -1,24 +1,41 @@
 package middleware
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"time"
 )
 
-func CORS() gin.HandlerFunc {
+// DefaultOrigins are the browser origins allowed when a tenant sets none
+var DefaultOrigins = []string{"http://localhost:3000", "http://localhost:3001", "http://127.0.2.2:3000", "http://127.0.0.1:3000"}
+
+func CORS(origins []string) gin.HandlerFunc {
 	return cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000", "http://localhost:3001", "http://127.0.2.2:3000", "http://127.0.0.1:3000"},
+		AllowOrigins:     origins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Actor", "X-API-Key", AdminKeyHeader},
+		ExposeHeaders:    []string{"Content-Length", EnvironmentHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	})
 }
 
-func Logger() gin.HandlerFunc {
-	return gin.Logger()
+// Logger writes access logs tagged with the tenant that served the request
+func Logger(tenantID string) gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(p gin.LogFormatterParams) string {
+		return fmt.Sprintf("[GIN] [tenant %s] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+			tenantID,
+			p.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			p.StatusCode,
+			p.Latency,
+			p.ClientIP,
+			p.Method,
+			p.Path,
+			p.ErrorMessage,
+		)
+	})
 }
 
 func Recovery() gin.HandlerFunc {
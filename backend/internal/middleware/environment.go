@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bitnob-api-demo/internal/bitnob"
+	"github.com/gin-gonic/gin"
+)
+
+// EnvironmentHeader names the Bitnob environment that served a response
+const EnvironmentHeader = "X-Bitnob-Environment"
+
+// Environment tags every response with the Bitnob environment: a header on
+// all responses and an "environment" field on JSON objects. Streams, files
+// and WebSockets pass through untouched.
+func Environment(env string) gin.HandlerFunc {
+	field := []byte(`"environment":` + strconv.Quote(env))
+	return func(c *gin.Context) {
+		c.Header(EnvironmentHeader, env)
+		w := &environmentWriter{ResponseWriter: c.Writer, field: field}
+		c.Writer = w
+		c.Next()
+		w.flush()
+	}
+}
+
+// MoneyMovement refuses a route that moves funds in production unless the
+// deployment has explicitly confirmed it
+func MoneyMovement(env string, confirmed bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if env == bitnob.Production && !confirmed {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "Money movement is disabled in production",
+				"details": "set BITNOB_CONFIRM_PRODUCTION=true, or confirm_production for the tenant, to allow it",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// environmentWriter buffers JSON bodies so the environment field can be
+// added once the handler has finished
+type environmentWriter struct {
+	gin.ResponseWriter
+	field []byte
+	buf   *bytes.Buffer
+	plain bool
+}
+
+func (w *environmentWriter) Write(b []byte) (int, error) {
+	if !w.buffering() {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.buf.Write(b)
+}
+
+func (w *environmentWriter) WriteString(s string) (int, error) {
+	if !w.buffering() {
+		return w.ResponseWriter.WriteString(s)
+	}
+	return w.buf.WriteString(s)
+}
+
+// buffering decides on the first write whether the body is JSON
+func (w *environmentWriter) buffering() bool {
+	if w.buf == nil && !w.plain {
+		if strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+			w.buf = &bytes.Buffer{}
+		} else {
+			w.plain = true
+		}
+	}
+	return w.buf != nil
+}
+
+func (w *environmentWriter) flush() {
+	if w.buf == nil {
+		return
+	}
+	body := w.buf.Bytes()
+	if trimmed := bytes.TrimLeft(body, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '{' {
+		rest := bytes.TrimLeft(trimmed[1:], " \t\r\n")
+		out := make([]byte, 0, len(body)+len(w.field)+2)
+		out = append(out, '{')
+		out = append(out, w.field...)
+		if len(rest) > 0 && rest[0] != '}' {
+			out = append(out, ',')
+		}
+		body = append(out, rest...)
+	}
+	w.buf = nil
+	w.ResponseWriter.Write(body)
+}
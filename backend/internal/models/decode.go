@@ -0,0 +1,25 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Decode converts a loosely-typed Bitnob response into a typed model. Responses
+// wrapped in a {"data": ...} envelope are unwrapped first.
+func Decode(raw interface{}, out interface{}) error {
+	if envelope, ok := raw.(map[string]interface{}); ok {
+		if data, ok := envelope["data"]; ok && data != nil {
+			raw = data
+		}
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("failed to encode response: %w", err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
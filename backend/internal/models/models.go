@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // Transfer Models
 type TransferRequest struct {
@@ -10,6 +13,9 @@ type TransferRequest struct {
 	Chain       string `json:"chain" binding:"required"`
 	Reference   string `json:"reference"`
 	Description string `json:"description"`
+
+	// IdempotencyKey is sent as the Idempotency-Key header
+	IdempotencyKey string `json:"-"`
 }
 
 type TransferResponse struct {
@@ -37,6 +43,16 @@ type PayoutQuoteRequest struct {
 	Chain            string  `json:"chain"`
 	Amount           float64 `json:"amount"`
 	SettlementAmount float64 `json:"settlementAmount"`
+
+	// Country selects the transaction limits; Bitnob's quote endpoint does
+	// not take it
+	Country string `json:"-"`
+}
+
+// PayoutQuoteInput is the body of a payout quote request
+type PayoutQuoteInput struct {
+	PayoutQuoteRequest
+	Country string `json:"country"`
 }
 
 type PayoutQuoteResponse struct {
@@ -63,16 +79,26 @@ type BeneficiaryDetails struct {
 	PhoneNumber   string `json:"phoneNumber,omitempty"`
 }
 
+// Masked returns a copy that shows only the last four digits of the account
+// number, for responses and logs
+func (d BeneficiaryDetails) Masked() BeneficiaryDetails {
+	if n := len(d.AccountNumber); n > 4 {
+		d.AccountNumber = strings.Repeat("*", n-4) + d.AccountNumber[n-4:]
+	}
+	return d
+}
+
 type InitializePayoutRequest struct {
-	QuoteID        string                 `json:"quoteId" binding:"required"`
-	CustomerID     string                 `json:"customerId" binding:"required"`
-	Country        string                 `json:"country" binding:"required"`
-	Reference      string                 `json:"reference" binding:"required"`
-	PaymentReason  string                 `json:"paymentReason" binding:"required"`
-	BeneficiaryID  string                 `json:"beneficiaryId,omitempty"`
-	Beneficiary    *BeneficiaryDetails    `json:"beneficiary,omitempty"`
-	CallbackURL    string                 `json:"callbackUrl,omitempty"`
-	ClientMetaData map[string]interface{} `json:"clientMetaData,omitempty"`
+	QuoteID            string                 `json:"quoteId" binding:"required"`
+	CustomerID         string                 `json:"customerId" binding:"required"`
+	Country            string                 `json:"country" binding:"required"`
+	Reference          string                 `json:"reference" binding:"required"`
+	PaymentReason      string                 `json:"paymentReason" binding:"required"`
+	BeneficiaryID      string                 `json:"beneficiaryId,omitempty"`
+	Beneficiary        *BeneficiaryDetails    `json:"beneficiary,omitempty"`
+	SavedBeneficiaryID string                 `json:"savedBeneficiaryId,omitempty"`
+	CallbackURL        string                 `json:"callbackUrl,omitempty"`
+	ClientMetaData     map[string]interface{} `json:"clientMetaData,omitempty"`
 }
 
 type InitializePayoutResponse struct {
@@ -98,6 +124,18 @@ type InitializePayoutResponse struct {
 	SettlementAmount   float64             `json:"settlementAmount"`
 }
 
+type SavedBeneficiaryRequest struct {
+	Label       string             `json:"label" binding:"required"`
+	Country     string             `json:"country" binding:"required"`
+	CustomerID  string             `json:"customerId"`
+	Beneficiary BeneficiaryDetails `json:"beneficiary" binding:"required"`
+}
+
+type BeneficiaryVerificationRequest struct {
+	Status       string `json:"status" binding:"required"`
+	VerifiedName string `json:"verifiedName"`
+}
+
 type FinalizePayoutRequest struct {
 	QuoteID string `json:"quoteId" binding:"required"`
 }
@@ -182,3 +220,38 @@ type OrderResponse struct {
 	} `json:"metadata"`
 	Timestamp time.Time `json:"timestamp"`
 }
+
+// Address Book Models
+type SavedAddressRequest struct {
+	Label    string `json:"label" binding:"required"`
+	Chain    string `json:"chain" binding:"required"`
+	Currency string `json:"currency"`
+	Address  string `json:"address" binding:"required"`
+	Owner    string `json:"owner"`
+}
+
+// Batch Models
+type PayoutBatchRow struct {
+	Reference          string              `json:"reference"`
+	CustomerID         string              `json:"customerId"`
+	Country            string              `json:"country"`
+	Source             string              `json:"source"`
+	FromAsset          string              `json:"fromAsset"`
+	ToCurrency         string              `json:"toCurrency"`
+	Chain              string              `json:"chain"`
+	Amount             float64             `json:"amount"`
+	SettlementAmount   float64             `json:"settlementAmount"`
+	PaymentReason      string              `json:"paymentReason"`
+	SavedBeneficiaryID string              `json:"savedBeneficiaryId,omitempty"`
+	Beneficiary        *BeneficiaryDetails `json:"beneficiary,omitempty"`
+}
+
+type BatchConfirmRequest struct {
+	SkipInvalid bool `json:"skipInvalid"`
+}
+
+// Credential Models
+type StageCredentialsRequest struct {
+	ClientID     string `json:"client_id" binding:"required"`
+	ClientSecret string `json:"client_secret" binding:"required"`
+}
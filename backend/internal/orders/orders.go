@@ -0,0 +1,442 @@
+package orders
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/events"
+	"github.com/bitnob-api-demo/internal/models"
+	"github.com/bitnob-api-demo/internal/schedule"
+	"github.com/bitnob-api-demo/internal/store"
+)
+
+// Page size bounds for Query
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+// Sort keys accepted by Query; prefix with "-" for descending
+var sortKeys = map[string]func(Order) float64{
+	"created_at": func(o Order) float64 { return float64(o.CreatedAt.UnixNano()) },
+	"updated_at": func(o Order) float64 { return float64(o.UpdatedAt.UnixNano()) },
+	"price":      func(o Order) float64 { return parseFloat(o.Price) },
+	"quantity":   func(o Order) float64 { return parseFloat(o.Quantity) },
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// terminalStatuses are order states that no longer change upstream
+var terminalStatuses = map[string]bool{
+	"filled":    true,
+	"cancelled": true,
+	"canceled":  true,
+	"rejected":  true,
+	"expired":   true,
+	"failed":    true,
+}
+
+// IsTerminal reports whether an order status is final
+func IsTerminal(status string) bool {
+	return terminalStatuses[strings.ToLower(status)]
+}
+
+// TradingClient is the subset of the Bitnob client used to sync orders
+type TradingClient interface {
+	GetOrders() (interface{}, error)
+	GetOrderByID(id string) (interface{}, error)
+}
+
+// Order is a Bitnob order as last seen by the gateway
+type Order struct {
+	models.OrderResponse
+	SyncedAt time.Time `json:"synced_at"`
+}
+
+// Filter selects and orders a page of orders
+type Filter struct {
+	Pair   string
+	Side   string
+	Status string
+	From   time.Time
+	To     time.Time
+	Sort   string
+	Limit  int
+	Cursor string
+}
+
+// Page is one page of query results
+type Page struct {
+	Orders     []Order `json:"orders"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	Total      int     `json:"total"`
+	Limit      int     `json:"limit"`
+}
+
+// cursor marks the last row of a page by its sort key and ID
+type cursor struct {
+	Sort string  `json:"s"`
+	Key  float64 `json:"k"`
+	ID   string  `json:"id"`
+}
+
+// EventOrderUpdated is published on topic "order:{id}" whenever an order's
+// status or fill quantities change
+const EventOrderUpdated = "order.updated"
+
+// PollConfig controls how often non-terminal orders are refreshed. Each
+// order starts at MinInterval and backs off to MaxInterval while unchanged.
+type PollConfig struct {
+	Tick        time.Duration
+	MinInterval time.Duration
+	MaxInterval time.Duration
+}
+
+// pollState is the backoff schedule for one tracked order
+type pollState struct {
+	next     time.Time
+	interval time.Duration
+}
+
+// Store is the local orders table kept in sync with Bitnob
+type Store struct {
+	client TradingClient
+	events *events.Broker
+	orders *store.Table[Order]
+	logger *log.Logger
+
+	mu    sync.Mutex
+	poll  PollConfig
+	polls map[string]*pollState
+}
+
+// NewStore creates an empty orders table that publishes changes to broker
+func NewStore(client TradingClient, broker *events.Broker, poll PollConfig) *Store {
+	return &Store{
+		client: client,
+		events: broker,
+		orders: store.NewTable[Order](),
+		poll:   poll,
+		polls:  make(map[string]*pollState),
+		logger: log.Default(),
+	}
+}
+
+// WithLogger sends logs of sync and poller failures to logger, e.g. one tagged with the tenant
+func (s *Store) WithLogger(logger *log.Logger) *Store {
+	s.logger = logger
+	return s
+}
+
+// StartPoller refreshes tracked orders as their backoff comes due until ctx
+// is cancelled
+func (s *Store) StartPoller(ctx context.Context) {
+	schedule.Every(ctx, s.poll.Tick, s.pollDue)
+}
+
+// Start syncs immediately and then at the given interval until ctx is cancelled
+func (s *Store) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		if err := s.Sync(); err != nil {
+			s.logger.Printf("Order sync failed: %v", err)
+		}
+	}()
+	schedule.Every(ctx, interval, func(time.Time) {
+		if err := s.Sync(); err != nil {
+			s.logger.Printf("Order sync failed: %v", err)
+		}
+	})
+}
+
+// Record upserts an order from a Bitnob order response
+func (s *Store) Record(raw interface{}) (Order, error) {
+	var resp models.OrderResponse
+	if err := models.Decode(raw, &resp); err != nil {
+		return Order{}, err
+	}
+	if resp.ID == "" {
+		return Order{}, errors.New("order response has no id")
+	}
+	o, _ := s.upsert(resp, time.Now().UTC())
+	return o, nil
+}
+
+// upsert stores an order, publishes it if it changed and starts or stops
+// polling it. It reports whether the order changed. A response older than
+// the stored order, such as a list fetched before the poller saw a fill, is
+// ignored.
+func (s *Store) upsert(resp models.OrderResponse, now time.Time) (Order, bool) {
+	var prev Order
+	var existed bool
+	o, err := s.orders.Upsert(resp.ID, func(row *Order, exists bool) error {
+		prev, existed = *row, exists
+		if exists && stale(*row, resp) {
+			return errStale
+		}
+		*row = Order{OrderResponse: resp, SyncedAt: now}
+		return nil
+	})
+	if err != nil {
+		o = prev
+	}
+
+	changed := err == nil && (!existed ||
+		prev.Status != o.Status ||
+		prev.FilledQuantity != o.FilledQuantity ||
+		prev.RemainingQuantity != o.RemainingQuantity)
+	if changed && s.events != nil {
+		s.events.Publish("order:"+o.ID, EventOrderUpdated, o)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case IsTerminal(o.Status):
+		delete(s.polls, o.ID)
+	case changed || s.polls[o.ID] == nil:
+		// New activity restarts the backoff
+		s.polls[o.ID] = &pollState{next: now.Add(s.poll.MinInterval), interval: s.poll.MinInterval}
+	}
+	return o, changed
+}
+
+// errStale rejects a response that would move an order back in time
+var errStale = errors.New("stale order response")
+
+// stale reports whether resp is older than the stored order: a terminal
+// status never changes, and a response updated before the stored one is old
+func stale(stored Order, resp models.OrderResponse) bool {
+	if IsTerminal(stored.Status) && !strings.EqualFold(stored.Status, resp.Status) {
+		return true
+	}
+	return !resp.UpdatedAt.IsZero() && resp.UpdatedAt.Before(stored.UpdatedAt)
+}
+
+// Tracked returns the IDs of orders the poller is watching
+func (s *Store) Tracked() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.polls))
+	for id := range s.polls {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (s *Store) pollDue(now time.Time) {
+	s.mu.Lock()
+	var due []string
+	for id, p := range s.polls {
+		if !now.Before(p.next) {
+			due = append(due, id)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, id := range due {
+		_, changed, err := s.refresh(id)
+		if err != nil {
+			s.logger.Printf("Order poller: refresh %s: %v", id, err)
+		}
+		if changed {
+			continue
+		}
+
+		// Unchanged or failed: double the interval up to the maximum
+		s.mu.Lock()
+		if p := s.polls[id]; p != nil {
+			p.interval = min(p.interval*2, s.poll.MaxInterval)
+			p.next = time.Now().Add(p.interval)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Get returns a locally stored order
+func (s *Store) Get(id string) (Order, bool) {
+	return s.orders.Get(id)
+}
+
+// List returns every locally stored order, oldest first
+func (s *Store) List() []Order {
+	rows := s.orders.List(nil)
+	sort.Slice(rows, func(i, j int) bool {
+		if !rows[i].CreatedAt.Equal(rows[j].CreatedAt) {
+			return rows[i].CreatedAt.Before(rows[j].CreatedAt)
+		}
+		return rows[i].ID < rows[j].ID
+	})
+	return rows
+}
+
+// Sync pulls the order list from Bitnob. Orders that are not yet terminal
+// are handed to the poller, which refreshes them with GetOrderByID.
+func (s *Store) Sync() error {
+	raw, err := s.client.GetOrders()
+	if err != nil {
+		return fmt.Errorf("list orders: %w", err)
+	}
+	var list []models.OrderResponse
+	if err := models.Decode(raw, &list); err != nil {
+		return fmt.Errorf("list orders: %w", err)
+	}
+
+	now := time.Now().UTC()
+	for _, resp := range list {
+		if resp.ID != "" {
+			s.upsert(resp, now)
+		}
+	}
+	return nil
+}
+
+// Refresh reloads one order from Bitnob
+func (s *Store) Refresh(id string) (Order, error) {
+	o, _, err := s.refresh(id)
+	return o, err
+}
+
+func (s *Store) refresh(id string) (Order, bool, error) {
+	raw, err := s.client.GetOrderByID(id)
+	if err != nil {
+		return Order{}, false, err
+	}
+	var resp models.OrderResponse
+	if err := models.Decode(raw, &resp); err != nil {
+		return Order{}, false, err
+	}
+	if resp.ID == "" {
+		resp.ID = id
+	}
+	o, changed := s.upsert(resp, time.Now().UTC())
+	return o, changed, nil
+}
+
+// Query filters, sorts and pages the local orders. The cursor is opaque to
+// callers and only valid with the sort it was issued for.
+func (s *Store) Query(f Filter) (Page, error) {
+	sortName := strings.TrimPrefix(f.Sort, "-")
+	desc := strings.HasPrefix(f.Sort, "-")
+	if f.Sort == "" {
+		sortName, desc = "created_at", true
+	}
+	key, ok := sortKeys[sortName]
+	if !ok {
+		return Page{}, fmt.Errorf("unsupported sort %q", f.Sort)
+	}
+	sortID := f.Sort
+	if sortID == "" {
+		sortID = "-created_at"
+	}
+
+	limit := f.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	limit = min(limit, MaxLimit)
+
+	base, quote := splitPair(f.Pair)
+	rows := s.orders.List(func(o Order) bool {
+		switch {
+		case base != "" && !strings.EqualFold(o.BaseCurrency, base):
+			return false
+		case quote != "" && !strings.EqualFold(o.QuoteCurrency, quote):
+			return false
+		case f.Side != "" && !strings.EqualFold(o.Side, f.Side):
+			return false
+		case f.Status != "" && !strings.EqualFold(o.Status, f.Status):
+			return false
+		case !f.From.IsZero() && o.CreatedAt.Before(f.From):
+			return false
+		case !f.To.IsZero() && !o.CreatedAt.Before(f.To):
+			return false
+		}
+		return true
+	})
+
+	less := func(a, b Order) bool {
+		ka, kb := key(a), key(b)
+		if ka != kb {
+			return (ka < kb) != desc
+		}
+		return (a.ID < b.ID) != desc
+	}
+	sort.Slice(rows, func(i, j int) bool { return less(rows[i], rows[j]) })
+	total := len(rows)
+
+	if f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor)
+		if err != nil || c.Sort != sortID {
+			return Page{}, ErrInvalidCursor
+		}
+		// Resume strictly after the row the cursor points at
+		start := sort.Search(len(rows), func(i int) bool {
+			ki := key(rows[i])
+			switch {
+			case ki != c.Key && desc:
+				return ki < c.Key
+			case ki != c.Key:
+				return ki > c.Key
+			case desc:
+				return rows[i].ID < c.ID
+			default:
+				return rows[i].ID > c.ID
+			}
+		})
+		rows = rows[start:]
+	}
+
+	page := Page{Total: total, Limit: limit}
+	if len(rows) > limit {
+		last := rows[limit-1]
+		page.NextCursor = encodeCursor(cursor{Sort: sortID, Key: key(last), ID: last.ID})
+		rows = rows[:limit]
+	}
+	page.Orders = rows
+	return page, nil
+}
+
+// splitPair accepts BTC/USDT, BTC-USDT or BTC_USDT; a single currency
+// matches the base side
+func splitPair(pair string) (string, string) {
+	pair = strings.TrimSpace(pair)
+	if pair == "" {
+		return "", ""
+	}
+	for _, sep := range []string{"/", "-", "_"} {
+		if base, quote, ok := strings.Cut(pair, sep); ok {
+			return base, quote
+		}
+	}
+	return pair, ""
+}
+
+func encodeCursor(c cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(b, &c)
+	return c, err
+}
+
+func parseFloat(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
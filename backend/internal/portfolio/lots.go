@@ -0,0 +1,301 @@
+package portfolio
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/orders"
+)
+
+// Cost basis methods
+const (
+	MethodFIFO    = "fifo"
+	MethodLIFO    = "lifo"
+	MethodAverage = "average"
+)
+
+// dust is the quantity below which a lot or position is treated as empty
+const dust = 1e-12
+
+var ErrInvalidMethod = errors.New("cost method must be fifo, lifo or average")
+
+// ParseMethod normalizes a cost basis method name
+func ParseMethod(method string) (string, error) {
+	switch m := strings.ToLower(strings.TrimSpace(method)); m {
+	case MethodFIFO, MethodLIFO, MethodAverage:
+		return m, nil
+	}
+	return "", ErrInvalidMethod
+}
+
+// Trade is one execution taken from an order's fills. Fee is in the quote
+// currency and is added to the cost of buys and deducted from sale proceeds.
+type Trade struct {
+	OrderID       string    `json:"order_id"`
+	BaseCurrency  string    `json:"base_currency"`
+	QuoteCurrency string    `json:"quote_currency"`
+	Side          string    `json:"side"`
+	Quantity      float64   `json:"quantity"`
+	Price         float64   `json:"price"`
+	Fee           float64   `json:"fee,omitempty"`
+	At            time.Time `json:"at"`
+}
+
+// RateFunc returns the multiplier from currency into the common currency
+// lots are costed in, as of at
+type RateFunc func(currency string, at time.Time) (float64, error)
+
+// Lot is an open acquisition with its remaining quantity. UnitCost is in the
+// common currency, converted when the lot was acquired.
+type Lot struct {
+	OrderID    string    `json:"order_id"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	Quantity   float64   `json:"quantity"`
+	UnitCost   float64   `json:"unit_cost"`
+}
+
+// LotMatch is the part of an acquisition lot consumed by a disposal
+type LotMatch struct {
+	OrderID    string    `json:"order_id"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	Quantity   float64   `json:"quantity"`
+	CostBasis  float64   `json:"cost_basis"`
+}
+
+// Disposal is a sell matched against acquisition lots, which may have been
+// bought with another currency. Amounts are in the common currency, with
+// proceeds converted at Rate when sold. Quantity sold beyond the tracked
+// holdings is reported as unmatched with no cost basis.
+type Disposal struct {
+	OrderID       string     `json:"order_id"`
+	BaseCurrency  string     `json:"base_currency"`
+	QuoteCurrency string     `json:"quote_currency"`
+	DisposedAt    time.Time  `json:"disposed_at"`
+	Quantity      float64    `json:"quantity"`
+	Rate          float64    `json:"rate"`
+	Proceeds      float64    `json:"proceeds"`
+	CostBasis     float64    `json:"cost_basis"`
+	Gain          float64    `json:"gain"`
+	Lots          []LotMatch `json:"lots"`
+	Unmatched     float64    `json:"unmatched_quantity,omitempty"`
+}
+
+// Holding is what remains of an asset after matching, with its cost in the
+// common currency. QuoteCurrency is the currency it was last traded against.
+type Holding struct {
+	BaseCurrency  string
+	QuoteCurrency string
+	Quantity      float64
+	CostBasis     float64
+	Lots          []Lot
+	Trades        int
+}
+
+// book tracks the open lots of one asset
+type book struct {
+	method string
+	Holding
+}
+
+func (b *book) buy(t Trade, rate float64) {
+	cost := (t.Quantity*t.Price + t.Fee) * rate
+	b.Lots = append(b.Lots, Lot{
+		OrderID:    t.OrderID,
+		AcquiredAt: t.At,
+		Quantity:   t.Quantity,
+		UnitCost:   cost / t.Quantity,
+	})
+	b.Quantity += t.Quantity
+	b.CostBasis += cost
+}
+
+// sell consumes lots in method order. Under the average method lots are
+// still consumed oldest first for their dates, but every unit is costed at
+// the pooled average so the average of the remainder is unchanged.
+func (b *book) sell(t Trade, rate float64) Disposal {
+	d := Disposal{
+		OrderID:       t.OrderID,
+		BaseCurrency:  t.BaseCurrency,
+		QuoteCurrency: t.QuoteCurrency,
+		DisposedAt:    t.At,
+		Quantity:      t.Quantity,
+		Rate:          rate,
+		Proceeds:      (t.Quantity*t.Price - t.Fee) * rate,
+	}
+
+	var avg float64
+	if b.Quantity > dust {
+		avg = b.CostBasis / b.Quantity
+	}
+
+	remaining := t.Quantity
+	for remaining > dust && len(b.Lots) > 0 {
+		i := 0
+		if b.method == MethodLIFO {
+			i = len(b.Lots) - 1
+		}
+		lot := &b.Lots[i]
+
+		take := min(remaining, lot.Quantity)
+		basis := take * lot.UnitCost
+		if b.method == MethodAverage {
+			basis = take * avg
+		}
+		d.Lots = append(d.Lots, LotMatch{
+			OrderID:    lot.OrderID,
+			AcquiredAt: lot.AcquiredAt,
+			Quantity:   take,
+			CostBasis:  basis,
+		})
+		d.CostBasis += basis
+
+		lot.Quantity -= take
+		remaining -= take
+		if lot.Quantity <= dust {
+			b.Lots = append(b.Lots[:i], b.Lots[i+1:]...)
+		}
+	}
+
+	b.Quantity = max(b.Quantity-(t.Quantity-remaining), 0)
+	b.CostBasis = max(b.CostBasis-d.CostBasis, 0)
+	if b.Quantity <= dust {
+		b.Quantity, b.CostBasis = 0, 0
+	}
+	if remaining > dust {
+		d.Unmatched = remaining
+	}
+	d.Gain = d.Proceeds - d.CostBasis
+	return d
+}
+
+// Match replays trades in time order and returns the holdings left per asset
+// and every disposal, using the given cost basis method. Lots are pooled per
+// asset whatever they were traded against, so every trade is converted into
+// the common currency with rate at the time it executed.
+func Match(trades []Trade, method string, rate RateFunc) ([]Holding, []Disposal, error) {
+	books := make(map[string]*book)
+	var keys []string
+	var disposals []Disposal
+
+	for _, t := range trades {
+		r, err := rate(t.QuoteCurrency, t.At)
+		if err != nil {
+			return nil, nil, fmt.Errorf("order %s: %w", t.OrderID, err)
+		}
+
+		b := books[t.BaseCurrency]
+		if b == nil {
+			b = &book{method: method, Holding: Holding{BaseCurrency: t.BaseCurrency}}
+			books[t.BaseCurrency] = b
+			keys = append(keys, t.BaseCurrency)
+		}
+		b.QuoteCurrency = t.QuoteCurrency
+		b.Trades++
+
+		if t.Side == "sell" {
+			disposals = append(disposals, b.sell(t, r))
+		} else {
+			b.buy(t, r)
+		}
+	}
+
+	sort.Strings(keys)
+	holdings := make([]Holding, 0, len(keys))
+	for _, k := range keys {
+		holdings = append(holdings, books[k].Holding)
+	}
+	return holdings, disposals, nil
+}
+
+// Trades extracts executions from orders in time order. Orders that report
+// fills contribute one trade per fill; otherwise the filled quantity is
+// taken at the order price.
+func Trades(list []orders.Order) []Trade {
+	var trades []Trade
+	for _, o := range list {
+		base := strings.ToUpper(o.BaseCurrency)
+		quote := strings.ToUpper(o.QuoteCurrency)
+		side := strings.ToLower(o.Side)
+		if base == "" || quote == "" || (side != "buy" && side != "sell") {
+			continue
+		}
+		at := o.UpdatedAt
+		if at.IsZero() {
+			at = o.CreatedAt
+		}
+
+		added := false
+		for _, raw := range o.Fills {
+			f, ok := raw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			qty := number(f, "quantity", "qty", "size", "filled_quantity")
+			price := number(f, "price", "rate")
+			if qty <= 0 || price <= 0 {
+				continue
+			}
+			trades = append(trades, Trade{
+				OrderID:       o.ID,
+				BaseCurrency:  base,
+				QuoteCurrency: quote,
+				Side:          side,
+				Quantity:      qty,
+				Price:         price,
+				Fee:           number(f, "fee", "fees"),
+				At:            timestamp(f, at, "timestamp", "executed_at", "created_at"),
+			})
+			added = true
+		}
+		if added {
+			continue
+		}
+
+		qty, _ := strconv.ParseFloat(o.FilledQuantity, 64)
+		price, _ := strconv.ParseFloat(o.Price, 64)
+		if qty > 0 && price > 0 {
+			trades = append(trades, Trade{
+				OrderID:       o.ID,
+				BaseCurrency:  base,
+				QuoteCurrency: quote,
+				Side:          side,
+				Quantity:      qty,
+				Price:         price,
+				At:            at,
+			})
+		}
+	}
+
+	sort.SliceStable(trades, func(i, j int) bool { return trades[i].At.Before(trades[j].At) })
+	return trades
+}
+
+// number reads the first of keys that holds a number or numeric string
+func number(m map[string]interface{}, keys ...string) float64 {
+	for _, k := range keys {
+		switch v := m[k].(type) {
+		case float64:
+			return v
+		case string:
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return f
+			}
+		}
+	}
+	return 0
+}
+
+func timestamp(m map[string]interface{}, fallback time.Time, keys ...string) time.Time {
+	for _, k := range keys {
+		if v, ok := m[k].(string); ok {
+			if t, err := time.Parse(time.RFC3339, v); err == nil {
+				return t
+			}
+		}
+	}
+	return fallback
+}
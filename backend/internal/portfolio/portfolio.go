@@ -0,0 +1,268 @@
+package portfolio
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/models"
+	"github.com/bitnob-api-demo/internal/orders"
+	"github.com/bitnob-api-demo/internal/schedule"
+)
+
+// maxSnapshots bounds the retained snapshot history
+const maxSnapshots = 2000
+
+// QuoteClient is the subset of the Bitnob client used to mark positions
+type QuoteClient interface {
+	CreateTradingQuote(req interface{}) (interface{}, error)
+}
+
+// Position is the holding and P&L of one asset across every currency it was
+// traded against. Amounts are in the portfolio currency. Open positions are
+// marked to an indicative sell quote for the full quantity against the
+// currency last traded, converted at the current rate; MarkError is set when
+// no quote or rate could be obtained.
+type Position struct {
+	Asset         string   `json:"asset"`
+	QuoteCurrency string   `json:"quote_currency"`
+	Quantity      float64  `json:"quantity"`
+	AvgCost       float64  `json:"avg_cost"`
+	CostBasis     float64  `json:"cost_basis"`
+	RealizedPnL   float64  `json:"realized_pnl"`
+	MarkPrice     *float64 `json:"mark_price,omitempty"`
+	MarketValue   *float64 `json:"market_value,omitempty"`
+	UnrealizedPnL *float64 `json:"unrealized_pnl,omitempty"`
+	MarkQuoteID   string   `json:"mark_quote_id,omitempty"`
+	MarkError     string   `json:"mark_error,omitempty"`
+	Trades        int      `json:"trades"`
+	Lots          []Lot    `json:"lots,omitempty"`
+}
+
+// Total sums every position. Unrealized P&L and market value only include
+// positions that could be marked.
+type Total struct {
+	CostBasis     float64 `json:"cost_basis"`
+	MarketValue   float64 `json:"market_value"`
+	RealizedPnL   float64 `json:"realized_pnl"`
+	UnrealizedPnL float64 `json:"unrealized_pnl"`
+	TotalPnL      float64 `json:"total_pnl"`
+}
+
+// Portfolio is a valuation of every pair traded through the gateway
+type Portfolio struct {
+	Method    string     `json:"method"`
+	Currency  string     `json:"currency"`
+	AsOf      time.Time  `json:"as_of"`
+	Positions []Position `json:"positions"`
+	Total     Total      `json:"total"`
+}
+
+// Service values positions from the local orders table in one currency
+type Service struct {
+	client   QuoteClient
+	orders   *orders.Store
+	method   string
+	currency string
+	rates    Rates
+	logger   *log.Logger
+
+	mu        sync.Mutex
+	snapshots []Portfolio
+}
+
+// NewService creates a portfolio service that snapshots using method and
+// values positions in currency, converting trades at rates
+func NewService(client QuoteClient, orderStore *orders.Store, method, currency string, rates Rates) *Service {
+	return &Service{
+		client:   client,
+		orders:   orderStore,
+		method:   method,
+		currency: strings.ToUpper(strings.TrimSpace(currency)),
+		rates:    rates,
+		logger:   log.Default(),
+	}
+}
+
+// WithLogger sends logs of snapshot failures to logger, e.g. one tagged with the tenant
+func (s *Service) WithLogger(logger *log.Logger) *Service {
+	s.logger = logger
+	return s
+}
+
+// Method returns the default cost basis method
+func (s *Service) Method() string {
+	return s.method
+}
+
+// Currency returns the currency positions are valued in
+func (s *Service) Currency() string {
+	return s.currency
+}
+
+// Start takes a snapshot at the given interval until ctx is cancelled
+func (s *Service) Start(ctx context.Context, interval time.Duration) {
+	schedule.Every(ctx, interval, func(time.Time) {
+		if _, err := s.Snapshot(); err != nil {
+			s.logger.Printf("Portfolio snapshot failed: %v", err)
+		}
+	})
+}
+
+// Trades returns every execution in the local orders table
+func (s *Service) Trades() []Trade {
+	return Trades(s.orders.List())
+}
+
+// Disposals returns every sell matched to its acquisition lots, in the
+// portfolio currency
+func (s *Service) Disposals(method string) ([]Disposal, error) {
+	method, err := ParseMethod(method)
+	if err != nil {
+		return nil, err
+	}
+	_, disposals, err := Match(s.Trades(), method, s.rates.Into(s.currency))
+	return disposals, err
+}
+
+// Value computes positions with the given method and marks open positions
+// to fresh indicative quotes
+func (s *Service) Value(method string) (Portfolio, error) {
+	method, err := ParseMethod(method)
+	if err != nil {
+		return Portfolio{}, err
+	}
+
+	holdings, disposals, err := Match(s.Trades(), method, s.rates.Into(s.currency))
+	if err != nil {
+		return Portfolio{}, err
+	}
+	realized := make(map[string]float64)
+	for _, d := range disposals {
+		realized[d.BaseCurrency] += d.Gain
+	}
+
+	p := Portfolio{Method: method, Currency: s.currency, AsOf: time.Now().UTC(), Positions: []Position{}}
+	for _, h := range holdings {
+		pos := Position{
+			Asset:         h.BaseCurrency,
+			QuoteCurrency: h.QuoteCurrency,
+			Quantity:      round(h.Quantity),
+			CostBasis:     round(h.CostBasis),
+			RealizedPnL:   round(realized[h.BaseCurrency]),
+			Trades:        h.Trades,
+			Lots:          h.Lots,
+		}
+		if h.Quantity > dust {
+			pos.AvgCost = round(h.CostBasis / h.Quantity)
+			s.mark(&pos)
+		}
+		for i := range pos.Lots {
+			pos.Lots[i].Quantity = round(pos.Lots[i].Quantity)
+			pos.Lots[i].UnitCost = round(pos.Lots[i].UnitCost)
+			if method == MethodAverage {
+				// Pooled lots all carry the average cost
+				pos.Lots[i].UnitCost = pos.AvgCost
+			}
+		}
+		p.Positions = append(p.Positions, pos)
+
+		p.Total.CostBasis += pos.CostBasis
+		p.Total.RealizedPnL += pos.RealizedPnL
+		if pos.MarketValue != nil {
+			p.Total.MarketValue += *pos.MarketValue
+			p.Total.UnrealizedPnL += *pos.UnrealizedPnL
+		}
+	}
+
+	p.Total.CostBasis = round(p.Total.CostBasis)
+	p.Total.MarketValue = round(p.Total.MarketValue)
+	p.Total.RealizedPnL = round(p.Total.RealizedPnL)
+	p.Total.UnrealizedPnL = round(p.Total.UnrealizedPnL)
+	p.Total.TotalPnL = round(p.Total.RealizedPnL + p.Total.UnrealizedPnL)
+	return p, nil
+}
+
+// mark prices an open position at what selling it would fetch now, in the
+// portfolio currency
+func (s *Service) mark(pos *Position) {
+	rate, err := s.rates.Into(s.currency)(pos.QuoteCurrency, time.Now())
+	if err != nil {
+		pos.MarkError = err.Error()
+		return
+	}
+
+	raw, err := s.client.CreateTradingQuote(models.CreateQuoteRequest{
+		BaseCurrency:  pos.Asset,
+		QuoteCurrency: pos.QuoteCurrency,
+		Side:          "sell",
+		Quantity:      strconv.FormatFloat(pos.Quantity, 'f', -1, 64),
+	})
+	if err != nil {
+		pos.MarkError = err.Error()
+		return
+	}
+
+	var q models.CreateQuoteResponse
+	if err := models.Decode(raw, &q); err != nil {
+		pos.MarkError = err.Error()
+		return
+	}
+	price, err := strconv.ParseFloat(q.Price, 64)
+	if err != nil || price <= 0 {
+		pos.MarkError = fmt.Sprintf("invalid quoted price %q", q.Price)
+		return
+	}
+	price = round(price * rate)
+
+	value := round(pos.Quantity * price)
+	unrealized := round(value - pos.CostBasis)
+	pos.MarkPrice = &price
+	pos.MarketValue = &value
+	pos.UnrealizedPnL = &unrealized
+	pos.MarkQuoteID = q.ID
+}
+
+// Snapshot values the portfolio with the default method and keeps the
+// result, without open lots, in the time series
+func (s *Service) Snapshot() (Portfolio, error) {
+	p, err := s.Value(s.method)
+	if err != nil {
+		return Portfolio{}, err
+	}
+	for i := range p.Positions {
+		p.Positions[i].Lots = nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots = append(s.snapshots, p)
+	if len(s.snapshots) > maxSnapshots {
+		s.snapshots = s.snapshots[len(s.snapshots)-maxSnapshots:]
+	}
+	return p, nil
+}
+
+// Snapshots returns snapshots taken in [from, to); zero bounds are open
+func (s *Service) Snapshots(from, to time.Time) []Portfolio {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []Portfolio{}
+	for _, p := range s.snapshots {
+		if (!from.IsZero() && p.AsOf.Before(from)) || (!to.IsZero() && !p.AsOf.Before(to)) {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// round trims float noise from reported amounts
+func round(v float64) float64 {
+	return math.Round(v*1e8) / 1e8
+}
@@ -0,0 +1,332 @@
+package portfolio
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Holding periods; disposals held longer than a year are long-term
+const (
+	TermShort = "short"
+	TermLong  = "long"
+)
+
+var ErrNoRate = errors.New("no conversion rate to the reporting currency")
+
+// Rates converts amounts into a reporting currency. Keys are "FROM/TO" and
+// each pair holds its rates in date order.
+type Rates map[string][]Rate
+
+// Rate is a conversion rate effective from Since until the pair's next rate.
+// An undated rate has a zero Since and applies at any date before the first
+// dated one, which suits pegged currencies.
+type Rate struct {
+	Since time.Time
+	Value float64
+}
+
+// ParseRates reads "FROM/TO:rate" or "FROM/TO@YYYY-MM-DD:rate" entries
+// separated by commas, e.g. "USDT/USD:1,USD/NGN@2024-01-01:900,
+// USD/NGN@2024-07-01:1500"
+func ParseRates(spec string) (Rates, error) {
+	rates := Rates{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		pair, value, ok := strings.Cut(part, ":")
+		pair, date, dated := strings.Cut(pair, "@")
+		from, to, okPair := strings.Cut(pair, "/")
+		if !ok || !okPair {
+			return nil, fmt.Errorf("invalid rate %q, expected FROM/TO:rate or FROM/TO@YYYY-MM-DD:rate", part)
+		}
+		rate := Rate{}
+		if dated {
+			since, err := time.Parse("2006-01-02", strings.TrimSpace(date))
+			if err != nil {
+				return nil, fmt.Errorf("invalid rate date in %q", part)
+			}
+			rate.Since = since
+		}
+		var err error
+		rate.Value, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || rate.Value <= 0 {
+			return nil, fmt.Errorf("invalid rate %q", part)
+		}
+
+		key := rateKey(from, to)
+		for _, existing := range rates[key] {
+			if existing.Since.Equal(rate.Since) {
+				return nil, fmt.Errorf("duplicate rate %q", part)
+			}
+		}
+		rates[key] = append(rates[key], rate)
+	}
+	for _, list := range rates {
+		sort.Slice(list, func(i, j int) bool { return list[i].Since.Before(list[j].Since) })
+	}
+	return rates, nil
+}
+
+// Convert returns the multiplier from one currency to another at a date.
+// Inverses of configured rates are used, and currencies without a direct
+// rate are crossed through one common currency.
+func (r Rates) Convert(from, to string, at time.Time) (float64, error) {
+	from, to = strings.ToUpper(strings.TrimSpace(from)), strings.ToUpper(strings.TrimSpace(to))
+	if rate, ok := r.direct(from, to, at); ok {
+		return rate, nil
+	}
+	for key := range r {
+		a, b, _ := strings.Cut(key, "/")
+		for _, pivot := range []string{a, b} {
+			first, ok1 := r.direct(from, pivot, at)
+			second, ok2 := r.direct(pivot, to, at)
+			if ok1 && ok2 {
+				return first * second, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("%w: %s to %s on %s", ErrNoRate, from, to, at.UTC().Format("2006-01-02"))
+}
+
+// Into converts into currency at the rates in effect when each amount arose
+func (r Rates) Into(currency string) RateFunc {
+	return func(from string, at time.Time) (float64, error) {
+		return r.Convert(from, currency, at)
+	}
+}
+
+func (r Rates) direct(from, to string, at time.Time) (float64, bool) {
+	if from == to {
+		return 1, true
+	}
+	if rate, ok := r.at(from+"/"+to, at); ok {
+		return rate, true
+	}
+	if rate, ok := r.at(to+"/"+from, at); ok {
+		return 1 / rate, true
+	}
+	return 0, false
+}
+
+// at returns the pair's rate in effect at a date
+func (r Rates) at(key string, at time.Time) (float64, bool) {
+	list := r[key]
+	for i := len(list) - 1; i >= 0; i-- {
+		if !list[i].Since.After(at) {
+			return list[i].Value, true
+		}
+	}
+	return 0, false
+}
+
+func rateKey(from, to string) string {
+	return strings.ToUpper(strings.TrimSpace(from)) + "/" + strings.ToUpper(strings.TrimSpace(to))
+}
+
+// GainLine is one disposal matched against one acquisition lot, the unit
+// reported on Form 8949. Rate converted the proceeds from the trade currency;
+// the cost basis was converted when the lot was acquired. Quantity sold
+// beyond tracked holdings has no acquisition date and a zero cost basis.
+type GainLine struct {
+	OrderID       string     `json:"order_id"`
+	LotOrderID    string     `json:"lot_order_id,omitempty"`
+	Asset         string     `json:"asset"`
+	Quantity      float64    `json:"quantity"`
+	AcquiredAt    *time.Time `json:"acquired_at,omitempty"`
+	DisposedAt    time.Time  `json:"disposed_at"`
+	Proceeds      float64    `json:"proceeds"`
+	CostBasis     float64    `json:"cost_basis"`
+	Gain          float64    `json:"gain"`
+	Term          string     `json:"term"`
+	TradeCurrency string     `json:"trade_currency"`
+	Rate          float64    `json:"rate"`
+}
+
+// GainSummary totals the lines of one holding period
+type GainSummary struct {
+	Proceeds  float64 `json:"proceeds"`
+	CostBasis float64 `json:"cost_basis"`
+	Gain      float64 `json:"gain"`
+	Lines     int     `json:"lines"`
+}
+
+// TaxReport lists the capital gains realized in one tax year
+type TaxReport struct {
+	Year      int         `json:"year"`
+	Method    string      `json:"method"`
+	Currency  string      `json:"currency"`
+	Lines     []GainLine  `json:"lines"`
+	ShortTerm GainSummary `json:"short_term"`
+	LongTerm  GainSummary `json:"long_term"`
+	Total     GainSummary `json:"total"`
+}
+
+// TaxReport matches every disposal with the given method, so lots consumed in
+// earlier years are never reused, and reports those in year converted into
+// currency. Cost basis is converted at the rate on each acquisition date and
+// proceeds at the rate on the disposal date.
+func (s *Service) TaxReport(year int, method, currency string) (TaxReport, error) {
+	method, err := ParseMethod(method)
+	if err != nil {
+		return TaxReport{}, err
+	}
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(1, 0, 0)
+
+	_, disposals, err := Match(s.Trades(), method, s.rates.Into(currency))
+	if err != nil {
+		return TaxReport{}, err
+	}
+	report := TaxReport{Year: year, Method: method, Currency: currency, Lines: []GainLine{}}
+	for _, d := range disposals {
+		if d.DisposedAt.Before(from) || !d.DisposedAt.Before(to) {
+			continue
+		}
+		for _, line := range gainLines(d) {
+			summary := &report.ShortTerm
+			if line.Term == TermLong {
+				summary = &report.LongTerm
+			}
+			summary.add(line)
+			report.Total.add(line)
+			report.Lines = append(report.Lines, line)
+		}
+	}
+	return report, nil
+}
+
+// gainLines splits a disposal per matched lot, allocating proceeds by quantity
+func gainLines(d Disposal) []GainLine {
+	line := func(qty, basis float64) GainLine {
+		proceeds := round(d.Proceeds * qty / d.Quantity)
+		basis = round(basis)
+		return GainLine{
+			OrderID:       d.OrderID,
+			Asset:         d.BaseCurrency,
+			Quantity:      round(qty),
+			DisposedAt:    d.DisposedAt,
+			Proceeds:      proceeds,
+			CostBasis:     basis,
+			Gain:          round(proceeds - basis),
+			Term:          TermShort,
+			TradeCurrency: d.QuoteCurrency,
+			Rate:          d.Rate,
+		}
+	}
+
+	lines := make([]GainLine, 0, len(d.Lots)+1)
+	for _, m := range d.Lots {
+		l := line(m.Quantity, m.CostBasis)
+		acquired := m.AcquiredAt
+		l.LotOrderID = m.OrderID
+		l.AcquiredAt = &acquired
+		if d.DisposedAt.After(acquired.AddDate(1, 0, 0)) {
+			l.Term = TermLong
+		}
+		lines = append(lines, l)
+	}
+	if d.Unmatched > 0 {
+		lines = append(lines, line(d.Unmatched, 0))
+	}
+	return lines
+}
+
+func (g *GainSummary) add(l GainLine) {
+	g.Proceeds = round(g.Proceeds + l.Proceeds)
+	g.CostBasis = round(g.CostBasis + l.CostBasis)
+	g.Gain = round(g.Gain + l.Gain)
+	g.Lines++
+}
+
+// WriteCSV writes one row per matched lot
+func (r TaxReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"orderId", "lotOrderId", "asset", "quantity", "dateAcquired", "dateSold",
+		"proceeds", "costBasis", "gain", "term", "currency", "tradeCurrency", "rate"})
+	for _, l := range r.Lines {
+		acquired := ""
+		if l.AcquiredAt != nil {
+			acquired = l.AcquiredAt.Format(time.RFC3339)
+		}
+		cw.Write([]string{
+			l.OrderID,
+			l.LotOrderID,
+			l.Asset,
+			formatAmount(l.Quantity),
+			acquired,
+			l.DisposedAt.Format(time.RFC3339),
+			formatMoney(l.Proceeds),
+			formatMoney(l.CostBasis),
+			formatMoney(l.Gain),
+			l.Term,
+			r.Currency,
+			l.TradeCurrency,
+			formatAmount(l.Rate),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteForm8949 writes the lines in the Form 8949 column layout, short-term
+// transactions in Part I and long-term in Part II, each followed by totals
+func (r TaxReport) WriteForm8949(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := []string{"(a) Description of property", "(b) Date acquired", "(c) Date sold or disposed of",
+		"(d) Proceeds (sales price)", "(e) Cost or other basis", "(f) Code(s)", "(g) Amount of adjustment",
+		"(h) Gain or (loss)"}
+
+	parts := []struct {
+		title   string
+		term    string
+		summary GainSummary
+	}{
+		{"Part I - Short-Term. Transactions involving capital assets you held 1 year or less", TermShort, r.ShortTerm},
+		{"Part II - Long-Term. Transactions involving capital assets you held more than 1 year", TermLong, r.LongTerm},
+	}
+	cw.Write([]string{fmt.Sprintf("Form 8949 - Tax year %d - Amounts in %s - Cost basis method %s", r.Year, r.Currency, strings.ToUpper(r.Method))})
+	for _, part := range parts {
+		cw.Write([]string{})
+		cw.Write([]string{part.title})
+		cw.Write(header)
+		for _, l := range r.Lines {
+			if l.Term != part.term {
+				continue
+			}
+			acquired := "UNKNOWN"
+			if l.AcquiredAt != nil {
+				acquired = l.AcquiredAt.Format("01/02/2006")
+			}
+			cw.Write([]string{
+				formatAmount(l.Quantity) + " " + l.Asset,
+				acquired,
+				l.DisposedAt.Format("01/02/2006"),
+				formatMoney(l.Proceeds),
+				formatMoney(l.CostBasis),
+				"",
+				"",
+				formatMoney(l.Gain),
+			})
+		}
+		cw.Write([]string{"Totals", "", "", formatMoney(part.summary.Proceeds), formatMoney(part.summary.CostBasis), "", "", formatMoney(part.summary.Gain)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func formatMoney(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
@@ -0,0 +1,308 @@
+package quotes
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/models"
+	"github.com/bitnob-api-demo/internal/store"
+)
+
+// expiryMargin treats quotes this close to expiry as expired, leaving time
+// for the order or payout to reach Bitnob
+const expiryMargin = 2 * time.Second
+
+// Quote kinds
+const (
+	KindTrading = "trading"
+	KindPayout  = "payout"
+)
+
+// Quote states
+const (
+	StatusActive  = "active"
+	StatusUsed    = "used"
+	StatusExpired = "expired"
+)
+
+var (
+	ErrUnknownQuote = errors.New("quote was not issued by this gateway")
+	ErrExpired      = errors.New("quote has expired")
+	ErrUsed         = errors.New("quote has already been used")
+)
+
+// TradingClient is the subset of the Bitnob client used to requote
+type TradingClient interface {
+	CreateTradingQuote(req interface{}) (interface{}, error)
+}
+
+// Quote is a trading or payout quote issued through the gateway together
+// with the request that produced it
+type Quote struct {
+	ID        string      `json:"id"`
+	Kind      string      `json:"kind"`
+	AltID     string      `json:"alt_id,omitempty"`
+	Request   interface{} `json:"request"`
+	Price     string      `json:"price,omitempty"`
+	SpreadBps float64     `json:"spread_bps,omitempty"`
+	Rate      float64     `json:"exchange_rate,omitempty"`
+	ExpiresAt time.Time   `json:"expires_at"`
+	CreatedAt time.Time   `json:"created_at"`
+	UsedAt    *time.Time  `json:"used_at,omitempty"`
+	UsedBy    string      `json:"used_by,omitempty"`
+
+	// Filled in by View
+	Status           string  `json:"status"`
+	RemainingSeconds float64 `json:"remaining_seconds"`
+}
+
+// Expired reports whether the quote is too close to expiry to be used at now
+func (q Quote) Expired(now time.Time) bool {
+	return !q.ExpiresAt.IsZero() && !now.Before(q.ExpiresAt.Add(-expiryMargin))
+}
+
+// View fills in the quote's status and remaining validity at now
+func (q Quote) View(now time.Time) Quote {
+	switch {
+	case q.UsedAt != nil:
+		q.Status = StatusUsed
+	case q.Expired(now):
+		q.Status = StatusExpired
+	default:
+		q.Status = StatusActive
+	}
+	if !q.ExpiresAt.IsZero() {
+		q.RemainingSeconds = math.Max(0, math.Floor(q.ExpiresAt.Sub(now).Seconds()))
+	}
+	return q
+}
+
+// Requote describes a replacement quote taken for an expired one
+type Requote struct {
+	OriginalQuoteID string  `json:"original_quote_id"`
+	OriginalPrice   float64 `json:"original_price"`
+	NewQuoteID      string  `json:"new_quote_id"`
+	NewPrice        float64 `json:"new_price"`
+	NewExpiresAt    string  `json:"new_expires_at,omitempty"`
+	SlippageBps     float64 `json:"slippage_bps"`
+	MaxSlippageBps  float64 `json:"max_slippage_bps"`
+}
+
+// PriceMovedError rejects a requote whose price moved against the caller by
+// more than the allowed slippage
+type PriceMovedError struct {
+	Requote
+}
+
+func (e *PriceMovedError) Error() string {
+	return fmt.Sprintf("price moved from %s to %s (%.1f bps, max %.1f bps)",
+		formatPrice(e.OriginalPrice), formatPrice(e.NewPrice), e.SlippageBps, e.MaxSlippageBps)
+}
+
+// Book records quotes issued through the gateway and tracks their use
+type Book struct {
+	quotes *store.Table[Quote]
+}
+
+// NewBook creates an empty quote book
+func NewBook() *Book {
+	return &Book{
+		quotes: store.NewTable[Quote](),
+	}
+}
+
+// RecordTrading stores a trading quote response from Bitnob
+func (b *Book) RecordTrading(req models.CreateQuoteRequest, raw interface{}) (Quote, error) {
+	var resp models.CreateQuoteResponse
+	if err := models.Decode(raw, &resp); err != nil {
+		return Quote{}, err
+	}
+	if resp.ID == "" {
+		return Quote{}, errors.New("quote response has no id")
+	}
+
+	q := Quote{
+		ID:        resp.ID,
+		Kind:      KindTrading,
+		Request:   req,
+		Price:     resp.Price,
+		SpreadBps: resp.SpreadBps,
+		ExpiresAt: resp.ExpiresAt,
+		CreatedAt: time.Now().UTC(),
+	}
+	b.quotes.Put(q.ID, q)
+	return q, nil
+}
+
+// RecordPayout stores a payout quote response from Bitnob. It is keyed by
+// quoteId, which initialize and finalize refer to.
+func (b *Book) RecordPayout(req models.PayoutQuoteRequest, raw interface{}) (Quote, error) {
+	var resp models.PayoutQuoteResponse
+	if err := models.Decode(raw, &resp); err != nil {
+		return Quote{}, err
+	}
+
+	q := Quote{
+		ID:        resp.QuoteID,
+		Kind:      KindPayout,
+		Request:   req,
+		Rate:      resp.ExchangeRate,
+		CreatedAt: time.Now().UTC(),
+	}
+	if q.ID == "" {
+		q.ID = resp.ID
+	} else if resp.ID != q.ID {
+		q.AltID = resp.ID
+	}
+	if q.ID == "" {
+		return Quote{}, errors.New("quote response has no id")
+	}
+	if resp.ExpiryTimeStamp > 0 {
+		q.ExpiresAt = unixTime(resp.ExpiryTimeStamp)
+	}
+	b.quotes.Put(q.ID, q)
+	return q, nil
+}
+
+// Get returns a recorded quote by its ID or alternate ID
+func (b *Book) Get(id string) (Quote, bool) {
+	if q, ok := b.quotes.Get(id); ok {
+		return q, true
+	}
+	return b.quotes.Find(func(q Quote) bool { return q.AltID != "" && q.AltID == id })
+}
+
+// Check rejects a known quote that has expired or been used. Quotes the
+// gateway did not issue are passed through for Bitnob to decide.
+func (b *Book) Check(id string, now time.Time) error {
+	q, ok := b.quotes.Get(id)
+	if !ok {
+		return nil
+	}
+	return usable(q, now)
+}
+
+// Claim marks a known quote as used before it is sent to Bitnob, so that
+// concurrent requests cannot use it twice. Release undoes the claim if the
+// upstream call fails, and Complete records what the quote was used for.
+func (b *Book) Claim(id string, now time.Time) error {
+	_, err := b.quotes.Update(id, func(q *Quote) error {
+		if err := usable(*q, now); err != nil {
+			return err
+		}
+		at := now.UTC()
+		q.UsedAt = &at
+		return nil
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Complete records the order or payout a claimed quote was used for
+func (b *Book) Complete(id, usedBy string) {
+	b.quotes.Update(id, func(q *Quote) error {
+		q.UsedBy = usedBy
+		return nil
+	})
+}
+
+// Release returns a claimed quote to active
+func (b *Book) Release(id string) {
+	b.quotes.Update(id, func(q *Quote) error {
+		q.UsedAt = nil
+		q.UsedBy = ""
+		return nil
+	})
+}
+
+// Refresh replaces an expired quote on order with a fresh one for the same
+// pair, side and quantity. It returns nil when the original quote is still
+// valid, and a *PriceMovedError when the new price is worse than the
+// caller's price by more than maxSlippageBps. Favourable moves are accepted.
+func (b *Book) Refresh(client TradingClient, order *models.CreateOrderRequest, maxSlippageBps float64, now time.Time) (*Requote, error) {
+	original, ok := b.quotes.Get(order.QuoteID)
+	if !ok || original.Kind != KindTrading {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownQuote, order.QuoteID)
+	}
+	if original.UsedAt != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUsed, order.QuoteID)
+	}
+	if !original.Expired(now) {
+		return nil, nil
+	}
+
+	oldPrice, err := strconv.ParseFloat(order.Price, 64)
+	if err != nil || oldPrice <= 0 {
+		return nil, fmt.Errorf("invalid order price %q", order.Price)
+	}
+
+	req := models.CreateQuoteRequest{
+		BaseCurrency:  order.BaseCurrency,
+		QuoteCurrency: order.QuoteCurrency,
+		Side:          order.Side,
+		Quantity:      order.Quantity,
+	}
+	raw, err := client.CreateTradingQuote(req)
+	if err != nil {
+		return nil, fmt.Errorf("requote: %w", err)
+	}
+	fresh, err := b.RecordTrading(req, raw)
+	if err != nil {
+		return nil, fmt.Errorf("requote: %w", err)
+	}
+	newPrice, err := strconv.ParseFloat(fresh.Price, 64)
+	if err != nil || newPrice <= 0 {
+		return nil, fmt.Errorf("requote: invalid quoted price %q", fresh.Price)
+	}
+
+	move := (newPrice - oldPrice) / oldPrice * 10000
+	if strings.EqualFold(order.Side, "sell") {
+		move = -move
+	}
+	rq := &Requote{
+		OriginalQuoteID: order.QuoteID,
+		OriginalPrice:   oldPrice,
+		NewQuoteID:      fresh.ID,
+		NewPrice:        newPrice,
+		SlippageBps:     move,
+		MaxSlippageBps:  maxSlippageBps,
+	}
+	if !fresh.ExpiresAt.IsZero() {
+		rq.NewExpiresAt = fresh.ExpiresAt.Format(time.RFC3339)
+	}
+	if move > maxSlippageBps {
+		return nil, &PriceMovedError{*rq}
+	}
+
+	order.QuoteID = fresh.ID
+	order.Price = fresh.Price
+	return rq, nil
+}
+
+func usable(q Quote, now time.Time) error {
+	switch {
+	case q.UsedAt != nil:
+		return fmt.Errorf("%w: %s was used at %s", ErrUsed, q.ID, q.UsedAt.Format(time.RFC3339))
+	case q.Expired(now):
+		return fmt.Errorf("%w: %s expired at %s", ErrExpired, q.ID, q.ExpiresAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// unixTime accepts expiry timestamps in seconds or milliseconds
+func unixTime(ts int64) time.Time {
+	if ts > 1e12 {
+		return time.UnixMilli(ts).UTC()
+	}
+	return time.Unix(ts, 0).UTC()
+}
+
+func formatPrice(p float64) string {
+	return strconv.FormatFloat(p, 'f', -1, 64)
+}
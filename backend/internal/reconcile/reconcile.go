@@ -0,0 +1,517 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/activity"
+	"github.com/bitnob-api-demo/internal/audit"
+	"github.com/bitnob-api-demo/internal/models"
+	"github.com/bitnob-api-demo/internal/orders"
+	"github.com/bitnob-api-demo/internal/schedule"
+	"github.com/bitnob-api-demo/internal/store"
+)
+
+// auditResource is the resource name used for discrepancy audit entries
+const auditResource = "reconciliation_discrepancy"
+
+// maxRuns bounds the retained run history
+const maxRuns = 200
+
+// Record kinds compared
+const (
+	KindOrder    = "order"
+	KindTransfer = activity.KindTransfer
+	KindPayout   = activity.KindPayout
+)
+
+// Discrepancy types
+const (
+	MissingUpstream = "missing_upstream"
+	MissingLocal    = "missing_local"
+	StatusMismatch  = "status_mismatch"
+	AmountMismatch  = "amount_mismatch"
+)
+
+// Discrepancy states. Open discrepancies that a later run no longer finds
+// are cleared; resolved ones that reappear are reopened.
+const (
+	StateOpen         = "open"
+	StateAcknowledged = "acknowledged"
+	StateResolved     = "resolved"
+	StateCleared      = "cleared"
+)
+
+var ErrInvalidState = errors.New("discrepancy cannot change to the requested state")
+
+// TradingClient is the subset of the Bitnob client used to read orders
+type TradingClient interface {
+	GetOrders() (interface{}, error)
+	GetOrderByID(id string) (interface{}, error)
+}
+
+// Discrepancy is a difference between a local record and Bitnob's view of it
+type Discrepancy struct {
+	ID          string     `json:"id"`
+	Kind        string     `json:"kind"`
+	Reference   string     `json:"reference"`
+	Type        string     `json:"type"`
+	Field       string     `json:"field,omitempty"`
+	Local       string     `json:"local,omitempty"`
+	Upstream    string     `json:"upstream,omitempty"`
+	State       string     `json:"state"`
+	FirstSeenAt time.Time  `json:"first_seen_at"`
+	LastSeenAt  time.Time  `json:"last_seen_at"`
+	LastRunID   string     `json:"last_run_id"`
+	Note        string     `json:"note,omitempty"`
+	HandledBy   string     `json:"handled_by,omitempty"`
+	HandledAt   *time.Time `json:"handled_at,omitempty"`
+}
+
+// Run summarizes one reconciliation pass
+type Run struct {
+	ID         string         `json:"id"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt time.Time      `json:"finished_at"`
+	Checked    map[string]int `json:"checked"`
+	Found      int            `json:"found"`
+	Errors     []string       `json:"errors,omitempty"`
+}
+
+// Observation is Bitnob's latest reported state of a transfer or payout,
+// taken from webhooks since the API has no lookup for them
+type Observation struct {
+	Kind      string    `json:"kind"`
+	Reference string    `json:"reference"`
+	Status    string    `json:"status"`
+	Amount    float64   `json:"amount,omitempty"`
+	Currency  string    `json:"currency,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+// Resolution is a manual action on a discrepancy. AcceptUpstream overwrites
+// the local record with Bitnob's state where the gateway can do so.
+type Resolution struct {
+	Note           string `json:"note"`
+	AcceptUpstream bool   `json:"accept_upstream"`
+}
+
+// Service compares local orders, transfers and payouts with Bitnob
+type Service struct {
+	client   TradingClient
+	orders   *orders.Store
+	activity *activity.Log
+	audit    *audit.Log
+	logger   *log.Logger
+
+	mu            sync.Mutex
+	observations  map[string]Observation
+	discrepancies *store.Table[Discrepancy]
+	runs          []Run
+}
+
+// NewService creates a reconciliation service with no history
+func NewService(client TradingClient, orderStore *orders.Store, activityLog *activity.Log, auditLog *audit.Log) *Service {
+	return &Service{
+		client:        client,
+		orders:        orderStore,
+		activity:      activityLog,
+		audit:         auditLog,
+		observations:  make(map[string]Observation),
+		discrepancies: store.NewTable[Discrepancy](),
+		logger:        log.Default(),
+	}
+}
+
+// WithLogger sends logs of runs that find discrepancies to logger, e.g. one tagged with the tenant
+func (s *Service) WithLogger(logger *log.Logger) *Service {
+	s.logger = logger
+	return s
+}
+
+// Start reconciles at the given interval until ctx is cancelled
+func (s *Service) Start(ctx context.Context, interval time.Duration) {
+	schedule.Every(ctx, interval, func(time.Time) { s.Run() })
+}
+
+// Observe records Bitnob's reported state of a transfer or payout
+func (s *Service) Observe(obs Observation) {
+	if obs.Reference == "" || (obs.Kind != KindTransfer && obs.Kind != KindPayout) {
+		return
+	}
+	if obs.At.IsZero() {
+		obs.At = time.Now().UTC()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observations[obs.Kind+":"+obs.Reference] = obs
+}
+
+// finding is a discrepancy detected by the current run
+type finding struct {
+	kind, ref, typ, field, local, upstream string
+}
+
+// Run performs one reconciliation pass and returns its summary
+func (s *Service) Run() Run {
+	run := Run{ID: store.NewID("recon"), StartedAt: time.Now().UTC(), Checked: map[string]int{}}
+
+	var findings []finding
+	orderFindings, err := s.checkOrders(&run)
+	if err != nil {
+		run.Errors = append(run.Errors, err.Error())
+	}
+	findings = append(findings, orderFindings...)
+	findings = append(findings, s.checkActivity(&run)...)
+
+	run.FinishedAt = time.Now().UTC()
+	run.Found = len(findings)
+	s.apply(run, findings, err == nil)
+
+	s.mu.Lock()
+	s.runs = append(s.runs, run)
+	if len(s.runs) > maxRuns {
+		s.runs = s.runs[len(s.runs)-maxRuns:]
+	}
+	s.mu.Unlock()
+
+	if run.Found > 0 {
+		s.logger.Printf("Reconciliation %s found %d discrepancies", run.ID, run.Found)
+	}
+	return run
+}
+
+// checkOrders compares the local orders table with Bitnob's order list,
+// looking up orders missing from the list individually
+func (s *Service) checkOrders(run *Run) ([]finding, error) {
+	raw, err := s.client.GetOrders()
+	if err != nil {
+		return nil, fmt.Errorf("list orders: %w", err)
+	}
+	var list []models.OrderResponse
+	if err := models.Decode(raw, &list); err != nil {
+		return nil, fmt.Errorf("list orders: %w", err)
+	}
+	upstream := make(map[string]models.OrderResponse, len(list))
+	for _, o := range list {
+		if o.ID != "" {
+			upstream[o.ID] = o
+		}
+	}
+
+	var findings []finding
+	local := s.orders.List()
+	seen := make(map[string]bool, len(local))
+	for _, o := range local {
+		run.Checked[KindOrder]++
+		seen[o.ID] = true
+
+		up, ok := upstream[o.ID]
+		if !ok {
+			raw, err := s.client.GetOrderByID(o.ID)
+			if err == nil {
+				err = models.Decode(raw, &up)
+			}
+			if err != nil || up.ID == "" {
+				findings = append(findings, finding{kind: KindOrder, ref: o.ID, typ: MissingUpstream, local: o.Status})
+				continue
+			}
+		}
+
+		if !strings.EqualFold(o.Status, up.Status) {
+			findings = append(findings, finding{KindOrder, o.ID, StatusMismatch, "status", o.Status, up.Status})
+		}
+		for _, f := range [][3]string{
+			{"quantity", o.Quantity, up.Quantity},
+			{"price", o.Price, up.Price},
+			{"filled_quantity", o.FilledQuantity, up.FilledQuantity},
+		} {
+			if !sameAmount(parseFloat(f[1]), parseFloat(f[2])) {
+				findings = append(findings, finding{KindOrder, o.ID, AmountMismatch, f[0], f[1], f[2]})
+			}
+		}
+	}
+
+	for id, up := range upstream {
+		if !seen[id] {
+			run.Checked[KindOrder]++
+			findings = append(findings, finding{kind: KindOrder, ref: id, typ: MissingLocal, upstream: up.Status})
+		}
+	}
+	return findings, nil
+}
+
+// checkActivity compares recorded transfers and payouts with the latest
+// webhook observation of each. Records Bitnob has not reported on yet are
+// not compared.
+func (s *Service) checkActivity(run *Run) []finding {
+	s.mu.Lock()
+	observed := make(map[string]Observation, len(s.observations))
+	for k, v := range s.observations {
+		observed[k] = v
+	}
+	s.mu.Unlock()
+
+	var findings []finding
+	matched := make(map[string]bool)
+	for _, tx := range s.activity.List(time.Time{}, time.Time{}) {
+		if tx.Kind != KindTransfer && tx.Kind != KindPayout {
+			continue
+		}
+		run.Checked[tx.Kind]++
+
+		obs, key, ok := lookup(observed, tx)
+		if !ok {
+			continue
+		}
+		matched[key] = true
+		ref := firstNonEmpty(tx.Reference, tx.QuoteID)
+
+		localOutcome, upstreamOutcome := outcome(tx.Status), outcome(obs.Status)
+		switch {
+		case localOutcome == upstreamOutcome:
+		case localOutcome == outcomePending && upstreamOutcome == outcomeSucceeded:
+			// Progress, not divergence: record the settled state locally
+			s.activity.Update(tx.ID, func(t *activity.Transaction) { t.Status = obs.Status })
+		default:
+			findings = append(findings, finding{tx.Kind, ref, StatusMismatch, "status", tx.Status, obs.Status})
+		}
+
+		if obs.Amount > 0 && !sameAmount(tx.Amount, obs.Amount) {
+			findings = append(findings, finding{tx.Kind, ref, AmountMismatch, "amount",
+				formatAmount(tx.Amount), formatAmount(obs.Amount)})
+		}
+		if obs.Currency != "" && tx.Currency != "" && !strings.EqualFold(obs.Currency, tx.Currency) {
+			findings = append(findings, finding{tx.Kind, ref, AmountMismatch, "currency", tx.Currency, obs.Currency})
+		}
+	}
+
+	for key, obs := range observed {
+		if !matched[key] {
+			findings = append(findings, finding{kind: obs.Kind, ref: obs.Reference, typ: MissingLocal, upstream: obs.Status})
+		}
+	}
+	return findings
+}
+
+// lookup finds the observation of a transaction by upstream reference or,
+// for payouts, by quote ID
+func lookup(observed map[string]Observation, tx activity.Transaction) (Observation, string, bool) {
+	for _, ref := range []string{tx.Reference, tx.QuoteID} {
+		if ref == "" {
+			continue
+		}
+		key := tx.Kind + ":" + ref
+		if obs, ok := observed[key]; ok {
+			return obs, key, true
+		}
+	}
+	return Observation{}, "", false
+}
+
+// apply merges a run's findings into the discrepancy table. When the order
+// list could not be fetched, open order discrepancies are left as they are.
+func (s *Service) apply(run Run, findings []finding, ordersChecked bool) {
+	current := make(map[string]bool, len(findings))
+	for _, f := range findings {
+		id := discrepancyID(f)
+		current[id] = true
+
+		if _, err := s.discrepancies.Update(id, func(d *Discrepancy) error {
+			d.Local, d.Upstream = f.local, f.upstream
+			d.LastSeenAt, d.LastRunID = run.FinishedAt, run.ID
+			if d.State == StateResolved || d.State == StateCleared {
+				d.State = StateOpen
+			}
+			return nil
+		}); err == nil {
+			continue
+		}
+		s.discrepancies.Put(id, Discrepancy{
+			ID:          id,
+			Kind:        f.kind,
+			Reference:   f.ref,
+			Type:        f.typ,
+			Field:       f.field,
+			Local:       f.local,
+			Upstream:    f.upstream,
+			State:       StateOpen,
+			FirstSeenAt: run.FinishedAt,
+			LastSeenAt:  run.FinishedAt,
+			LastRunID:   run.ID,
+		})
+	}
+
+	for _, d := range s.discrepancies.List(func(d Discrepancy) bool { return d.State == StateOpen && !current[d.ID] }) {
+		if d.Kind == KindOrder && !ordersChecked {
+			continue
+		}
+		s.discrepancies.Update(d.ID, func(d *Discrepancy) error {
+			d.State = StateCleared
+			return nil
+		})
+	}
+}
+
+func discrepancyID(f finding) string {
+	id := f.kind + ":" + f.ref + ":" + f.typ
+	if f.field != "" {
+		id += ":" + f.field
+	}
+	return id
+}
+
+// List returns discrepancies, newest first, optionally filtered by state
+// and kind
+func (s *Service) List(state, kind string) []Discrepancy {
+	rows := s.discrepancies.List(func(d Discrepancy) bool {
+		return (state == "" || d.State == state) && (kind == "" || d.Kind == kind)
+	})
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].LastSeenAt.After(rows[j].LastSeenAt) })
+	return rows
+}
+
+// Get returns one discrepancy
+func (s *Service) Get(id string) (Discrepancy, bool) {
+	return s.discrepancies.Get(id)
+}
+
+// Runs returns the run history, newest first
+func (s *Service) Runs() []Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Run, len(s.runs))
+	for i, r := range s.runs {
+		out[len(s.runs)-1-i] = r
+	}
+	return out
+}
+
+// LastRun returns the most recent run, if any
+func (s *Service) LastRun() *Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.runs) == 0 {
+		return nil
+	}
+	r := s.runs[len(s.runs)-1]
+	return &r
+}
+
+// Acknowledge marks an open discrepancy as known so it stops needing
+// attention; it stays acknowledged while it persists
+func (s *Service) Acknowledge(id, note, actor string) (Discrepancy, error) {
+	return s.transition(id, "acknowledge", StateAcknowledged, note, actor, func(d Discrepancy) error {
+		if d.State != StateOpen {
+			return ErrInvalidState
+		}
+		return nil
+	})
+}
+
+// Resolve closes an open or acknowledged discrepancy. With AcceptUpstream,
+// order discrepancies are refreshed from Bitnob and transfer or payout
+// statuses are overwritten with the observed upstream status.
+func (s *Service) Resolve(id string, res Resolution, actor string) (Discrepancy, error) {
+	return s.transition(id, "resolve", StateResolved, res.Note, actor, func(d Discrepancy) error {
+		if d.State != StateOpen && d.State != StateAcknowledged {
+			return ErrInvalidState
+		}
+		if res.AcceptUpstream {
+			return s.acceptUpstream(d)
+		}
+		return nil
+	})
+}
+
+func (s *Service) transition(id, action, state, note, actor string, check func(Discrepancy) error) (Discrepancy, error) {
+	before, ok := s.discrepancies.Get(id)
+	if !ok {
+		return Discrepancy{}, store.ErrNotFound
+	}
+	if err := check(before); err != nil {
+		return Discrepancy{}, err
+	}
+
+	now := time.Now().UTC()
+	after, err := s.discrepancies.Update(id, func(d *Discrepancy) error {
+		d.State = state
+		d.Note = note
+		d.HandledBy = actor
+		d.HandledAt = &now
+		return nil
+	})
+	if err != nil {
+		return Discrepancy{}, err
+	}
+	s.audit.Record(actor, action, auditResource, id, before, after)
+	return after, nil
+}
+
+func (s *Service) acceptUpstream(d Discrepancy) error {
+	switch d.Kind {
+	case KindOrder:
+		if d.Type == MissingUpstream {
+			return fmt.Errorf("order %s does not exist upstream", d.Reference)
+		}
+		_, err := s.orders.Refresh(d.Reference)
+		return err
+	default:
+		if d.Type != StatusMismatch {
+			return fmt.Errorf("only status discrepancies can be accepted for %s records", d.Kind)
+		}
+		tx, ok := s.activity.Find(d.Kind, d.Reference)
+		if !ok {
+			return store.ErrNotFound
+		}
+		_, err := s.activity.Update(tx.ID, func(t *activity.Transaction) { t.Status = d.Upstream })
+		return err
+	}
+}
+
+// Coarse outcomes used to compare statuses named differently on each side
+const (
+	outcomePending   = "pending"
+	outcomeSucceeded = "succeeded"
+	outcomeFailed    = "failed"
+)
+
+func outcome(status string) string {
+	switch strings.ToLower(status) {
+	case "success", "successful", "succeeded", "completed", "complete", "settled", "paid", "confirmed", "filled":
+		return outcomeSucceeded
+	case "failed", "failure", "cancelled", "canceled", "rejected", "expired", "reversed", "refunded":
+		return outcomeFailed
+	}
+	return outcomePending
+}
+
+func sameAmount(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-8*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+func parseFloat(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+func formatAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package requirements
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/cache"
+	"github.com/bitnob-api-demo/internal/models"
+)
+
+// limitsKey is the single cache key used for transaction limits
+const limitsKey = "limits"
+
+// Origin is the subset of the Bitnob client the cache reads through to
+type Origin interface {
+	GetCountryRequirements(country string) (interface{}, error)
+	GetTransactionLimits() (interface{}, error)
+}
+
+// Cache serves country requirements and transaction limits from memory,
+// refreshing them in the background
+type Cache struct {
+	ttl          time.Duration
+	requirements *cache.TTL[interface{}]
+	schemas      *cache.TTL[*Schema]
+	limits       *cache.TTL[interface{}]
+}
+
+// NewCache wraps origin with TTL caches for requirements, parsed schemas and limits
+func NewCache(origin Origin, ttl time.Duration) *Cache {
+	c := &Cache{
+		ttl: ttl,
+		requirements: cache.NewTTL(ttl, func(country string) (interface{}, error) {
+			return origin.GetCountryRequirements(country)
+		}),
+		limits: cache.NewTTL(ttl, func(string) (interface{}, error) {
+			return origin.GetTransactionLimits()
+		}),
+	}
+	c.schemas = cache.NewTTL(ttl, func(country string) (*Schema, error) {
+		return Fetch(c, country)
+	})
+	return c
+}
+
+// WithLogger sends the caches' logs to logger, e.g. one tagged with the tenant
+func (c *Cache) WithLogger(logger *log.Logger) *Cache {
+	c.requirements.WithLogger(logger)
+	c.schemas.WithLogger(logger)
+	c.limits.WithLogger(logger)
+	return c
+}
+
+// Start launches the background refreshers, reloading entries at half their TTL
+func (c *Cache) Start(ctx context.Context, interval time.Duration) {
+	c.requirements.StartRefresher(ctx, interval, c.ttl/2)
+	c.schemas.StartRefresher(ctx, interval, c.ttl/2)
+	c.limits.StartRefresher(ctx, interval, c.ttl/2)
+}
+
+// GetCountryRequirements returns the raw requirements for a country
+func (c *Cache) GetCountryRequirements(country string) (interface{}, error) {
+	return c.requirements.Get(strings.ToUpper(country))
+}
+
+// GetTransactionLimits returns the raw transaction limits
+func (c *Cache) GetTransactionLimits() (interface{}, error) {
+	return c.limits.Get(limitsKey)
+}
+
+// Schema returns the parsed destination schema for a country
+func (c *Cache) Schema(country string) (*Schema, error) {
+	return c.schemas.Get(strings.ToUpper(country))
+}
+
+// Limits returns the transaction limits decoded into typed records. Bitnob
+// may return a single object or a list; both are accepted.
+func (c *Cache) Limits() ([]models.TransactionLimits, error) {
+	raw, err := c.GetTransactionLimits()
+	if err != nil {
+		return nil, err
+	}
+
+	var list []models.TransactionLimits
+	if err := models.Decode(raw, &list); err == nil {
+		return list, nil
+	}
+
+	var single models.TransactionLimits
+	if err := models.Decode(raw, &single); err != nil {
+		return nil, err
+	}
+	return []models.TransactionLimits{single}, nil
+}
@@ -0,0 +1,189 @@
+package requirements
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bitnob-api-demo/internal/models"
+)
+
+// usdPeggedAssets are source assets whose amount is treated as USD when
+// converting through the limits rate
+var usdPeggedAssets = map[string]bool{
+	"usd":  true,
+	"usdt": true,
+	"usdc": true,
+}
+
+// ErrUnconverted is returned by CheckQuote when the amount can only be
+// compared with the limits once a quote gives its settlement value
+var ErrUnconverted = errors.New("amount cannot be converted to the settlement currency before quoting")
+
+// LimitError reports a payout amount outside the allowed range
+type LimitError struct {
+	Country           string  `json:"country,omitempty"`
+	Currency          string  `json:"currency"`
+	Requested         float64 `json:"requested"`
+	RequestedCurrency string  `json:"requestedCurrency"`
+	Converted         float64 `json:"converted,omitempty"`
+	Min               float64 `json:"min"`
+	Max               float64 `json:"max"`
+	UsdMin            float64 `json:"usdMin,omitempty"`
+	UsdMax            float64 `json:"usdMax,omitempty"`
+	Rate              float64 `json:"rate,omitempty"`
+}
+
+func (e *LimitError) Error() string {
+	requested := fmt.Sprintf("%.2f %s", e.Requested, strings.ToUpper(e.RequestedCurrency))
+	if e.Converted > 0 {
+		requested += fmt.Sprintf(" (%.2f %s)", e.Converted, strings.ToUpper(e.Currency))
+	}
+	return fmt.Sprintf("amount %s is outside the allowed range %.2f-%.2f %s",
+		requested, e.Min, e.Max, strings.ToUpper(e.Currency))
+}
+
+// FindLimits returns the limits for a settlement currency, preferring an exact country match
+func FindLimits(limits []models.TransactionLimits, country, currency string) (models.TransactionLimits, bool) {
+	var fallback *models.TransactionLimits
+	for i := range limits {
+		l := limits[i]
+		if !strings.EqualFold(l.Currency, currency) {
+			continue
+		}
+		if country == "" || strings.EqualFold(l.Country, country) {
+			return l, true
+		}
+		if l.Country == "" && fallback == nil {
+			fallback = &limits[i]
+		}
+	}
+	if fallback != nil {
+		return *fallback, true
+	}
+	return models.TransactionLimits{}, false
+}
+
+// CheckQuote validates the requested payout amount against the limits for
+// its destination. SettlementAmount is compared directly in the local
+// currency; Amount is converted with the published rate when the source
+// asset is USD-pegged. Other amounts return ErrUnconverted and are checked
+// with CheckQuoted once quoted.
+func CheckQuote(limits []models.TransactionLimits, req models.PayoutQuoteRequest) error {
+	l, ok := FindLimits(limits, req.Country, req.ToCurrency)
+	if !ok {
+		return nil
+	}
+	r, ok := newRange(l)
+	if !ok {
+		return nil
+	}
+
+	if req.SettlementAmount > 0 {
+		return r.check(req.SettlementAmount, req.ToCurrency, 0)
+	}
+
+	if req.Amount > 0 && usdPeggedAssets[strings.ToLower(req.FromAsset)] {
+		if r.rate > 0 {
+			return r.check(req.Amount, req.FromAsset, req.Amount*r.rate)
+		}
+		if r.usdHigher > 0 {
+			if req.Amount < r.usdLower || req.Amount > r.usdHigher {
+				return r.err(req.Amount, req.FromAsset, 0)
+			}
+			return nil
+		}
+	}
+
+	if req.Amount > 0 {
+		return ErrUnconverted
+	}
+	return nil
+}
+
+// CheckQuoted validates the settlement value of a quote against the limits,
+// converting Amount with the quoted rate when Bitnob omits the settlement
+// amount. A quote with neither leaves nothing to check, so it passes when
+// CheckQuote already validated the amount and fails with ErrUnconverted
+// otherwise.
+func CheckQuoted(limits []models.TransactionLimits, req models.PayoutQuoteRequest, quote models.PayoutQuoteResponse) error {
+	l, ok := FindLimits(limits, req.Country, req.ToCurrency)
+	if !ok {
+		return nil
+	}
+	r, ok := newRange(l)
+	if !ok {
+		return nil
+	}
+
+	settlement := quote.SettlementAmount
+	if settlement == 0 {
+		settlement = req.Amount * quote.ExchangeRate
+	}
+	if settlement <= 0 {
+		err := CheckQuote(limits, req)
+		if errors.Is(err, ErrUnconverted) {
+			return fmt.Errorf("%w: the quote has no settlement amount or rate", ErrUnconverted)
+		}
+		return err
+	}
+	if req.SettlementAmount > 0 {
+		return r.check(settlement, req.ToCurrency, 0)
+	}
+	return r.check(req.Amount, req.FromAsset, settlement)
+}
+
+// limitRange is the allowed local-currency range of one limits record
+type limitRange struct {
+	limits              models.TransactionLimits
+	lower, higher, rate float64
+	usdLower, usdHigher float64
+}
+
+func newRange(l models.TransactionLimits) (limitRange, bool) {
+	r := limitRange{limits: l}
+	var lowerOK, higherOK bool
+	r.lower, lowerOK = parseAmount(l.LowerLimit)
+	r.higher, higherOK = parseAmount(l.HigherLimit)
+	r.rate, _ = parseAmount(l.Rate)
+	r.usdLower, _ = parseAmount(l.UsdLowerLimit)
+	r.usdHigher, _ = parseAmount(l.UsdHigherLimit)
+	return r, lowerOK && higherOK
+}
+
+// check compares local, or requested when local is zero, with the range
+func (r limitRange) check(requested float64, requestedCurrency string, local float64) error {
+	value := local
+	if value == 0 {
+		value = requested
+	}
+	if value < r.lower || value > r.higher {
+		return r.err(requested, requestedCurrency, local)
+	}
+	return nil
+}
+
+func (r limitRange) err(requested float64, requestedCurrency string, converted float64) *LimitError {
+	return &LimitError{
+		Country:           r.limits.Country,
+		Currency:          r.limits.Currency,
+		Requested:         requested,
+		RequestedCurrency: requestedCurrency,
+		Converted:         converted,
+		Min:               r.lower,
+		Max:               r.higher,
+		UsdMin:            r.usdLower,
+		UsdMax:            r.usdHigher,
+		Rate:              r.rate,
+	}
+}
+
+func parseAmount(s string) (float64, bool) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ",", "")
+	if s == "" {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil
+}
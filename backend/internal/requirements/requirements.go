@@ -0,0 +1,38 @@
+package requirements
+
+import (
+	"fmt"
+
+	"github.com/bitnob-api-demo/internal/models"
+)
+
+// Source fetches raw country requirements from Bitnob
+type Source interface {
+	GetCountryRequirements(country string) (interface{}, error)
+}
+
+// SchemaProvider resolves the parsed destination schema for a country
+type SchemaProvider interface {
+	Schema(country string) (*Schema, error)
+}
+
+// Fetch retrieves and parses the destination schema for a country
+func Fetch(src Source, country string) (*Schema, error) {
+	raw, err := src.GetCountryRequirements(country)
+	if err != nil {
+		return nil, err
+	}
+
+	var req models.CountryRequirement
+	if err := models.Decode(raw, &req); err != nil {
+		return nil, err
+	}
+	if req.Code == "" {
+		req.Code = country
+	}
+	if len(req.Destination) == 0 {
+		return nil, fmt.Errorf("no destination requirements published for %s", country)
+	}
+
+	return Parse(req), nil
+}
@@ -0,0 +1,324 @@
+package requirements
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bitnob-api-demo/internal/models"
+)
+
+// Field describes one beneficiary field a destination type needs. Unmapped
+// fields have no counterpart in BeneficiaryDetails and are left to Bitnob to
+// validate.
+type Field struct {
+	Name     string `json:"name"`
+	Required bool   `json:"required"`
+	Pattern  string `json:"pattern,omitempty"`
+	Unmapped bool   `json:"unmapped,omitempty"`
+
+	regex *regexp.Regexp
+}
+
+// Option is a selectable value such as a bank or mobile-money network
+type Option struct {
+	Code string `json:"code"`
+	Name string `json:"name,omitempty"`
+}
+
+// DestinationType is a payout rail supported by a country, such as bank or mobile money
+type DestinationType struct {
+	Name     string   `json:"name"`
+	Fields   []Field  `json:"fields"`
+	Banks    []Option `json:"banks,omitempty"`
+	Networks []Option `json:"networks,omitempty"`
+}
+
+// Schema is the typed form of CountryRequirement.Destination
+type Schema struct {
+	Country string                      `json:"country"`
+	Types   map[string]*DestinationType `json:"types"`
+}
+
+// FieldError describes a single invalid beneficiary field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every problem found with a set of beneficiary details
+type ValidationError struct {
+	Country string       `json:"country"`
+	Type    string       `json:"type"`
+	Fields  []FieldError `json:"fields"`
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		parts = append(parts, f.Field+": "+f.Message)
+	}
+	return fmt.Sprintf("beneficiary invalid for %s %s: %s", e.Country, e.Type, strings.Join(parts, "; "))
+}
+
+// Parse builds a Schema from a country requirement. Each object-valued key in
+// Destination is treated as a destination type.
+func Parse(req models.CountryRequirement) *Schema {
+	schema := &Schema{
+		Country: strings.ToUpper(req.Code),
+		Types:   make(map[string]*DestinationType),
+	}
+
+	for name, raw := range req.Destination {
+		spec, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		schema.Types[normalizeType(name)] = parseType(name, spec)
+	}
+
+	return schema
+}
+
+func parseType(name string, spec map[string]interface{}) *DestinationType {
+	dt := &DestinationType{Name: normalizeType(name)}
+	seen := make(map[string]int)
+
+	addField := func(f Field) {
+		if f.Name == "" {
+			return
+		}
+		if i, ok := seen[f.Name]; ok {
+			dt.Fields[i].Required = dt.Fields[i].Required || f.Required
+			return
+		}
+		seen[f.Name] = len(dt.Fields)
+		dt.Fields = append(dt.Fields, f)
+	}
+
+	for _, key := range []string{"required", "requiredFields"} {
+		for _, item := range asSlice(spec[key]) {
+			if s, ok := item.(string); ok {
+				addField(Field{Name: s, Required: true})
+			}
+		}
+	}
+
+	for _, item := range asSlice(spec["fields"]) {
+		switch v := item.(type) {
+		case string:
+			addField(Field{Name: v, Required: true})
+		case map[string]interface{}:
+			required := true
+			if r, ok := v["required"].(bool); ok {
+				required = r
+			}
+			addField(Field{
+				Name:     firstString(v, "name", "key", "field"),
+				Required: required,
+				Pattern:  firstString(v, "regex", "pattern", "validation"),
+			})
+		}
+	}
+
+	// Patterns may also be published as a map or as "<field>Regex" keys
+	patterns := make(map[string]string)
+	for _, key := range []string{"patterns", "regex", "regexes"} {
+		if m, ok := spec[key].(map[string]interface{}); ok {
+			for field, p := range m {
+				if ps, ok := p.(string); ok {
+					patterns[field] = ps
+				}
+			}
+		}
+	}
+	for key, v := range spec {
+		ps, ok := v.(string)
+		if !ok {
+			continue
+		}
+		for _, suffix := range []string{"Regex", "Pattern"} {
+			if field := strings.TrimSuffix(key, suffix); field != key && field != "" {
+				patterns[field] = ps
+			}
+		}
+	}
+	for i := range dt.Fields {
+		if p, ok := patterns[dt.Fields[i].Name]; ok && dt.Fields[i].Pattern == "" {
+			dt.Fields[i].Pattern = p
+		}
+	}
+	for i := range dt.Fields {
+		if _, ok := fieldAliases[fieldKey(dt.Fields[i].Name)]; !ok {
+			dt.Fields[i].Unmapped = true
+		}
+		if dt.Fields[i].Pattern == "" {
+			continue
+		}
+		if re, err := regexp.Compile(dt.Fields[i].Pattern); err == nil {
+			dt.Fields[i].regex = re
+		}
+	}
+
+	dt.Banks = parseOptions(spec, "banks", "bankList", "institutions")
+	dt.Networks = parseOptions(spec, "networks", "mobileNetworks", "mobileMoneyNetworks", "providers")
+
+	sort.SliceStable(dt.Fields, func(i, j int) bool { return dt.Fields[i].Name < dt.Fields[j].Name })
+	return dt
+}
+
+// Validate checks beneficiary details against the schema for their type
+func (s *Schema) Validate(details models.BeneficiaryDetails) error {
+	typeName := normalizeType(details.Type)
+	verr := &ValidationError{Country: s.Country, Type: typeName}
+
+	dt, ok := s.Types[typeName]
+	if !ok {
+		supported := make([]string, 0, len(s.Types))
+		for name := range s.Types {
+			supported = append(supported, name)
+		}
+		sort.Strings(supported)
+		verr.Fields = append(verr.Fields, FieldError{
+			Field:   "type",
+			Message: fmt.Sprintf("unsupported destination type, expected one of [%s]", strings.Join(supported, ", ")),
+		})
+		return verr
+	}
+
+	values := fieldValues(details)
+	for _, f := range dt.Fields {
+		if f.Unmapped {
+			continue
+		}
+		value := strings.TrimSpace(values[fieldAliases[fieldKey(f.Name)]])
+		if value == "" {
+			if f.Required {
+				verr.Fields = append(verr.Fields, FieldError{Field: f.Name, Message: "is required"})
+			}
+			continue
+		}
+		if f.regex != nil && !f.regex.MatchString(value) {
+			verr.Fields = append(verr.Fields, FieldError{Field: f.Name, Message: "does not match " + f.Pattern})
+		}
+	}
+
+	if len(dt.Banks) > 0 && details.BankCode != "" && !hasOption(dt.Banks, details.BankCode) {
+		verr.Fields = append(verr.Fields, FieldError{Field: "bankCode", Message: "is not a supported bank"})
+	}
+	if len(dt.Networks) > 0 && details.Network != "" && !hasOption(dt.Networks, details.Network) {
+		verr.Fields = append(verr.Fields, FieldError{Field: "network", Message: "is not a supported mobile-money network"})
+	}
+
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// Type returns the destination type with the given name
+func (s *Schema) Type(name string) (*DestinationType, bool) {
+	dt, ok := s.Types[normalizeType(name)]
+	return dt, ok
+}
+
+func parseOptions(spec map[string]interface{}, keys ...string) []Option {
+	var out []Option
+	for _, key := range keys {
+		for _, item := range asSlice(spec[key]) {
+			switch v := item.(type) {
+			case string:
+				out = append(out, Option{Code: v, Name: v})
+			case map[string]interface{}:
+				opt := Option{
+					Code: firstString(v, "code", "bankCode", "id", "slug"),
+					Name: firstString(v, "name", "bankName", "label"),
+				}
+				if opt.Code == "" {
+					opt.Code = opt.Name
+				}
+				if opt.Code != "" {
+					out = append(out, opt)
+				}
+			}
+		}
+	}
+	return out
+}
+
+func hasOption(options []Option, value string) bool {
+	for _, o := range options {
+		if strings.EqualFold(o.Code, value) || strings.EqualFold(o.Name, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldAliases maps the spellings Bitnob uses for a field, normalized by
+// fieldKey, onto the BeneficiaryDetails field that holds it
+var fieldAliases = map[string]string{
+	"type":                "type",
+	"destinationtype":     "type",
+	"bankcode":            "bankCode",
+	"bank":                "bankCode",
+	"bankname":            "bankCode",
+	"accountname":         "accountName",
+	"beneficiaryname":     "accountName",
+	"name":                "accountName",
+	"accountnumber":       "accountNumber",
+	"accountno":           "accountNumber",
+	"iban":                "accountNumber",
+	"network":             "network",
+	"mobilenetwork":       "network",
+	"provider":            "network",
+	"mobilemoneyprovider": "network",
+	"phonenumber":         "phoneNumber",
+	"phone":               "phoneNumber",
+	"mobilenumber":        "phoneNumber",
+	"msisdn":              "phoneNumber",
+}
+
+// fieldKey normalizes a field name such as "account_number" or
+// "Account Number" for alias lookup
+func fieldKey(name string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "", " ", "").Replace(name))
+}
+
+func fieldValues(d models.BeneficiaryDetails) map[string]string {
+	return map[string]string{
+		"type":          d.Type,
+		"bankCode":      d.BankCode,
+		"accountName":   d.AccountName,
+		"accountNumber": d.AccountNumber,
+		"network":       d.Network,
+		"phoneNumber":   d.PhoneNumber,
+	}
+}
+
+// normalizeType maps variants such as "mobileMoney" and "MOBILE_MONEY" onto one name
+func normalizeType(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.NewReplacer("-", "_", " ", "_").Replace(name)
+	if name == "mobilemoney" || name == "momo" {
+		return "mobile_money"
+	}
+	return name
+}
+
+func asSlice(v interface{}) []interface{} {
+	if s, ok := v.([]interface{}); ok {
+		return s
+	}
+	return nil
+}
+
+func firstString(m map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		if s, ok := m[k].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
@@ -0,0 +1,136 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Calendar frequencies
+const (
+	Daily    = "daily"
+	Weekly   = "weekly"
+	Biweekly = "biweekly"
+	Monthly  = "monthly"
+)
+
+// Calendar is a human-oriented schedule such as "monthly on the 25th at 09:00"
+// or "weekly on Monday and Thursday at 14:30"
+type Calendar struct {
+	Frequency string `json:"frequency"`
+	// Weekdays lists days for weekly schedules, 0 = Sunday
+	Weekdays []int `json:"weekdays,omitempty"`
+	// DayOfMonth for monthly schedules; -1 means the last day of the month
+	DayOfMonth int `json:"dayOfMonth,omitempty"`
+	// Time of day as HH:MM
+	Time string `json:"time"`
+	// StartDate anchors biweekly schedules, as YYYY-MM-DD
+	StartDate string `json:"startDate,omitempty"`
+}
+
+type calendarSchedule struct {
+	cal          Calendar
+	hour, minute int
+	anchor       time.Time
+}
+
+func newCalendarSchedule(cal Calendar, loc *time.Location) (*calendarSchedule, error) {
+	cs := &calendarSchedule{cal: cal}
+	cal.Frequency = strings.ToLower(cal.Frequency)
+	cs.cal.Frequency = cal.Frequency
+
+	if cal.Time == "" {
+		cal.Time = "00:00"
+	}
+	if _, err := fmt.Sscanf(cal.Time, "%d:%d", &cs.hour, &cs.minute); err != nil ||
+		cs.hour < 0 || cs.hour > 23 || cs.minute < 0 || cs.minute > 59 {
+		return nil, fmt.Errorf("invalid time %q, expected HH:MM", cal.Time)
+	}
+
+	switch cal.Frequency {
+	case Daily:
+	case Weekly, Biweekly:
+		if len(cal.Weekdays) == 0 {
+			return nil, fmt.Errorf("%s schedule requires weekdays", cal.Frequency)
+		}
+		for _, d := range cal.Weekdays {
+			if d < 0 || d > 6 {
+				return nil, fmt.Errorf("invalid weekday %d", d)
+			}
+		}
+		if cal.Frequency == Biweekly {
+			if cal.StartDate == "" {
+				return nil, fmt.Errorf("biweekly schedule requires startDate")
+			}
+			anchor, err := time.ParseInLocation("2006-01-02", cal.StartDate, loc)
+			if err != nil {
+				return nil, fmt.Errorf("invalid startDate: %w", err)
+			}
+			cs.anchor = anchor
+		}
+	case Monthly:
+		if cal.DayOfMonth == 0 || cal.DayOfMonth < -1 || cal.DayOfMonth > 31 {
+			return nil, fmt.Errorf("monthly schedule requires dayOfMonth 1-31 or -1")
+		}
+	default:
+		return nil, fmt.Errorf("unknown frequency %q", cal.Frequency)
+	}
+
+	return cs, nil
+}
+
+// Next returns the first occurrence strictly after t, in t's location
+func (cs *calendarSchedule) Next(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for i := 0; i < 800; i++ {
+		candidate := time.Date(day.Year(), day.Month(), day.Day(), cs.hour, cs.minute, 0, 0, day.Location())
+		if candidate.After(t) && cs.matches(day) {
+			return candidate
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return time.Time{}
+}
+
+func (cs *calendarSchedule) matches(day time.Time) bool {
+	switch cs.cal.Frequency {
+	case Daily:
+		return true
+	case Weekly:
+		return containsWeekday(cs.cal.Weekdays, day.Weekday())
+	case Biweekly:
+		if !containsWeekday(cs.cal.Weekdays, day.Weekday()) || day.Before(cs.anchor) {
+			return false
+		}
+		return daysBetween(startOfWeek(cs.anchor), day)/7%2 == 0
+	case Monthly:
+		last := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, day.Location()).Day()
+		target := cs.cal.DayOfMonth
+		if target == -1 || target > last {
+			target = last
+		}
+		return day.Day() == target
+	}
+	return false
+}
+
+func containsWeekday(days []int, wd time.Weekday) bool {
+	for _, d := range days {
+		if d == int(wd) {
+			return true
+		}
+	}
+	return false
+}
+
+func startOfWeek(t time.Time) time.Time {
+	return t.AddDate(0, 0, -int(t.Weekday()))
+}
+
+// daysBetween counts calendar days from a to b, so a DST change in between
+// does not shorten or lengthen a day
+func daysBetween(a, b time.Time) int {
+	from := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	to := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from).Hours() / 24)
+}
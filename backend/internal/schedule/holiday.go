@@ -0,0 +1,76 @@
+package schedule
+
+import (
+	"fmt"
+	"time"
+)
+
+// Holiday rules decide what happens when a run falls on a non-business day
+const (
+	HolidayRun      = "run"
+	HolidaySkip     = "skip"
+	HolidayNext     = "next_business_day"
+	HolidayPrevious = "previous_business_day"
+)
+
+// HolidayPolicy describes non-business days and how runs on them are handled
+type HolidayPolicy struct {
+	Rule         string   `json:"rule,omitempty"`
+	SkipWeekends bool     `json:"skipWeekends,omitempty"`
+	Dates        []string `json:"dates,omitempty"`
+}
+
+// Validate checks the rule name and date formats
+func (p HolidayPolicy) Validate() error {
+	switch p.Rule {
+	case "", HolidayRun, HolidaySkip, HolidayNext, HolidayPrevious:
+	default:
+		return fmt.Errorf("unknown holiday rule %q", p.Rule)
+	}
+	for _, d := range p.Dates {
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			return fmt.Errorf("invalid holiday date %q", d)
+		}
+	}
+	return nil
+}
+
+// IsBusinessDay reports whether t is neither a listed holiday nor, when
+// configured, a weekend
+func (p HolidayPolicy) IsBusinessDay(t time.Time) bool {
+	if p.SkipWeekends && (t.Weekday() == time.Saturday || t.Weekday() == time.Sunday) {
+		return false
+	}
+	date := t.Format("2006-01-02")
+	for _, d := range p.Dates {
+		if d == date {
+			return false
+		}
+	}
+	return true
+}
+
+// Adjust applies the policy to a scheduled time. It returns the time the run
+// should actually happen, or skip=true if the occurrence should be dropped.
+func (p HolidayPolicy) Adjust(t time.Time) (adjusted time.Time, skip bool) {
+	if p.Rule == "" || p.Rule == HolidayRun || p.IsBusinessDay(t) {
+		return t, false
+	}
+
+	switch p.Rule {
+	case HolidaySkip:
+		return t, true
+	case HolidayNext, HolidayPrevious:
+		step := 1
+		if p.Rule == HolidayPrevious {
+			step = -1
+		}
+		for i := 0; i < 31; i++ {
+			t = t.AddDate(0, 0, step)
+			if p.IsBusinessDay(t) {
+				return t, false
+			}
+		}
+	}
+	return t, true
+}
@@ -0,0 +1,93 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	// Embed the timezone database so schedules work on hosts without one
+	_ "time/tzdata"
+)
+
+// Schedule produces successive run times
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// Spec is the user-facing definition of when something runs: either a cron
+// expression or a calendar rule, evaluated in Timezone
+type Spec struct {
+	Cron     string    `json:"cron,omitempty"`
+	Calendar *Calendar `json:"calendar,omitempty"`
+	Timezone string    `json:"timezone,omitempty"`
+}
+
+type located struct {
+	inner Schedule
+	loc   *time.Location
+}
+
+func (l located) Next(after time.Time) time.Time {
+	return l.inner.Next(after.In(l.loc))
+}
+
+// Compile validates a spec and returns its schedule
+func (s Spec) Compile() (Schedule, error) {
+	loc := time.UTC
+	if s.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(s.Timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone %q", s.Timezone)
+		}
+	}
+
+	switch {
+	case s.Cron != "" && s.Calendar != nil:
+		return nil, errors.New("specify either cron or calendar, not both")
+	case s.Cron != "":
+		c, err := ParseCron(s.Cron)
+		if err != nil {
+			return nil, err
+		}
+		return located{inner: c, loc: loc}, nil
+	case s.Calendar != nil:
+		cs, err := newCalendarSchedule(*s.Calendar, loc)
+		if err != nil {
+			return nil, err
+		}
+		return located{inner: cs, loc: loc}, nil
+	default:
+		return nil, errors.New("schedule requires cron or calendar")
+	}
+}
+
+// Upcoming returns the next n run times after t
+func Upcoming(s Schedule, t time.Time, n int) []time.Time {
+	out := make([]time.Time, 0, n)
+	for i := 0; i < n; i++ {
+		t = s.Next(t)
+		if t.IsZero() {
+			break
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// Every calls fn with the current time at each interval until ctx is cancelled
+func Every(ctx context.Context, interval time.Duration, fn func(now time.Time)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				fn(now)
+			}
+		}
+	}()
+}
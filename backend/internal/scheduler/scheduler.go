@@ -0,0 +1,489 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/audit"
+	"github.com/bitnob-api-demo/internal/beneficiary"
+	"github.com/bitnob-api-demo/internal/models"
+	"github.com/bitnob-api-demo/internal/requirements"
+	"github.com/bitnob-api-demo/internal/schedule"
+	"github.com/bitnob-api-demo/internal/store"
+)
+
+// auditResource is the resource name used for schedule audit entries
+const auditResource = "payout_schedule"
+
+// Schedule states
+const (
+	StatusActive    = "active"
+	StatusPaused    = "paused"
+	StatusCompleted = "completed"
+)
+
+// Run outcomes
+const (
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+	RunSkipped   = "skipped"
+	RunBlocked   = "blocked"
+)
+
+var ErrInvalidState = errors.New("schedule cannot change to the requested state")
+
+// PayoutClient is the subset of the Bitnob client used to execute payouts
+type PayoutClient interface {
+	CreatePayoutQuote(req interface{}) (interface{}, error)
+	InitializePayout(req interface{}) (interface{}, error)
+	FinalizePayout(req interface{}) (interface{}, error)
+}
+
+// Template is the payout repeated on every run. The amount is given in
+// either the source asset (Amount) or the settlement currency (SettlementAmount).
+type Template struct {
+	BeneficiaryID    string  `json:"beneficiaryId"`
+	CustomerID       string  `json:"customerId"`
+	Country          string  `json:"country"`
+	Source           string  `json:"source"`
+	FromAsset        string  `json:"fromAsset"`
+	ToCurrency       string  `json:"toCurrency"`
+	Chain            string  `json:"chain,omitempty"`
+	Amount           float64 `json:"amount,omitempty"`
+	SettlementAmount float64 `json:"settlementAmount,omitempty"`
+	PaymentReason    string  `json:"paymentReason"`
+	ReferencePrefix  string  `json:"referencePrefix,omitempty"`
+}
+
+// Guardrails stop a run before money moves
+type Guardrails struct {
+	// MaxRateDeviationBps blocks a run whose quoted rate differs from the
+	// previous successful run by more than this many basis points
+	MaxRateDeviationBps float64 `json:"maxRateDeviationBps,omitempty"`
+}
+
+// Definition is the user-editable part of a schedule
+type Definition struct {
+	Name       string                 `json:"name" binding:"required"`
+	Timing     schedule.Spec          `json:"timing"`
+	Holidays   schedule.HolidayPolicy `json:"holidays"`
+	Template   Template               `json:"template"`
+	Guardrails Guardrails             `json:"guardrails"`
+	MaxRuns    int                    `json:"maxRuns,omitempty"`
+	EndAt      *time.Time             `json:"endAt,omitempty"`
+}
+
+// PayoutSchedule is a recurring payout and its progress
+type PayoutSchedule struct {
+	Definition
+	ID            string      `json:"id"`
+	Status        string      `json:"status"`
+	CreatedBy     string      `json:"createdBy"`
+	CreatedAt     time.Time   `json:"createdAt"`
+	UpdatedAt     time.Time   `json:"updatedAt"`
+	NextRunAt     *time.Time  `json:"nextRunAt,omitempty"`
+	NextNominalAt *time.Time  `json:"nextNominalAt,omitempty"`
+	NextRunSkip   bool        `json:"nextRunSkip,omitempty"`
+	LastRunAt     *time.Time  `json:"lastRunAt,omitempty"`
+	LastRate      float64     `json:"lastRate,omitempty"`
+	RunCount      int         `json:"runCount"`
+	Upcoming      []time.Time `json:"upcoming,omitempty"`
+
+	compiled schedule.Schedule
+}
+
+// Run records one execution attempt of a schedule
+type Run struct {
+	ID           string    `json:"id"`
+	ScheduleID   string    `json:"scheduleId"`
+	ScheduledFor time.Time `json:"scheduledFor"`
+	StartedAt    time.Time `json:"startedAt"`
+	FinishedAt   time.Time `json:"finishedAt"`
+	Status       string    `json:"status"`
+	Reason       string    `json:"reason,omitempty"`
+	Reference    string    `json:"reference,omitempty"`
+	QuoteID      string    `json:"quoteId,omitempty"`
+	ExchangeRate float64   `json:"exchangeRate,omitempty"`
+	PayoutID     string    `json:"payoutId,omitempty"`
+	Fees         float64   `json:"fees,omitempty"`
+}
+
+// Service stores payout schedules and runs them when due
+type Service struct {
+	client        PayoutClient
+	beneficiaries *beneficiary.Store
+	requirements  *requirements.Cache
+	audit         *audit.Log
+	logger        *log.Logger
+
+	mu        sync.Mutex
+	schedules *store.Table[*PayoutSchedule]
+	runs      *store.Table[Run]
+}
+
+// NewService creates an empty payout scheduler
+func NewService(client PayoutClient, beneficiaries *beneficiary.Store, reqCache *requirements.Cache, auditLog *audit.Log) *Service {
+	return &Service{
+		client:        client,
+		beneficiaries: beneficiaries,
+		requirements:  reqCache,
+		audit:         auditLog,
+		schedules:     store.NewTable[*PayoutSchedule](),
+		runs:          store.NewTable[Run](),
+		logger:        log.Default(),
+	}
+}
+
+// WithLogger sends logs of schedule runs to logger, e.g. one tagged with the tenant
+func (s *Service) WithLogger(logger *log.Logger) *Service {
+	s.logger = logger
+	return s
+}
+
+// Start checks for due schedules at the given interval until ctx is cancelled
+func (s *Service) Start(ctx context.Context, interval time.Duration) {
+	schedule.Every(ctx, interval, s.runDue)
+}
+
+// Create validates a definition and activates the schedule
+func (s *Service) Create(def Definition, actor string) (PayoutSchedule, error) {
+	compiled, err := s.validate(def)
+	if err != nil {
+		return PayoutSchedule{}, err
+	}
+
+	now := time.Now().UTC()
+	ps := &PayoutSchedule{
+		Definition: def,
+		ID:         store.NewID("psch"),
+		Status:     StatusActive,
+		CreatedBy:  actor,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		compiled:   compiled,
+	}
+	s.advance(ps, now)
+
+	s.schedules.Put(ps.ID, ps)
+	s.audit.Record(actor, "create", auditResource, ps.ID, nil, ps.Definition)
+	return s.snapshot(ps), nil
+}
+
+// Get returns a schedule with its next few run times
+func (s *Service) Get(id string) (PayoutSchedule, bool) {
+	ps, ok := s.schedules.Get(id)
+	if !ok {
+		return PayoutSchedule{}, false
+	}
+	s.mu.Lock()
+	out, compiled := *ps, ps.compiled
+	s.mu.Unlock()
+	if out.Status == StatusActive {
+		out.Upcoming = schedule.Upcoming(compiled, time.Now(), 5)
+	}
+	return out, true
+}
+
+// List returns all schedules
+func (s *Service) List() []PayoutSchedule {
+	rows := s.schedules.List(nil)
+	out := make([]PayoutSchedule, 0, len(rows))
+	for _, ps := range rows {
+		out = append(out, s.snapshot(ps))
+	}
+	return out
+}
+
+// Update replaces a schedule's definition and recomputes its next run
+func (s *Service) Update(id string, def Definition, actor string) (PayoutSchedule, error) {
+	ps, ok := s.schedules.Get(id)
+	if !ok {
+		return PayoutSchedule{}, store.ErrNotFound
+	}
+	compiled, err := s.validate(def)
+	if err != nil {
+		return PayoutSchedule{}, err
+	}
+
+	s.mu.Lock()
+	before := ps.Definition
+	ps.Definition = def
+	ps.compiled = compiled
+	ps.UpdatedAt = time.Now().UTC()
+	if ps.Status == StatusActive {
+		s.advance(ps, ps.UpdatedAt)
+	}
+	s.mu.Unlock()
+
+	s.audit.Record(actor, "update", auditResource, id, before, def)
+	return s.snapshot(ps), nil
+}
+
+// Delete removes a schedule; its run history is kept
+func (s *Service) Delete(id, actor string) error {
+	ps, ok := s.schedules.Delete(id)
+	if !ok {
+		return store.ErrNotFound
+	}
+	s.audit.Record(actor, "delete", auditResource, id, ps.Definition, nil)
+	return nil
+}
+
+// Pause stops an active schedule from running
+func (s *Service) Pause(id, actor string) (PayoutSchedule, error) {
+	return s.transition(id, actor, "pause", StatusActive, StatusPaused)
+}
+
+// Resume reactivates a paused schedule from the next occurrence after now.
+// Occurrences missed while paused are not run.
+func (s *Service) Resume(id, actor string) (PayoutSchedule, error) {
+	return s.transition(id, actor, "resume", StatusPaused, StatusActive)
+}
+
+// Runs returns the run history of a schedule, newest first
+func (s *Service) Runs(id string) []Run {
+	runs := s.runs.List(func(r Run) bool { return r.ScheduleID == id })
+	for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+		runs[i], runs[j] = runs[j], runs[i]
+	}
+	return runs
+}
+
+func (s *Service) transition(id, actor, action, from, to string) (PayoutSchedule, error) {
+	ps, ok := s.schedules.Get(id)
+	if !ok {
+		return PayoutSchedule{}, store.ErrNotFound
+	}
+
+	s.mu.Lock()
+	if ps.Status != from {
+		s.mu.Unlock()
+		return PayoutSchedule{}, fmt.Errorf("%w: schedule is %s", ErrInvalidState, ps.Status)
+	}
+	ps.Status = to
+	ps.UpdatedAt = time.Now().UTC()
+	if to == StatusActive {
+		s.advance(ps, ps.UpdatedAt)
+	} else {
+		ps.NextRunAt, ps.NextNominalAt, ps.NextRunSkip = nil, nil, false
+	}
+	s.mu.Unlock()
+
+	s.audit.Record(actor, action, auditResource, id, from, to)
+	return s.snapshot(ps), nil
+}
+
+func (s *Service) validate(def Definition) (schedule.Schedule, error) {
+	compiled, err := def.Timing.Compile()
+	if err != nil {
+		return nil, fmt.Errorf("invalid timing: %w", err)
+	}
+	if err := def.Holidays.Validate(); err != nil {
+		return nil, err
+	}
+
+	t := def.Template
+	var missing []string
+	for _, field := range [][2]string{
+		{"beneficiaryId", t.BeneficiaryID},
+		{"customerId", t.CustomerID},
+		{"country", t.Country},
+		{"source", t.Source},
+		{"fromAsset", t.FromAsset},
+		{"toCurrency", t.ToCurrency},
+		{"paymentReason", t.PaymentReason},
+	} {
+		if strings.TrimSpace(field[1]) == "" {
+			missing = append(missing, field[0])
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("template is missing %s", strings.Join(missing, ", "))
+	}
+	if (t.Amount > 0) == (t.SettlementAmount > 0) {
+		return nil, errors.New("template requires exactly one of amount or settlementAmount")
+	}
+	if _, err := s.beneficiaries.Details(t.BeneficiaryID, t.Country); err != nil {
+		return nil, fmt.Errorf("beneficiary %s: %w", t.BeneficiaryID, err)
+	}
+	if def.Guardrails.MaxRateDeviationBps < 0 {
+		return nil, errors.New("maxRateDeviationBps must not be negative")
+	}
+
+	return compiled, nil
+}
+
+// advance computes the next run after t, applying the holiday policy and
+// completing the schedule once MaxRuns or EndAt is reached; callers hold s.mu
+// or own ps exclusively
+func (s *Service) advance(ps *PayoutSchedule, t time.Time) {
+	ps.NextRunAt, ps.NextNominalAt, ps.NextRunSkip = nil, nil, false
+
+	nominal := ps.compiled.Next(t)
+	if nominal.IsZero() || (ps.MaxRuns > 0 && ps.RunCount >= ps.MaxRuns) || (ps.EndAt != nil && nominal.After(*ps.EndAt)) {
+		ps.Status = StatusCompleted
+		return
+	}
+
+	runAt, skip := ps.Holidays.Adjust(nominal)
+	ps.NextNominalAt = &nominal
+	ps.NextRunAt = &runAt
+	ps.NextRunSkip = skip
+}
+
+func (s *Service) runDue(now time.Time) {
+	due := s.schedules.List(func(ps *PayoutSchedule) bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return ps.Status == StatusActive && ps.NextRunAt != nil && !ps.NextRunAt.After(now)
+	})
+
+	for _, ps := range due {
+		s.runOnce(ps, now)
+	}
+}
+
+func (s *Service) runOnce(ps *PayoutSchedule, now time.Time) {
+	s.mu.Lock()
+	scheduledFor := *ps.NextNominalAt
+	skip := ps.NextRunSkip
+	def := ps.Definition
+	lastRate := ps.LastRate
+	runNumber := ps.RunCount + 1
+	s.mu.Unlock()
+
+	run := Run{
+		ID:           store.NewID("prun"),
+		ScheduleID:   ps.ID,
+		ScheduledFor: scheduledFor,
+		StartedAt:    now.UTC(),
+	}
+
+	if skip {
+		run.Status = RunSkipped
+		run.Reason = "scheduled on a non-business day"
+	} else {
+		s.execute(&run, def, lastRate, runNumber)
+	}
+	run.FinishedAt = time.Now().UTC()
+	s.runs.Put(run.ID, run)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if run.Status == RunSucceeded {
+		ps.RunCount++
+		ps.LastRate = run.ExchangeRate
+	}
+	ps.LastRunAt = &run.StartedAt
+	if ps.Status == StatusActive {
+		s.advance(ps, scheduledFor)
+	}
+	s.logger.Printf("Payout schedule %s run %s: %s %s", ps.ID, run.ID, run.Status, run.Reason)
+}
+
+// execute performs limits check → quote → rate guardrail → initialize → finalize
+func (s *Service) execute(run *Run, def Definition, lastRate float64, runNumber int) {
+	t := def.Template
+	fail := func(status string, err error) {
+		run.Status = status
+		run.Reason = err.Error()
+	}
+
+	quoteReq := models.PayoutQuoteRequest{
+		Source:           t.Source,
+		FromAsset:        t.FromAsset,
+		ToCurrency:       t.ToCurrency,
+		Chain:            t.Chain,
+		Amount:           t.Amount,
+		SettlementAmount: t.SettlementAmount,
+		Country:          t.Country,
+	}
+
+	limits, err := s.requirements.Limits()
+	if err != nil {
+		fail(RunFailed, fmt.Errorf("limits: %w", err))
+		return
+	}
+	if err := requirements.CheckQuote(limits, quoteReq); err != nil && !errors.Is(err, requirements.ErrUnconverted) {
+		fail(RunBlocked, err)
+		return
+	}
+
+	details, err := s.beneficiaries.Details(t.BeneficiaryID, t.Country)
+	if err != nil {
+		fail(RunFailed, fmt.Errorf("beneficiary: %w", err))
+		return
+	}
+
+	raw, err := s.client.CreatePayoutQuote(quoteReq)
+	if err != nil {
+		fail(RunFailed, fmt.Errorf("quote: %w", err))
+		return
+	}
+	var quote models.PayoutQuoteResponse
+	if err := models.Decode(raw, &quote); err != nil {
+		fail(RunFailed, fmt.Errorf("quote: %w", err))
+		return
+	}
+	run.QuoteID = quote.QuoteID
+	if run.QuoteID == "" {
+		run.QuoteID = quote.ID
+	}
+	run.ExchangeRate = quote.ExchangeRate
+	if err := requirements.CheckQuoted(limits, quoteReq, quote); err != nil {
+		fail(RunBlocked, err)
+		return
+	}
+
+	if max := def.Guardrails.MaxRateDeviationBps; max > 0 && lastRate > 0 && quote.ExchangeRate > 0 {
+		deviation := math.Abs(quote.ExchangeRate-lastRate) / lastRate * 10000
+		if deviation > max {
+			fail(RunBlocked, fmt.Errorf("rate %.4f deviates %.1f bps from last run rate %.4f (max %.1f)",
+				quote.ExchangeRate, deviation, lastRate, max))
+			return
+		}
+	}
+
+	prefix := t.ReferencePrefix
+	if prefix == "" {
+		prefix = run.ScheduleID
+	}
+	run.Reference = fmt.Sprintf("%s-%d-%s", prefix, runNumber, run.ScheduledFor.UTC().Format("20060102T1504"))
+
+	raw, err = s.client.InitializePayout(models.InitializePayoutRequest{
+		QuoteID:       run.QuoteID,
+		CustomerID:    t.CustomerID,
+		Country:       t.Country,
+		Reference:     run.Reference,
+		PaymentReason: t.PaymentReason,
+		Beneficiary:   details,
+	})
+	if err != nil {
+		fail(RunFailed, fmt.Errorf("initialize: %w", err))
+		return
+	}
+	var init models.InitializePayoutResponse
+	if err := models.Decode(raw, &init); err == nil {
+		run.PayoutID = init.ID
+		run.Fees = init.Fees
+	}
+
+	if _, err := s.client.FinalizePayout(models.FinalizePayoutRequest{QuoteID: run.QuoteID}); err != nil {
+		fail(RunFailed, fmt.Errorf("finalize: %w", err))
+		return
+	}
+
+	run.Status = RunSucceeded
+}
+
+func (s *Service) snapshot(ps *PayoutSchedule) PayoutSchedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *ps
+}
@@ -0,0 +1,173 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitnob-api-demo/internal/secretbox"
+)
+
+// Key derivations of an encrypted credentials file
+const (
+	kdfKeyFile = "keyfile"
+	kdfScrypt  = "scrypt"
+)
+
+// mountProvider reads one file per value from a mounted secret directory,
+// as Kubernetes and Docker secrets are exposed
+type mountProvider struct {
+	dir string
+}
+
+func (p mountProvider) Load(context.Context) (Credentials, error) {
+	var creds Credentials
+	for name, dst := range map[string]*string{"client_id": &creds.ClientID, "client_secret": &creds.ClientSecret} {
+		raw, err := os.ReadFile(filepath.Join(p.dir, name))
+		if err != nil {
+			return Credentials{}, err
+		}
+		*dst = string(raw)
+	}
+	return creds.check()
+}
+
+func (p mountProvider) String() string {
+	return KindFile + ":" + p.dir
+}
+
+// sealedFile is the on-disk format of an encrypted credentials file
+type sealedFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Salt       string `json:"salt,omitempty"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// encryptedProvider decrypts a credentials file on every load, so replacing
+// the file rotates the credentials
+type encryptedProvider struct {
+	path       string
+	keyFile    string
+	passphrase string
+}
+
+func (p encryptedProvider) Load(context.Context) (Credentials, error) {
+	raw, err := os.ReadFile(p.path)
+	if err != nil {
+		return Credentials{}, err
+	}
+	return Open(raw, p.keyFile, p.passphrase)
+}
+
+func (p encryptedProvider) String() string {
+	return KindEncrypted + ":" + p.path
+}
+
+// Seal encrypts credentials with the key in keyFile or, when keyFile is
+// empty, a key derived from passphrase
+func Seal(creds Credentials, keyFile, passphrase string) ([]byte, error) {
+	creds, err := creds.check()
+	if err != nil {
+		return nil, err
+	}
+
+	file := sealedFile{Version: 1, KDF: kdfKeyFile}
+	var key []byte
+	switch {
+	case keyFile != "":
+		key, err = readKeyFile(keyFile)
+	case passphrase != "":
+		var salt []byte
+		if salt, err = secretbox.RandomKey(); err != nil {
+			return nil, err
+		}
+		file.KDF, file.Salt = kdfScrypt, base64.StdEncoding.EncodeToString(salt)
+		key, err = secretbox.DeriveKey(passphrase, salt)
+	default:
+		return nil, ErrNoDecryption
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	box, err := secretbox.New(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := json.Marshal(creds)
+	if err != nil {
+		return nil, err
+	}
+	if file.Ciphertext, err = box.Seal(string(plaintext)); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(file, "", "  ")
+}
+
+// Open decrypts a file written by Seal
+func Open(raw []byte, keyFile, passphrase string) (Credentials, error) {
+	var file sealedFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return Credentials{}, fmt.Errorf("invalid encrypted secrets file: %w", err)
+	}
+
+	var key []byte
+	var err error
+	switch file.KDF {
+	case kdfKeyFile:
+		if keyFile == "" {
+			return Credentials{}, fmt.Errorf("%w: the file was sealed with a key file", ErrNoDecryption)
+		}
+		key, err = readKeyFile(keyFile)
+	case kdfScrypt:
+		if passphrase == "" {
+			return Credentials{}, fmt.Errorf("%w: the file was sealed with a passphrase", ErrNoDecryption)
+		}
+		var salt []byte
+		if salt, err = base64.StdEncoding.DecodeString(file.Salt); err == nil {
+			key, err = secretbox.DeriveKey(passphrase, salt)
+		}
+	default:
+		return Credentials{}, fmt.Errorf("unsupported key derivation %q", file.KDF)
+	}
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	box, err := secretbox.New(key)
+	if err != nil {
+		return Credentials{}, err
+	}
+	plaintext, err := box.Open(file.Ciphertext)
+	if err != nil {
+		return Credentials{}, err
+	}
+	var creds Credentials
+	if err := json.Unmarshal([]byte(plaintext), &creds); err != nil {
+		return Credentials{}, fmt.Errorf("invalid decrypted secrets: %w", err)
+	}
+	return creds.check()
+}
+
+// readKeyFile accepts a raw 32-byte key, a hex key, or any other content,
+// which is hashed into a key
+func readKeyFile(path string) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 32 {
+		return raw, nil
+	}
+	// Editors and echo leave a trailing newline after a raw key
+	trimmed := strings.TrimSpace(string(raw))
+	if len(trimmed) == 32 {
+		return []byte(trimmed), nil
+	}
+	return secretbox.KeyFromString(trimmed), nil
+}
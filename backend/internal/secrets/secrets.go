@@ -0,0 +1,114 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/schedule"
+)
+
+// Provider kinds, used as the prefix of a provider spec
+const (
+	KindEnv       = "env"
+	KindFile      = "file"
+	KindEncrypted = "encrypted"
+	KindVault     = "vault"
+)
+
+var (
+	ErrInvalidSpec  = errors.New("invalid secret provider")
+	ErrIncomplete   = errors.New("client ID and client secret are required")
+	ErrNoDecryption = errors.New("encrypted secrets need a key file or passphrase")
+)
+
+// Credentials are a Bitnob API key pair
+type Credentials struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+func (c Credentials) check() (Credentials, error) {
+	c.ClientID, c.ClientSecret = strings.TrimSpace(c.ClientID), strings.TrimSpace(c.ClientSecret)
+	if c.ClientID == "" || c.ClientSecret == "" {
+		return Credentials{}, ErrIncomplete
+	}
+	return c, nil
+}
+
+// Provider loads the current credentials from a secret store. String
+// describes the source for logs and never includes secret material.
+type Provider interface {
+	Load(ctx context.Context) (Credentials, error)
+	String() string
+}
+
+// Options configure the providers that need more than a path
+type Options struct {
+	KeyFile    string
+	Passphrase string
+	VaultAddr  string
+	VaultToken string
+}
+
+// Parse builds a provider from a spec:
+//
+//	env              the static credentials given
+//	file:DIR         client_id and client_secret files in a mounted directory
+//	encrypted:PATH   a file written by cmd/secrets, opened with the key file or passphrase
+//	vault:PATH       a Vault KV v1 or v2 secret, e.g. vault:secret/data/bitnob
+func Parse(spec string, static Credentials, opts Options) (Provider, error) {
+	kind, path, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	kind = strings.ToLower(kind)
+	if kind != KindEnv && kind != "" && path == "" {
+		return nil, fmt.Errorf("%w: %q needs a path", ErrInvalidSpec, spec)
+	}
+
+	switch kind {
+	case KindEnv, "":
+		return staticProvider{creds: static}, nil
+	case KindFile:
+		return mountProvider{dir: path}, nil
+	case KindEncrypted:
+		if opts.KeyFile == "" && opts.Passphrase == "" {
+			return nil, ErrNoDecryption
+		}
+		return encryptedProvider{path: path, keyFile: opts.KeyFile, passphrase: opts.Passphrase}, nil
+	case KindVault:
+		return newVaultProvider(opts.VaultAddr, opts.VaultToken, path), nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrInvalidSpec, spec)
+}
+
+// Watch reloads credentials at interval until ctx is cancelled and calls
+// apply whenever they change. Failed reloads keep the current credentials.
+func Watch(ctx context.Context, logger *log.Logger, p Provider, interval time.Duration, current Credentials, apply func(Credentials)) {
+	schedule.Every(ctx, interval, func(time.Time) {
+		next, err := p.Load(ctx)
+		if err != nil {
+			logger.Printf("Failed to reload credentials from %s: %v", p, err)
+			return
+		}
+		if next == current {
+			return
+		}
+		current = next
+		apply(next)
+		logger.Printf("Rotated Bitnob credentials from %s", p)
+	})
+}
+
+type staticProvider struct {
+	creds Credentials
+}
+
+func (p staticProvider) Load(context.Context) (Credentials, error) {
+	return p.creds.check()
+}
+
+func (p staticProvider) String() string {
+	return KindEnv
+}
@@ -0,0 +1,79 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// vaultTimeout bounds each read from Vault
+const vaultTimeout = 10 * time.Second
+
+// vaultProvider reads credentials from a Vault KV secret over the HTTP API.
+// KV v2 paths include the data segment, e.g. secret/data/bitnob.
+type vaultProvider struct {
+	addr       string
+	token      string
+	path       string
+	httpClient *http.Client
+}
+
+func newVaultProvider(addr, token, path string) vaultProvider {
+	return vaultProvider{
+		addr:       strings.TrimRight(addr, "/"),
+		token:      token,
+		path:       strings.Trim(path, "/"),
+		httpClient: &http.Client{Timeout: vaultTimeout},
+	}
+}
+
+func (p vaultProvider) Load(ctx context.Context) (Credentials, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.addr+"/v1/"+p.path, nil)
+	if err != nil {
+		return Credentials{}, err
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return Credentials{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Credentials{}, fmt.Errorf("vault returned status %d for %s", resp.StatusCode, p.path)
+	}
+
+	// KV v1 returns the fields in data; v2 nests them in data.data
+	var secret struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return Credentials{}, fmt.Errorf("invalid vault response: %w", err)
+	}
+	fields := secret.Data
+	if nested, ok := secret.Data["data"]; ok {
+		if _, v2 := secret.Data["metadata"]; v2 {
+			fields = nil
+			if err := json.Unmarshal(nested, &fields); err != nil {
+				return Credentials{}, fmt.Errorf("invalid vault secret: %w", err)
+			}
+		}
+	}
+
+	var creds Credentials
+	json.Unmarshal(fields["client_id"], &creds.ClientID)
+	json.Unmarshal(fields["client_secret"], &creds.ClientSecret)
+	return creds.check()
+}
+
+func (p vaultProvider) String() string {
+	return KindVault + ":" + p.path
+}
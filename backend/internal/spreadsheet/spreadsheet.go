@@ -0,0 +1,14 @@
+// Package spreadsheet prepares values for CSV files that are opened in
+// spreadsheet applications
+package spreadsheet
+
+import "strings"
+
+// Cell stops spreadsheets from evaluating free text as a formula by quoting
+// values that start with a formula character
+func Cell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
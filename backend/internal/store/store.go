@@ -0,0 +1,136 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+)
+
+// ErrNotFound is returned when a record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// Table is a concurrency-safe in-memory collection keyed by ID that keeps insertion order
+type Table[T any] struct {
+	mu    sync.RWMutex
+	rows  map[string]T
+	order []string
+}
+
+// NewTable creates an empty table
+func NewTable[T any]() *Table[T] {
+	return &Table[T]{rows: make(map[string]T)}
+}
+
+// Get returns the record with the given ID
+func (t *Table[T]) Get(id string) (T, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	row, ok := t.rows[id]
+	return row, ok
+}
+
+// Put inserts or replaces a record
+func (t *Table[T]) Put(id string, row T) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, exists := t.rows[id]; !exists {
+		t.order = append(t.order, id)
+	}
+	t.rows[id] = row
+}
+
+// Update applies fn to a copy of the record and stores the result if fn succeeds
+func (t *Table[T]) Update(id string, fn func(*T) error) (T, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	row, ok := t.rows[id]
+	if !ok {
+		var zero T
+		return zero, ErrNotFound
+	}
+	if err := fn(&row); err != nil {
+		var zero T
+		return zero, err
+	}
+	t.rows[id] = row
+	return row, nil
+}
+
+// Upsert applies fn to a copy of the record, or to a zero record when none
+// exists, and stores the result if fn succeeds
+func (t *Table[T]) Upsert(id string, fn func(row *T, exists bool) error) (T, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	row, exists := t.rows[id]
+	if err := fn(&row, exists); err != nil {
+		var zero T
+		return zero, err
+	}
+	if !exists {
+		t.order = append(t.order, id)
+	}
+	t.rows[id] = row
+	return row, nil
+}
+
+// Delete removes a record and returns it
+func (t *Table[T]) Delete(id string) (T, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	row, ok := t.rows[id]
+	if !ok {
+		return row, false
+	}
+	delete(t.rows, id)
+	for i, existing := range t.order {
+		if existing == id {
+			t.order = append(t.order[:i], t.order[i+1:]...)
+			break
+		}
+	}
+	return row, true
+}
+
+// List returns all records in insertion order, optionally filtered
+func (t *Table[T]) List(match func(T) bool) []T {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	out := make([]T, 0, len(t.order))
+	for _, id := range t.order {
+		row := t.rows[id]
+		if match == nil || match(row) {
+			out = append(out, row)
+		}
+	}
+	return out
+}
+
+// Find returns the first record matching the predicate
+func (t *Table[T]) Find(match func(T) bool) (T, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, id := range t.order {
+		if row := t.rows[id]; match(row) {
+			return row, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// Len returns the number of records
+func (t *Table[T]) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.rows)
+}
+
+// NewID generates a random identifier with the given prefix
+func NewID(prefix string) string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic("store: failed to read random bytes: " + err.Error())
+	}
+	return prefix + "_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,257 @@
+package tenant
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/bitnob"
+)
+
+// APIKeyHeader carries the key that authenticates a tenant
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyParam authenticates clients that cannot set headers, such as
+// EventSource and browser WebSockets
+const apiKeyParam = "api_key"
+
+// webhookPath is the one route Bitnob calls without a tenant key; the tenant
+// is named in ?tenant and authenticated by its webhook signature
+const webhookPath = "/api/webhooks/bitnob"
+
+var validID = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Tenant is a business unit with its own Bitnob account. Empty settings fall
+// back to the gateway defaults, except ConfirmProduction which each
+// production tenant must set itself.
+type Tenant struct {
+	ID                   string            `json:"id"`
+	Name                 string            `json:"name"`
+	APIKeys              []string          `json:"api_keys"`
+	AdminKeys            map[string]string `json:"admin_keys"`
+	ClientID             string            `json:"bitnob_client_id"`
+	ClientSecret         string            `json:"bitnob_client_secret"`
+	Credentials          string            `json:"credentials"`
+	SecondaryCredentials string            `json:"secondary_credentials"`
+	Environment          string            `json:"environment"`
+	ConfirmProduction    bool              `json:"confirm_production"`
+	BaseURL              string            `json:"bitnob_api_url"`
+	WebhookSecret        string            `json:"webhook_secret"`
+	CORSOrigins          []string          `json:"cors_origins"`
+	VelocityLimits       string            `json:"velocity_limits"`
+	VelocityWindow       Duration          `json:"velocity_window"`
+	BatchConcurrency     int               `json:"batch_concurrency"`
+}
+
+// Duration reads a Go duration string such as "24h" from JSON
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Load reads a JSON array of tenants from path, filling unset settings from
+// defaults
+func Load(path string, defaults Tenant) ([]Tenant, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tenants []Tenant
+	if err := json.Unmarshal(raw, &tenants); err != nil {
+		return nil, fmt.Errorf("invalid tenants file: %w", err)
+	}
+	for i := range tenants {
+		tenants[i] = withDefaults(tenants[i], defaults)
+	}
+	return tenants, Validate(tenants)
+}
+
+func withDefaults(t, defaults Tenant) Tenant {
+	t.ID = strings.ToLower(strings.TrimSpace(t.ID))
+	if t.Name == "" {
+		t.Name = t.ID
+	}
+	t.Environment = strings.ToLower(strings.TrimSpace(t.Environment))
+	if t.Environment == "" {
+		t.Environment = defaults.Environment
+	}
+	if t.BaseURL == "" {
+		t.BaseURL = defaults.BaseURL
+		if t.Environment != defaults.Environment {
+			t.BaseURL = bitnob.BaseURLs[t.Environment]
+		}
+	}
+	if len(t.CORSOrigins) == 0 {
+		t.CORSOrigins = defaults.CORSOrigins
+	}
+	if t.VelocityLimits == "" {
+		t.VelocityLimits = defaults.VelocityLimits
+	}
+	if t.VelocityWindow == 0 {
+		t.VelocityWindow = defaults.VelocityWindow
+	}
+	if t.BatchConcurrency <= 0 {
+		t.BatchConcurrency = defaults.BatchConcurrency
+	}
+	return t
+}
+
+// Validate checks that tenants are distinct and fully configured. At most one
+// tenant may have no API keys; it serves unauthenticated requests. Admin keys
+// are never shared with API keys or other tenants.
+func Validate(tenants []Tenant) error {
+	if len(tenants) == 0 {
+		return errors.New("no tenants configured")
+	}
+	ids := map[string]bool{}
+	keys := map[string]string{}
+	open := ""
+	for i, t := range tenants {
+		env, err := bitnob.ParseEnvironment(t.Environment)
+		if err != nil {
+			return fmt.Errorf("tenant %s: %w", t.ID, err)
+		}
+		tenants[i].Environment = env
+
+		switch {
+		case !validID.MatchString(t.ID):
+			return fmt.Errorf("invalid tenant ID %q", t.ID)
+		case ids[t.ID]:
+			return fmt.Errorf("duplicate tenant ID %q", t.ID)
+		case inlineCredentials(t) && (t.ClientID == "" || t.ClientSecret == ""):
+			return fmt.Errorf("tenant %s: Bitnob client ID and secret, or a credentials provider, are required", t.ID)
+		case strings.EqualFold(strings.TrimSpace(t.SecondaryCredentials), "env"):
+			return fmt.Errorf("tenant %s: secondary credentials need a file, encrypted or vault provider", t.ID)
+		case t.BaseURL == "":
+			return fmt.Errorf("tenant %s: Bitnob API URL is required", t.ID)
+		}
+		ids[t.ID] = true
+
+		if len(t.APIKeys) == 0 {
+			if open != "" {
+				return fmt.Errorf("tenants %s and %s both have no API keys", open, t.ID)
+			}
+			open = t.ID
+		}
+		for _, key := range t.APIKeys {
+			if len(key) < 16 {
+				return fmt.Errorf("tenant %s: API keys must be at least 16 characters", t.ID)
+			}
+			if other, ok := keys[key]; ok {
+				return fmt.Errorf("tenants %s and %s share an API key", other, t.ID)
+			}
+			keys[key] = t.ID
+		}
+	}
+	for _, t := range tenants {
+		for operator, key := range t.AdminKeys {
+			if len(key) < 16 {
+				return fmt.Errorf("tenant %s: admin key of %s must be at least 16 characters", t.ID, operator)
+			}
+			if other, ok := keys[key]; ok {
+				return fmt.Errorf("tenant %s: admin key of %s is also a key of tenant %s", t.ID, operator, other)
+			}
+			keys[key] = t.ID
+		}
+	}
+	return nil
+}
+
+// inlineCredentials reports whether the tenant keeps its Bitnob key pair in
+// the tenant settings rather than a secret provider
+func inlineCredentials(t Tenant) bool {
+	spec := strings.TrimSpace(t.Credentials)
+	return spec == "" || strings.EqualFold(spec, "env")
+}
+
+// Gateway routes each request to the handler of the tenant it authenticates
+// as. Tenants never share handlers, so their stores, clients and background
+// workers are isolated.
+type Gateway struct {
+	handlers map[string]http.Handler
+	keys     map[[sha256.Size]byte]string
+	origins  map[string]string
+	open     string
+}
+
+// NewGateway builds one handler per tenant with build
+func NewGateway(tenants []Tenant, build func(Tenant) http.Handler) *Gateway {
+	g := &Gateway{
+		handlers: make(map[string]http.Handler, len(tenants)),
+		keys:     make(map[[sha256.Size]byte]string),
+		origins:  make(map[string]string),
+	}
+	for _, t := range tenants {
+		g.handlers[t.ID] = build(t)
+		for _, key := range t.APIKeys {
+			g.keys[sha256.Sum256([]byte(key))] = t.ID
+		}
+		for _, origin := range t.CORSOrigins {
+			if _, taken := g.origins[origin]; !taken {
+				g.origins[origin] = t.ID
+			}
+		}
+		if len(t.APIKeys) == 0 {
+			g.open = t.ID
+		}
+	}
+	return g
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, ok := g.resolve(r)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"success":false,"error":"Tenant authentication required"}`))
+		return
+	}
+	g.handlers[id].ServeHTTP(w, r)
+}
+
+// resolve identifies the tenant of a request: by API key, by ?tenant on
+// webhooks, by Origin on CORS preflights, or the keyless tenant otherwise.
+// The key is removed from the query so it never reaches access logs.
+func (g *Gateway) resolve(r *http.Request) (string, bool) {
+	key := r.Header.Get(APIKeyHeader)
+	if query := r.URL.Query(); query.Has(apiKeyParam) {
+		if key == "" {
+			key = query.Get(apiKeyParam)
+		}
+		query.Del(apiKeyParam)
+		r.URL.RawQuery = query.Encode()
+	}
+	if key != "" {
+		id, ok := g.keys[sha256.Sum256([]byte(key))]
+		return id, ok
+	}
+
+	if r.URL.Path == webhookPath {
+		if id := r.URL.Query().Get("tenant"); id != "" {
+			_, ok := g.handlers[id]
+			return id, ok
+		}
+	}
+	if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+		if id, ok := g.origins[r.Header.Get("Origin")]; ok {
+			return id, true
+		}
+	}
+	return g.open, g.open != ""
+}
@@ -0,0 +1,527 @@
+package twap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitnob-api-demo/internal/models"
+	"github.com/bitnob-api-demo/internal/store"
+)
+
+// Parent order states
+const (
+	StatusRunning         = "running"
+	StatusCompleted       = "completed"
+	StatusPartiallyFilled = "partially_filled"
+	StatusStopped         = "stopped"
+	StatusCancelled       = "cancelled"
+	StatusFailed          = "failed"
+)
+
+// Child slice states
+const (
+	ChildPending   = "pending"
+	ChildFilled    = "filled"
+	ChildFailed    = "failed"
+	ChildSkipped   = "skipped"
+	ChildCancelled = "cancelled"
+)
+
+// quantityPrecision is the number of decimals child quantities are rounded down to
+const quantityPrecision = 8
+
+// maxChildren bounds how finely a parent order may be sliced
+const maxChildren = 500
+
+var ErrNotRunning = errors.New("order is not running")
+
+// TradingClient is the subset of the Bitnob client used to execute slices
+type TradingClient interface {
+	CreateTradingQuote(req interface{}) (interface{}, error)
+	CreateOrder(req interface{}) (interface{}, error)
+}
+
+// Request describes a parent order to be worked over Duration. Slices is
+// the preferred number of children; it is adjusted so every child falls
+// within MinChildQuantity and MaxChildQuantity.
+type Request struct {
+	BaseCurrency     string  `json:"base_currency" binding:"required"`
+	QuoteCurrency    string  `json:"quote_currency" binding:"required"`
+	Side             string  `json:"side" binding:"required"`
+	Quantity         float64 `json:"quantity" binding:"required"`
+	Duration         string  `json:"duration" binding:"required"`
+	Slices           int     `json:"slices,omitempty"`
+	MinChildQuantity float64 `json:"min_child_quantity,omitempty"`
+	MaxChildQuantity float64 `json:"max_child_quantity,omitempty"`
+	MaxSlippageBps   float64 `json:"max_slippage_bps,omitempty"`
+}
+
+// Child is one quote+order slice of a parent order
+type Child struct {
+	Index       int        `json:"index"`
+	ScheduledAt time.Time  `json:"scheduled_at"`
+	ExecutedAt  *time.Time `json:"executed_at,omitempty"`
+	Quantity    string     `json:"quantity"`
+	Status      string     `json:"status"`
+	QuoteID     string     `json:"quote_id,omitempty"`
+	OrderID     string     `json:"order_id,omitempty"`
+	Price       float64    `json:"price,omitempty"`
+	SlippageBps float64    `json:"slippage_bps,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// Report summarises execution quality against the arrival price. Shortfall
+// is positive when execution was worse than arriving at once; the opportunity
+// cost prices unfilled quantity at the last quote seen.
+type Report struct {
+	FilledQuantity    float64 `json:"filled_quantity"`
+	FilledNotional    float64 `json:"filled_notional"`
+	AveragePrice      float64 `json:"average_price,omitempty"`
+	LastPrice         float64 `json:"last_price,omitempty"`
+	ExecutionCost     float64 `json:"execution_cost"`
+	OpportunityCost   float64 `json:"opportunity_cost"`
+	ShortfallCost     float64 `json:"implementation_shortfall"`
+	ShortfallBps      float64 `json:"implementation_shortfall_bps"`
+	CompletedSlices   int     `json:"completed_slices"`
+	FailedSlices      int     `json:"failed_slices"`
+	RemainingSlices   int     `json:"remaining_slices"`
+	RemainingQuantity float64 `json:"remaining_quantity"`
+}
+
+// Order is a parent order and its slices
+type Order struct {
+	ID            string     `json:"id"`
+	BaseCurrency  string     `json:"base_currency"`
+	QuoteCurrency string     `json:"quote_currency"`
+	Side          string     `json:"side"`
+	Quantity      float64    `json:"quantity"`
+	Duration      string     `json:"duration"`
+	Interval      string     `json:"interval"`
+	MaxSlippage   float64    `json:"max_slippage_bps,omitempty"`
+	ArrivalPrice  float64    `json:"arrival_price"`
+	ArrivalQuote  string     `json:"arrival_quote_id"`
+	Status        string     `json:"status"`
+	StopReason    string     `json:"stop_reason,omitempty"`
+	CreatedBy     string     `json:"created_by"`
+	StartedAt     time.Time  `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+	Report        Report     `json:"report"`
+	Children      []Child    `json:"children,omitempty"`
+
+	cancel context.CancelFunc
+}
+
+// Service slices and executes parent orders
+type Service struct {
+	ctx    context.Context
+	client TradingClient
+	logger *log.Logger
+
+	mu     sync.Mutex
+	orders *store.Table[*Order]
+}
+
+// NewService creates an executor whose running orders stop when ctx is cancelled
+func NewService(ctx context.Context, client TradingClient) *Service {
+	return &Service{
+		ctx:    ctx,
+		client: client,
+		orders: store.NewTable[*Order](),
+		logger: log.Default(),
+	}
+}
+
+// WithLogger sends logs of stopped orders to logger, e.g. one tagged with the tenant
+func (s *Service) WithLogger(logger *log.Logger) *Service {
+	s.logger = logger
+	return s
+}
+
+// Create takes an arrival quote for the full quantity, plans the slices and
+// starts executing them; the first slice is placed immediately
+func (s *Service) Create(req Request, actor string) (Order, error) {
+	req.Side = strings.ToLower(strings.TrimSpace(req.Side))
+	req.BaseCurrency = strings.ToUpper(strings.TrimSpace(req.BaseCurrency))
+	req.QuoteCurrency = strings.ToUpper(strings.TrimSpace(req.QuoteCurrency))
+
+	if req.Side != "buy" && req.Side != "sell" {
+		return Order{}, fmt.Errorf("side must be buy or sell, got %q", req.Side)
+	}
+	duration, err := time.ParseDuration(req.Duration)
+	if err != nil || duration <= 0 {
+		return Order{}, fmt.Errorf("invalid duration %q", req.Duration)
+	}
+	if req.MaxSlippageBps < 0 {
+		return Order{}, errors.New("max_slippage_bps must not be negative")
+	}
+	sizes, err := plan(req)
+	if err != nil {
+		return Order{}, err
+	}
+
+	arrival, price, err := s.quote(req.BaseCurrency, req.QuoteCurrency, req.Side, formatQuantity(req.Quantity))
+	if err != nil {
+		return Order{}, fmt.Errorf("arrival quote: %w", err)
+	}
+
+	now := time.Now().UTC()
+	interval := time.Duration(0)
+	if len(sizes) > 1 {
+		interval = duration / time.Duration(len(sizes)-1)
+	}
+
+	o := &Order{
+		ID:            store.NewID("twap"),
+		BaseCurrency:  req.BaseCurrency,
+		QuoteCurrency: req.QuoteCurrency,
+		Side:          req.Side,
+		Quantity:      req.Quantity,
+		Duration:      duration.String(),
+		Interval:      interval.String(),
+		MaxSlippage:   req.MaxSlippageBps,
+		ArrivalPrice:  price,
+		ArrivalQuote:  arrival.ID,
+		Status:        StatusRunning,
+		CreatedBy:     actor,
+		StartedAt:     now,
+	}
+	for i, size := range sizes {
+		o.Children = append(o.Children, Child{
+			Index:       i,
+			ScheduledAt: now.Add(time.Duration(i) * interval),
+			Quantity:    formatQuantity(size),
+			Status:      ChildPending,
+		})
+	}
+	o.Report = report(o)
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	o.cancel = cancel
+	s.orders.Put(o.ID, o)
+	go s.work(ctx, o)
+
+	return s.snapshot(o), nil
+}
+
+// Get returns a parent order with its slices and execution report
+func (s *Service) Get(id string) (Order, bool) {
+	o, ok := s.orders.Get(id)
+	if !ok {
+		return Order{}, false
+	}
+	return s.snapshot(o), true
+}
+
+// List returns parent orders without their slices
+func (s *Service) List() []Order {
+	rows := s.orders.List(nil)
+	out := make([]Order, 0, len(rows))
+	for _, o := range rows {
+		snap := s.snapshot(o)
+		snap.Children = nil
+		out = append(out, snap)
+	}
+	return out
+}
+
+// Cancel stops a running order; slices already placed are kept
+func (s *Service) Cancel(id string) (Order, error) {
+	o, ok := s.orders.Get(id)
+	if !ok {
+		return Order{}, store.ErrNotFound
+	}
+
+	s.mu.Lock()
+	if o.Status != StatusRunning {
+		status := o.Status
+		s.mu.Unlock()
+		return Order{}, fmt.Errorf("%w: order is %s", ErrNotRunning, status)
+	}
+	s.finish(o, StatusCancelled, "cancelled by request")
+	s.mu.Unlock()
+
+	o.cancel()
+	return s.snapshot(o), nil
+}
+
+// plan splits the parent quantity into child sizes. The requested slice
+// count is raised or lowered to honour the child size bounds, and the last
+// child absorbs rounding.
+func plan(req Request) ([]float64, error) {
+	if req.Quantity <= 0 {
+		return nil, errors.New("quantity must be positive")
+	}
+	if req.MinChildQuantity < 0 || req.MaxChildQuantity < 0 {
+		return nil, errors.New("child quantity bounds must not be negative")
+	}
+	if req.MaxChildQuantity > 0 && req.MinChildQuantity > req.MaxChildQuantity {
+		return nil, errors.New("min_child_quantity must not exceed max_child_quantity")
+	}
+
+	n := req.Slices
+	if n <= 0 {
+		n = 10
+	}
+	if req.MaxChildQuantity > 0 {
+		n = max(n, int(math.Ceil(req.Quantity/req.MaxChildQuantity)))
+	}
+	if req.MinChildQuantity > 0 {
+		n = min(n, int(math.Floor(req.Quantity/req.MinChildQuantity)))
+	}
+	if n < 1 {
+		return nil, errors.New("quantity is smaller than min_child_quantity")
+	}
+	if req.MaxChildQuantity > 0 && req.Quantity/float64(n) > req.MaxChildQuantity {
+		return nil, errors.New("child size bounds cannot be met for this quantity")
+	}
+	if n > maxChildren {
+		return nil, fmt.Errorf("order would need %d slices, more than the maximum of %d", n, maxChildren)
+	}
+
+	child := roundDown(req.Quantity / float64(n))
+	sizes := make([]float64, n)
+	for i := range sizes {
+		sizes[i] = child
+	}
+	sizes[n-1] = roundDown(req.Quantity - child*float64(n-1))
+	return sizes, nil
+}
+
+func (s *Service) work(ctx context.Context, o *Order) {
+	defer o.cancel()
+
+	for i := range o.Children {
+		s.mu.Lock()
+		at := o.Children[i].ScheduledAt
+		s.mu.Unlock()
+
+		if wait := time.Until(at); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				s.abandon(o, "gateway shutting down")
+				return
+			case <-timer.C:
+			}
+		}
+		if ctx.Err() != nil {
+			s.abandon(o, "gateway shutting down")
+			return
+		}
+
+		if !s.executeChild(o, i) {
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if o.Status == StatusRunning {
+		status := StatusCompleted
+		switch r := report(o); {
+		case r.FilledQuantity == 0:
+			status = StatusFailed
+		case r.FailedSlices > 0:
+			status = StatusPartiallyFilled
+		}
+		s.finish(o, status, "")
+	}
+}
+
+// executeChild quotes and places one slice. It returns false once the
+// parent has stopped and no further slices should run.
+func (s *Service) executeChild(o *Order, i int) bool {
+	s.mu.Lock()
+	if o.Status != StatusRunning {
+		s.mu.Unlock()
+		return false
+	}
+	child := o.Children[i]
+	s.mu.Unlock()
+
+	quote, price, err := s.quote(o.BaseCurrency, o.QuoteCurrency, o.Side, child.Quantity)
+	now := time.Now().UTC()
+	if err != nil {
+		s.setChild(o, i, func(c *Child) {
+			c.Status = ChildFailed
+			c.Error = "quote: " + err.Error()
+		})
+		return true
+	}
+
+	slippage := slippageBps(o.Side, o.ArrivalPrice, price)
+	if o.MaxSlippage > 0 && slippage > o.MaxSlippage {
+		reason := fmt.Sprintf("quoted price %s is %.1f bps from arrival price %s (max %.1f)",
+			formatQuantity(price), slippage, formatQuantity(o.ArrivalPrice), o.MaxSlippage)
+
+		s.mu.Lock()
+		o.Children[i].Status = ChildSkipped
+		o.Children[i].Price = price
+		o.Children[i].SlippageBps = slippage
+		o.Children[i].Error = reason
+		s.finish(o, StatusStopped, reason)
+		s.mu.Unlock()
+		s.logger.Printf("TWAP order %s stopped: %s", o.ID, reason)
+		return false
+	}
+
+	raw, err := s.client.CreateOrder(models.CreateOrderRequest{
+		BaseCurrency:  o.BaseCurrency,
+		QuoteCurrency: o.QuoteCurrency,
+		Side:          o.Side,
+		Quantity:      child.Quantity,
+		Price:         quote.Price,
+		QuoteID:       quote.ID,
+		Metadata:      map[string]interface{}{"parent_order_id": o.ID, "slice": i},
+	})
+
+	s.setChild(o, i, func(c *Child) {
+		c.QuoteID = quote.ID
+		c.Price = price
+		c.SlippageBps = slippage
+		c.ExecutedAt = &now
+		if err != nil {
+			c.Status = ChildFailed
+			c.Error = "order: " + err.Error()
+			return
+		}
+		c.Status = ChildFilled
+		var resp models.OrderResponse
+		if err := models.Decode(raw, &resp); err == nil {
+			c.OrderID = resp.ID
+		}
+	})
+	return true
+}
+
+func (s *Service) quote(base, quote, side, quantity string) (models.CreateQuoteResponse, float64, error) {
+	var q models.CreateQuoteResponse
+
+	raw, err := s.client.CreateTradingQuote(models.CreateQuoteRequest{
+		BaseCurrency:  base,
+		QuoteCurrency: quote,
+		Side:          side,
+		Quantity:      quantity,
+	})
+	if err != nil {
+		return q, 0, err
+	}
+	if err := models.Decode(raw, &q); err != nil {
+		return q, 0, err
+	}
+	price, err := strconv.ParseFloat(q.Price, 64)
+	if err != nil || price <= 0 {
+		return q, 0, fmt.Errorf("invalid quoted price %q", q.Price)
+	}
+	return q, price, nil
+}
+
+func (s *Service) setChild(o *Order, i int, fn func(*Child)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&o.Children[i])
+	o.Report = report(o)
+}
+
+// abandon stops an order whose worker is exiting early
+func (s *Service) abandon(o *Order, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if o.Status == StatusRunning {
+		s.finish(o, StatusStopped, reason)
+	}
+}
+
+// finish moves the parent to a terminal status and cancels pending slices;
+// callers hold s.mu
+func (s *Service) finish(o *Order, status, reason string) {
+	now := time.Now().UTC()
+	o.Status = status
+	o.StopReason = reason
+	o.FinishedAt = &now
+	for i := range o.Children {
+		if o.Children[i].Status == ChildPending {
+			o.Children[i].Status = ChildCancelled
+		}
+	}
+	o.Report = report(o)
+}
+
+// report recomputes fills and implementation shortfall; callers hold s.mu
+func report(o *Order) Report {
+	var r Report
+	for _, c := range o.Children {
+		switch c.Status {
+		case ChildFilled:
+			qty, _ := strconv.ParseFloat(c.Quantity, 64)
+			r.FilledQuantity += qty
+			r.FilledNotional += qty * c.Price
+			r.CompletedSlices++
+		case ChildFailed:
+			r.FailedSlices++
+		case ChildPending:
+			r.RemainingSlices++
+		}
+		if c.Price > 0 {
+			r.LastPrice = c.Price
+		}
+	}
+	r.FilledQuantity = roundDown(r.FilledQuantity)
+	r.RemainingQuantity = roundDown(math.Max(o.Quantity-r.FilledQuantity, 0))
+	if r.FilledQuantity > 0 {
+		r.AveragePrice = r.FilledNotional / r.FilledQuantity
+	}
+
+	// Costs are expressed so that a positive value is worse for the trader
+	sign := 1.0
+	if o.Side == "sell" {
+		sign = -1
+	}
+	r.ExecutionCost = sign * (r.FilledNotional - r.FilledQuantity*o.ArrivalPrice)
+	if r.LastPrice > 0 && o.Status != StatusRunning {
+		r.OpportunityCost = sign * r.RemainingQuantity * (r.LastPrice - o.ArrivalPrice)
+	}
+	r.ShortfallCost = r.ExecutionCost + r.OpportunityCost
+	if paper := o.Quantity * o.ArrivalPrice; paper > 0 {
+		r.ShortfallBps = r.ShortfallCost / paper * 10000
+	}
+	return r
+}
+
+// slippageBps is how far price has moved against the trader from arrival
+func slippageBps(side string, arrival, price float64) float64 {
+	if arrival <= 0 {
+		return 0
+	}
+	move := (price - arrival) / arrival * 10000
+	if side == "sell" {
+		return -move
+	}
+	return move
+}
+
+func (s *Service) snapshot(o *Order) Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := *o
+	out.Children = append([]Child(nil), o.Children...)
+	out.cancel = nil
+	return out
+}
+
+func roundDown(q float64) float64 {
+	p := math.Pow10(quantityPrecision)
+	return math.Floor(q*p+1e-6) / p
+}
+
+func formatQuantity(q float64) string {
+	return strconv.FormatFloat(q, 'f', -1, 64)
+}
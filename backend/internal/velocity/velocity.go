@@ -0,0 +1,176 @@
+package velocity
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidAmount is returned for amounts that are not positive decimals
+var ErrInvalidAmount = errors.New("amount must be a positive decimal number")
+
+// decimalAmount accepts plain decimals only, never NaN, Inf, signs or exponents
+var decimalAmount = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+// ParseAmount parses a money amount such as "0.0015"
+func ParseAmount(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if !decimalAmount.MatchString(s) {
+		return 0, ErrInvalidAmount
+	}
+	amount, err := strconv.ParseFloat(s, 64)
+	if err != nil || !validAmount(amount) {
+		return 0, ErrInvalidAmount
+	}
+	return amount, nil
+}
+
+func validAmount(amount float64) bool {
+	return amount > 0 && !math.IsNaN(amount) && !math.IsInf(amount, 0)
+}
+
+// LimitError reports that a transfer would exceed the rolling velocity limit
+type LimitError struct {
+	Currency  string  `json:"currency"`
+	Limit     float64 `json:"limit"`
+	Used      float64 `json:"used"`
+	Requested float64 `json:"requested"`
+	Window    string  `json:"window"`
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s velocity limit exceeded: %g used + %g requested > %g per %s",
+		e.Currency, e.Used, e.Requested, e.Limit, e.Window)
+}
+
+type event struct {
+	id     uint64
+	at     time.Time
+	amount float64
+}
+
+// Reservation identifies an amount recorded against a velocity limit
+type Reservation struct {
+	Currency string
+	id       uint64
+}
+
+// Tracker enforces a maximum total amount per currency over a rolling window
+type Tracker struct {
+	mu     sync.Mutex
+	nextID uint64
+	window time.Duration
+	limits map[string]float64
+	events map[string][]event
+}
+
+// NewTracker creates a tracker. Currencies without a limit are unrestricted.
+func NewTracker(window time.Duration, limits map[string]float64) *Tracker {
+	normalized := make(map[string]float64, len(limits))
+	for currency, limit := range limits {
+		normalized[strings.ToUpper(currency)] = limit
+	}
+	return &Tracker{
+		window: window,
+		limits: normalized,
+		events: make(map[string][]event),
+	}
+}
+
+// ParseLimits parses "BTC:0.5,USDT:10000" into a limit map
+func ParseLimits(spec string) (map[string]float64, error) {
+	limits := make(map[string]float64)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		currency, amount, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid velocity limit %q", part)
+		}
+		limit, err := ParseAmount(amount)
+		if err != nil {
+			return nil, fmt.Errorf("invalid velocity limit %q: %w", part, err)
+		}
+		limits[strings.ToUpper(strings.TrimSpace(currency))] = limit
+	}
+	return limits, nil
+}
+
+// Used returns the amount sent in the current window
+func (t *Tracker) Used(currency string, now time.Time) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.used(strings.ToUpper(currency), now)
+}
+
+// Check reports whether amount more of currency may be sent now
+func (t *Tracker) Check(currency string, amount float64, now time.Time) error {
+	currency = strings.ToUpper(currency)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.check(currency, amount, now)
+}
+
+// Reserve checks the limit and records the amount in one step
+func (t *Tracker) Reserve(currency string, amount float64, now time.Time) (Reservation, error) {
+	currency = strings.ToUpper(currency)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.check(currency, amount, now); err != nil {
+		return Reservation{}, err
+	}
+	t.nextID++
+	t.events[currency] = append(t.events[currency], event{id: t.nextID, at: now, amount: amount})
+	return Reservation{Currency: currency, id: t.nextID}, nil
+}
+
+// Release removes a reservation, e.g. after the transfer failed upstream
+func (t *Tracker) Release(r Reservation) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	events := t.events[r.Currency]
+	for i, e := range events {
+		if e.id == r.id {
+			t.events[r.Currency] = append(events[:i], events[i+1:]...)
+			return
+		}
+	}
+}
+
+func (t *Tracker) check(currency string, amount float64, now time.Time) error {
+	if !validAmount(amount) {
+		return ErrInvalidAmount
+	}
+	limit, ok := t.limits[currency]
+	if !ok {
+		return nil
+	}
+	used := t.used(currency, now)
+	if used+amount > limit {
+		return &LimitError{Currency: currency, Limit: limit, Used: used, Requested: amount, Window: t.window.String()}
+	}
+	return nil
+}
+
+// used prunes expired events and sums the rest; callers hold t.mu
+func (t *Tracker) used(currency string, now time.Time) float64 {
+	cutoff := now.Add(-t.window)
+	events := t.events[currency]
+	kept := events[:0]
+	total := 0.0
+	for _, e := range events {
+		if e.at.After(cutoff) {
+			kept = append(kept, e)
+			total += e.amount
+		}
+	}
+	t.events[currency] = kept
+	return total
+}
@@ -126,7 +126,7 @@ export default function TradingPage() {
         "http://localhost:8080/api/trading/orders",
       );
       const data = await response.json();
-      setOrders(Array.isArray(data) ? data : []);
+      setOrders(Array.isArray(data) ? data : Array.isArray(data.data) ? data.data : []);
     } catch (error) {
       console.error("Failed to fetch orders:", error);
     }